@@ -1,34 +1,53 @@
 package cache
 
 import (
-	"errors"
+	"bytes"
+	"container/list"
+	"encoding/json"
+	"sync"
 	"time"
 
-	"github.com/kpango/glg"
-
-	"github.com/allegro/bigcache"
 	"github.com/gizo-network/gizo/core"
 	"github.com/gizo-network/gizo/job"
+	"github.com/gizo-network/gizo/metrics"
 )
 
-//MaxCacheLen holds/held jobs in the cache
-const (
+//! size overridden by config
+var (
 	MaxCacheLen = 128 //number of jobs held in cache
 )
 
-//ErrCacheFull handles errors if the cache is
-var (
-	ErrCacheFull = errors.New("Cache: Cache filled up")
+const (
+	MissingTTL = time.Minute // time an id that isn't in the blockchain is answered as not found without a lookup
 )
 
-//JobCache stores jobs
-type JobCache struct {
-	cache *bigcache.BigCache
-	bc    *core.BlockChain
+//Stats is a snapshot of the cache's lookups since it was created
+type Stats struct {
+	Len           int     `json:"len"`
+	Hits          uint64  `json:"hits"`
+	Misses        uint64  `json:"misses"`
+	Missing       uint64  `json:"missing"`       // lookups of ids known not to be in the blockchain
+	Evictions     uint64  `json:"evictions"`     // jobs dropped to make room
+	Invalidations uint64  `json:"invalidations"` // jobs dropped because a block added a new version
+	HitRate       float64 `json:"hit_rate"`
 }
 
-func (c JobCache) getCache() *bigcache.BigCache {
-	return c.cache
+//cached job
+type entry struct {
+	id  string
+	job []byte //! serialized so callers can't modify cached jobs
+}
+
+//JobCache is a read-through cache of jobs keyed by id, jobs that miss are read from the blockchain
+//and the least recently used job is evicted once the cache holds MaxCacheLen jobs
+type JobCache struct {
+	mu      *sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List           // front is the most recently used
+	missing map[string]time.Time // ids not found in the blockchain and when they were looked up
+	version *uint64              // incremented by every invalidation, jobs read before one aren't cached
+	bc      *core.BlockChain
+	stats   *Stats
 }
 
 func (c JobCache) getBC() *core.BlockChain {
@@ -37,120 +56,175 @@ func (c JobCache) getBC() *core.BlockChain {
 
 //IsFull returns true is cache is full
 func (c JobCache) IsFull() bool {
-	if c.getCache().Len() >= MaxCacheLen {
-		return true
-	}
-	return false
-}
-
-//updates cache every minute
-func (c JobCache) watch() {
-	ticker := time.NewTicker(time.Minute)
-	quit := make(chan struct{})
-	go func() {
-		for {
-			select {
-			case <-ticker.C:
-				glg.Warn("Job Cache: Updating cache")
-				c.fill()
-			case <-quit:
-				ticker.Stop()
-				return
-			}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len() >= MaxCacheLen
+}
+
+//Set adds key and value to cache, evicting the least recently used job if it's full
+func (c JobCache) Set(key string, val []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(key, val)
+}
+
+//caches a job read from the blockchain at version unless a block invalidated jobs since,
+//ids that weren't found are cached as missing
+func (c JobCache) setVersion(key string, val []byte, version uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if *c.version != version {
+		return
+	}
+	if val == nil {
+		if len(c.missing) >= MaxCacheLen {
+			c.expireMissing()
 		}
-	}()
+		if len(c.missing) < MaxCacheLen {
+			c.missing[key] = time.Now()
+		}
+		return
+	}
+	c.set(key, val)
 }
 
-//Set adds key and value to cache
-func (c JobCache) Set(key string, val []byte) error {
-	if c.getCache().Len() >= MaxCacheLen {
-		return ErrCacheFull
+func (c JobCache) set(key string, val []byte) {
+	delete(c.missing, key)
+	if el, ok := c.entries[key]; ok {
+		el.Value.(*entry).job = val
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(&entry{id: key, job: val})
+	for c.lru.Len() > MaxCacheLen {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*entry).id)
+		c.stats.Evictions++
 	}
-	c.getCache().Set(key, val)
-	return nil
 }
 
-//Get returns job from cache
+//Get returns job from cache, reading it from the blockchain on a miss
 func (c JobCache) Get(key string) (*job.Job, error) {
-	jBytes, err := c.getCache().Get(key)
-	if err != nil {
-		return nil, err
+	c.mu.Lock()
+	el, ok := c.entries[key]
+	if ok {
+		c.lru.MoveToFront(el)
+		c.stats.Hits++
+		jBytes := el.Value.(*entry).job
+		c.mu.Unlock()
+		metrics.JobCacheLookups.WithLabelValues("hit").Inc()
+		return job.DeserializeJob(jBytes)
+	}
+	if at, ok := c.missing[key]; ok && time.Since(at) < MissingTTL {
+		c.stats.Missing++
+		c.mu.Unlock()
+		metrics.JobCacheLookups.WithLabelValues("missing").Inc()
+		return nil, core.ErrJobNotFound
+	}
+	c.stats.Misses++
+	version := *c.version
+	c.mu.Unlock()
+	metrics.JobCacheLookups.WithLabelValues("miss").Inc()
+	j, err := c.getBC().FindJob(key)
+	if err == core.ErrJobNotFound {
+		c.setVersion(key, nil, version)
 	}
-	j, err := job.DeserializeJob(jBytes)
 	if err != nil {
 		return nil, err
 	}
+	c.setVersion(key, j.Serialize(), version)
 	return j, nil
 }
 
-//fills up the cache with jobs with most execs in the last 15 blocks
-func (c JobCache) fill() {
-	var jobs []job.Job
-	blks := c.getBC().GetLatest15()
-	if len(blks) != 0 {
-		for _, blk := range blks {
-			for _, job := range blk.GetNodes() {
-				jobs = append(jobs, job.GetJob())
-			}
-		}
-		sorted := mergeSort(jobs)
-		if len(sorted) > MaxCacheLen {
-			for i := 0; i <= MaxCacheLen; i++ {
-				c.Set(sorted[i].GetID(), sorted[i].Serialize())
-			}
-		} else {
-			for _, job := range sorted {
-				c.Set(job.GetID(), job.Serialize())
-			}
+//drops ids cached as missing for longer than MissingTTL
+func (c JobCache) expireMissing() {
+	for key, at := range c.missing {
+		if time.Since(at) >= MissingTTL {
+			delete(c.missing, key)
 		}
-	} else {
-		glg.Warn("Job Cache: Unable to refill cache - No blocks")
 	}
 }
 
-// NewJobCache returns the memoery address of a new JobCache
-func NewJobCache(bc *core.BlockChain) *JobCache {
-	c, _ := bigcache.NewBigCache(bigcache.DefaultConfig(time.Minute))
-	jc := JobCache{c, bc}
-	jc.fill()
-	go jc.watch()
-	return &jc
+//Invalidate drops a job from the cache, it's read from the blockchain on the next lookup
+func (c JobCache) Invalidate(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	*c.version++
+	delete(c.missing, key)
+	if el, ok := c.entries[key]; ok {
+		c.lru.Remove(el)
+		delete(c.entries, key)
+		c.stats.Invalidations++
+	}
 }
 
-func NewJobCacheNoWatch(bc *core.BlockChain) *JobCache {
-	c, _ := bigcache.NewBigCache(bigcache.DefaultConfig(time.Minute))
-	jc := JobCache{c, bc}
-	jc.fill()
-	return &jc
+//drops the jobs of a block added to the blockchain since it holds a new version of them
+func (c JobCache) invalidateBlock(b core.Block) {
+	for _, node := range b.GetNodes() {
+		c.Invalidate(node.GetJob().GetID())
+	}
 }
 
-// merge returns an array of job in order of number of execs in the job from max to min
-func merge(left, right []job.Job) []job.Job {
-	size, i, j := len(left)+len(right), 0, 0
-	slice := make([]job.Job, size, size)
-	for k := 0; k < size; k++ {
-		if i > len(left)-1 && j <= len(right)-1 {
-			slice[k] = right[j]
-			j++
-		} else if j > len(right)-1 && i <= len(left)-1 {
-			slice[k] = left[i]
-			i++
-		} else if len(left[i].GetExecs()) > len(right[j].GetExecs()) {
-			slice[k] = left[i]
-			i++
-		} else {
-			slice[k] = right[j]
-			j++
+//Stats returns the cache's size and lookups
+func (c JobCache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := *c.stats
+	stats.Len = c.lru.Len()
+	if lookups := stats.Hits + stats.Misses; lookups != 0 {
+		stats.HitRate = float64(stats.Hits) / float64(lookups)
+	}
+	return stats
+}
+
+//FindExec returns the latest successful exec of j with the args and pub of exec, the job is looked up in the cache then the blockchain.
+//execs with environment variables are never matched since their results can depend on them
+func (c JobCache) FindExec(j job.Job, exec *job.Exec) (*job.Exec, error) {
+	if exec.HasEnvs() {
+		return nil, job.ErrExecNotFound
+	}
+	found, err := c.Get(j.GetID())
+	if err != nil {
+		return nil, job.ErrExecNotFound
+	}
+	if bytes.Compare(found.GetHash(), j.GetHash()) != 0 || !found.GetDeterministic() {
+		return nil, job.ErrExecNotFound
+	}
+	//! args are compared as json since numbers of execs read from blocks are float64
+	want, err := json.Marshal(exec.GetArgs())
+	if err != nil {
+		return nil, err
+	}
+	execs := found.GetExecs()
+	for i := len(execs) - 1; i >= 0; i-- {
+		if execs[i].GetStatus() != job.FINISHED || execs[i].GetErr() != nil || execs[i].HasEnvs() || execs[i].GetPub() != exec.GetPub() {
+			continue
+		}
+		got, err := json.Marshal(execs[i].GetArgs())
+		if err == nil && bytes.Compare(got, want) == 0 {
+			return &execs[i], nil
 		}
 	}
-	return slice
+	return nil, job.ErrExecNotFound
 }
 
-//used to quickly sort the jobs from max execs to min execs
-func mergeSort(slice []job.Job) []job.Job {
-	if len(slice) < 2 {
-		return slice
+// NewJobCache returns the memoery address of a new JobCache, jobs are invalidated as blocks are added to bc
+func NewJobCache(bc *core.BlockChain) *JobCache {
+	jc := JobCache{
+		mu:      new(sync.Mutex),
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+		missing: make(map[string]time.Time),
+		version: new(uint64),
+		bc:      bc,
+		stats:   new(Stats),
 	}
-	mid := (len(slice)) / 2
-	return merge(mergeSort(slice[:mid]), mergeSort(slice[mid:]))
+	bc.OnBlock(jc.invalidateBlock)
+	return &jc
+}
+
+//NewJobCacheNoWatch returns a job cache, kept for callers from before the cache was read-through and invalidated by new blocks
+func NewJobCacheNoWatch(bc *core.BlockChain) *JobCache {
+	return NewJobCache(bc)
 }
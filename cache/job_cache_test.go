@@ -83,3 +83,129 @@ func TestJobCache(t *testing.T) {
 	assert.NotNil(t, cj3)
 	assert.False(t, c.IsFull())
 }
+
+func TestJobCacheFindExec(t *testing.T) {
+	os.Setenv("ENV", "dev")
+	core.RemoveDataPath()
+	priv, _ := crypt.GenKeys()
+	j := job.NewJob(`
+		func Square(n){
+		 return n * n
+		}`, "Square", false, hex.EncodeToString(priv))
+	j.SetDeterministic(true)
+	assert.True(t, j.Verify())
+	finished := job.Exec{Args: []interface{}{10}, Result: 100, Status: job.FINISHED}
+	failed := job.Exec{Args: []interface{}{20}, Err: "failed", Status: job.FINISHED}
+	j.AddExec(finished)
+	j.AddExec(failed)
+
+	node := merkletree.NewNode(*j, &merkletree.MerkleNode{}, &merkletree.MerkleNode{})
+	tree := merkletree.NewMerkleTree([]*merkletree.MerkleNode{node, node})
+	bc := core.CreateBlockChain("test")
+	blk := core.NewBlock(*tree, bc.GetLatestBlock().GetHeader().GetHash(), bc.GetNextHeight(), 10, "test")
+	bc.AddBlock(blk)
+	c := cache.NewJobCache(bc)
+
+	prior, err := c.FindExec(*j, &job.Exec{Args: []interface{}{10}})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 100, prior.GetResult())
+	_, err = c.FindExec(*j, &job.Exec{Args: []interface{}{20}})
+	assert.Equal(t, job.ErrExecNotFound, err)
+	_, err = c.FindExec(*j, &job.Exec{Args: []interface{}{30}})
+	assert.Equal(t, job.ErrExecNotFound, err)
+
+	//! execs of other submitters of private jobs and execs with envs aren't answered from prior execs
+	_, err = c.FindExec(*j, &job.Exec{Args: []interface{}{10}, Pub: "other"})
+	assert.Equal(t, job.ErrExecNotFound, err)
+	withEnvs, err := job.NewExec([]interface{}{10}, 0, job.NORMAL, 0, 0, 0, 0, "", job.NewEnvVariables(*job.NewEnv("key", "value")), "passphrase")
+	assert.NoError(t, err)
+	assert.True(t, withEnvs.HasEnvs())
+	_, err = c.FindExec(*j, withEnvs)
+	assert.Equal(t, job.ErrExecNotFound, err)
+
+	exec := job.Exec{Args: []interface{}{10}}
+	exec.Memoise(*prior)
+	assert.Equal(t, job.CacheHit, exec.GetCacheStatus())
+	assert.Equal(t, job.FINISHED, exec.GetStatus())
+}
+
+func TestJobCacheEviction(t *testing.T) {
+	os.Setenv("ENV", "dev")
+	core.RemoveDataPath()
+	defer func(size int) {
+		cache.MaxCacheLen = size
+	}(cache.MaxCacheLen)
+	cache.MaxCacheLen = 2
+	priv, _ := crypt.GenKeys()
+	var nodes []*merkletree.MerkleNode
+	var jobs []*job.Job
+	for i := 0; i < 3; i++ {
+		j := job.NewJob(`
+		func Square(n){
+		 return n * n
+		}`, "Square", false, hex.EncodeToString(priv))
+		jobs = append(jobs, j)
+		nodes = append(nodes, merkletree.NewNode(*j, &merkletree.MerkleNode{}, &merkletree.MerkleNode{}))
+	}
+	nodes = append(nodes, nodes[2])
+	tree := merkletree.NewMerkleTree(nodes)
+	bc := core.CreateBlockChain("test")
+	c := cache.NewJobCache(bc)
+	blk := core.NewBlock(*tree, bc.GetLatestBlock().GetHeader().GetHash(), bc.GetNextHeight(), 10, "test")
+	bc.AddBlock(blk)
+
+	for _, j := range jobs {
+		_, err := c.Get(j.GetID()) //! read through from the blockchain
+		assert.NoError(t, err)
+	}
+	stats := c.Stats()
+	assert.Equal(t, 2, stats.Len)
+	assert.EqualValues(t, 3, stats.Misses)
+	assert.EqualValues(t, 1, stats.Evictions)
+	assert.True(t, c.IsFull())
+
+	_, err := c.Get(jobs[2].GetID())
+	assert.NoError(t, err)
+	_, err = c.Get(jobs[0].GetID()) //! evicted as the least recently used
+	assert.NoError(t, err)
+	stats = c.Stats()
+	assert.EqualValues(t, 1, stats.Hits)
+	assert.EqualValues(t, 4, stats.Misses)
+	assert.Equal(t, 0.2, stats.HitRate)
+
+	next := merkletree.NewMerkleTree([]*merkletree.MerkleNode{nodes[2], nodes[2]})
+	bc.AddBlock(core.NewBlock(*next, bc.GetLatestBlock().GetHeader().GetHash(), bc.GetNextHeight(), 10, "test"))
+	assert.EqualValues(t, 1, c.Stats().Invalidations)
+	assert.Equal(t, 1, c.Stats().Len)
+
+	_, err = c.Get("unknown")
+	assert.Error(t, err)
+}
+
+func TestJobCacheMissing(t *testing.T) {
+	os.Setenv("ENV", "dev")
+	core.RemoveDataPath()
+	priv, _ := crypt.GenKeys()
+	j := job.NewJob(`
+		func Square(n){
+		 return n * n
+		}`, "Square", false, hex.EncodeToString(priv))
+	bc := core.CreateBlockChain("test")
+	c := cache.NewJobCache(bc)
+
+	_, err := c.Get(j.GetID())
+	assert.Equal(t, core.ErrJobNotFound, err)
+	_, err = c.Get(j.GetID()) //! answered without searching the blockchain
+	assert.Equal(t, core.ErrJobNotFound, err)
+	stats := c.Stats()
+	assert.EqualValues(t, 1, stats.Misses)
+	assert.EqualValues(t, 1, stats.Missing)
+
+	//! a block with the job drops it from the missing ids
+	node := merkletree.NewNode(*j, &merkletree.MerkleNode{}, &merkletree.MerkleNode{})
+	tree := merkletree.NewMerkleTree([]*merkletree.MerkleNode{node, node})
+	bc.AddBlock(core.NewBlock(*tree, bc.GetLatestBlock().GetHeader().GetHash(), bc.GetNextHeight(), 10, "test"))
+	found, err := c.Get(j.GetID())
+	assert.NoError(t, err)
+	assert.Equal(t, j.GetID(), found.GetID())
+}
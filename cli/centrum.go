@@ -0,0 +1,33 @@
+package cli
+
+import (
+	"path"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/gizo-network/gizo/config"
+	"github.com/gizo-network/gizo/core"
+	"github.com/gizo-network/gizo/helpers"
+	"github.com/gizo-network/gizo/registry"
+	"github.com/kpango/glg"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	intFlag(centrumCmd.Flags(), "port", "p", "port to run centrum registry on", func(c *config.Config) *int { return &c.Registry.Port })
+}
+
+var centrumCmd = &cobra.Command{
+	Use:   "centrum",
+	Short: "Spin up a self-hosted centrum registry",
+	Run: func(cmd *cobra.Command, args []string) {
+		helpers.Banner()
+		cfg := loadConfig()
+		core.InitializeDataPath()
+		db, err := bolt.Open(path.Join(core.IndexPath(), registry.RegistryDB), 0600, &bolt.Options{Timeout: time.Second * 2})
+		if err != nil {
+			glg.Fatal(err)
+		}
+		glg.Fatal(registry.NewRegistry(db).Start(cfg.Registry.Port))
+	},
+}
@@ -1,8 +1,6 @@
 package cli
 
 import (
-	"os"
-
 	"github.com/gizo-network/gizo/core"
 	"github.com/spf13/cobra"
 )
@@ -16,10 +14,10 @@ var cleardbCmd = &cobra.Command{
 	Short: "Clears db",
 	Run: func(cmd *cobra.Command, args []string) {
 		if env == "dev" {
-			os.Setenv("ENV", "dev")
-			core.RemoveDataPath()
+			core.SetDataDir(core.IndexPathDev)
 		} else {
-			core.RemoveDataPath()
+			core.SetDataDir(core.IndexPathProd)
 		}
+		core.RemoveDataPath()
 	},
 }
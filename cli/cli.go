@@ -1,10 +1,25 @@
 package cli
 
 import (
+	"github.com/gizo-network/gizo/config"
 	"github.com/kpango/glg"
 	"github.com/spf13/cobra"
 )
 
+func init() {
+	gizoCmd.PersistentFlags().StringVarP(&configPath, "config", "c", "", "path to config file (default ~/.gizo/config.yml)")
+	flags := gizoCmd.PersistentFlags()
+	stringFlag(flags, "data-dir", "blockchain and node db directory", func(c *config.Config) *string { return &c.DataDir })
+	stringFlag(flags, "centrum-url", "url of the centrum service", func(c *config.Config) *string { return &c.CentrumURL })
+	stringFlag(flags, "discovery", "centrum or static", func(c *config.Config) *string { return &c.Discovery })
+	stringsFlag(flags, "bootstrap", "dispatcher addresses (gizo://pub@ip:port) used by static discovery", func(c *config.Config) *[]string { return &c.Bootstrap })
+	boolFlag(flags, "tls", "serve and dial over tls", func(c *config.Config) *bool { return &c.TLS.Enabled })
+	stringFlag(flags, "tls-cert", "tls certificate file", func(c *config.Config) *string { return &c.TLS.CertFile })
+	stringFlag(flags, "tls-key", "tls key file", func(c *config.Config) *string { return &c.TLS.KeyFile })
+	stringFlag(flags, "tls-ca", "ca file peers are verified against", func(c *config.Config) *string { return &c.TLS.CAFile })
+	boolFlag(flags, "tls-mutual", "require peers to present a certificate", func(c *config.Config) *bool { return &c.TLS.Mutual })
+}
+
 var gizoCmd = &cobra.Command{
 	Use:     "gizo [command]",
 	Short:   "Job scheduling system build using blockchain",
@@ -13,8 +28,18 @@ var gizoCmd = &cobra.Command{
 	Version: "1.0.0",
 }
 
+//loads the node config from the config file, environment and flags
+func loadConfig() *config.Config {
+	cfg, err := config.Load(configPath, applyFlags)
+	if err != nil {
+		glg.Fatal(err)
+	}
+	cfg.Apply()
+	return cfg
+}
+
 func Execute() {
-	gizoCmd.AddCommand(workerCmd, dispatcherCmd)
+	gizoCmd.AddCommand(workerCmd, dispatcherCmd, centrumCmd)
 	if err := gizoCmd.Execute(); err != nil {
 		glg.Fatal(err)
 	}
@@ -1,13 +1,26 @@
 package cli
 
 import (
+	"time"
+
+	"github.com/gizo-network/gizo/config"
 	"github.com/gizo-network/gizo/helpers"
 	"github.com/gizo-network/gizo/p2p"
 	"github.com/spf13/cobra"
 )
 
 func init() {
-	dispatcherCmd.Flags().IntVarP(&port, "port", "p", 9999, "port to run dispatcher on")
+	flags := dispatcherCmd.Flags()
+	intFlag(flags, "port", "p", "port to run dispatcher on", func(c *config.Config) *int { return &c.Dispatcher.Port })
+	stringFlag(flags, "ip", "public ip, overrides the ip detected through nat", func(c *config.Config) *string { return &c.Dispatcher.IP })
+	intFlag(flags, "public-port", "", "port announced to the network if it differs from port", func(c *config.Config) *int { return &c.Dispatcher.PublicPort })
+	stringFlag(flags, "nat", "none, upnp or natpmp", func(c *config.Config) *string { return &c.Dispatcher.NAT })
+	intFlag(flags, "max-workers", "", "max workers connected to the dispatcher", func(c *config.Config) *int { return &c.Dispatcher.MaxWorkers })
+	intFlag(flags, "target-peers", "", "neighbours the dispatcher tries to stay connected to", func(c *config.Config) *int { return &c.Dispatcher.TargetPeers })
+	intFlag(flags, "high-water", "", "queued execs past which submissions are rejected, 0 is unlimited", func(c *config.Config) *int { return &c.Dispatcher.HighWater })
+	stringFlag(flags, "admin-token", "token of the admin api", func(c *config.Config) *string { return &c.Dispatcher.AdminToken })
+	boolFlag(flags, "admin-insecure", "serve the admin api without tls", func(c *config.Config) *bool { return &c.Dispatcher.AdminInsecure })
+	durationFlag(flags, "drain-timeout", "time running execs are waited for on shutdown", func(c *config.Config) *time.Duration { return &c.Dispatcher.DrainTimeout })
 }
 
 var dispatcherCmd = &cobra.Command{
@@ -15,7 +28,8 @@ var dispatcherCmd = &cobra.Command{
 	Short: "Spin up a dispatcher node",
 	Run: func(cmd *cobra.Command, args []string) {
 		helpers.Banner()
-		d := p2p.NewDispatcher(port)
+		cfg := loadConfig()
+		d := p2p.NewDispatcher(cfg)
 		d.Start()
 	},
 }
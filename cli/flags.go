@@ -1,6 +1,64 @@
 package cli
 
+import (
+	"time"
+
+	"github.com/gizo-network/gizo/config"
+	"github.com/spf13/pflag"
+)
+
 var (
-	port int
-	env  string
+	env        string
+	configPath string
+	overrides  []override // config settings overridden by flags
 )
+
+//a flag that overrides a config setting if it's set, its default is the default setting
+type override struct {
+	flag  *pflag.Flag
+	apply func(c *config.Config)
+}
+
+//applies the overrides of the flags that were set
+func applyFlags(c *config.Config) {
+	for _, o := range overrides {
+		if o.flag.Changed {
+			o.apply(c)
+		}
+	}
+}
+
+func stringFlag(flags *pflag.FlagSet, name, usage string, setting func(c *config.Config) *string) {
+	v := flags.String(name, *setting(config.Default()), usage)
+	overrides = append(overrides, override{flag: flags.Lookup(name), apply: func(c *config.Config) {
+		*setting(c) = *v
+	}})
+}
+
+func stringsFlag(flags *pflag.FlagSet, name, usage string, setting func(c *config.Config) *[]string) {
+	v := flags.StringSlice(name, *setting(config.Default()), usage)
+	overrides = append(overrides, override{flag: flags.Lookup(name), apply: func(c *config.Config) {
+		*setting(c) = *v
+	}})
+}
+
+func intFlag(flags *pflag.FlagSet, name, shorthand, usage string, setting func(c *config.Config) *int) {
+	v := flags.IntP(name, shorthand, *setting(config.Default()), usage)
+	overrides = append(overrides, override{flag: flags.Lookup(name), apply: func(c *config.Config) {
+		*setting(c) = *v
+	}})
+}
+
+func boolFlag(flags *pflag.FlagSet, name, usage string, setting func(c *config.Config) *bool) {
+	v := flags.Bool(name, *setting(config.Default()), usage)
+	overrides = append(overrides, override{flag: flags.Lookup(name), apply: func(c *config.Config) {
+		*setting(c) = *v
+	}})
+}
+
+func durationFlag(flags *pflag.FlagSet, name, usage string, setting func(c *config.Config) *time.Duration) {
+	v := flags.Duration(name, *setting(config.Default()), usage)
+	overrides = append(overrides, override{flag: flags.Lookup(name), apply: func(c *config.Config) {
+		*setting(c) = *v
+	}})
+}
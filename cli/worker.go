@@ -1,13 +1,14 @@
 package cli
 
 import (
+	"github.com/gizo-network/gizo/config"
 	"github.com/gizo-network/gizo/helpers"
 	"github.com/gizo-network/gizo/p2p"
 	"github.com/spf13/cobra"
 )
 
 func init() {
-	workerCmd.Flags().IntVarP(&port, "port", "p", 9998, "port to run worker on")
+	intFlag(workerCmd.Flags(), "port", "p", "port to run worker on", func(c *config.Config) *int { return &c.Worker.Port })
 }
 
 var workerCmd = &cobra.Command{
@@ -15,7 +16,8 @@ var workerCmd = &cobra.Command{
 	Short: "Spin up a worker node",
 	Run: func(cmd *cobra.Command, args []string) {
 		helpers.Banner()
-		w := p2p.NewWorker(port)
+		cfg := loadConfig()
+		w := p2p.NewWorker(cfg)
 		w.Start()
 	},
 }
@@ -0,0 +1,421 @@
+package config
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gizo-network/gizo/cache"
+	"github.com/gizo-network/gizo/core"
+	"github.com/gizo-network/gizo/core/difficulty"
+	"github.com/gizo-network/gizo/core/merkletree"
+	"github.com/gizo-network/gizo/job"
+	"github.com/gizo-network/gizo/job/dag"
+	"github.com/gizo-network/gizo/job/mapreduce"
+	"github.com/gizo-network/gizo/job/queue"
+	"github.com/kpango/glg"
+	yaml "gopkg.in/yaml.v2"
+)
+
+var (
+	ErrInvalidEnv       = errors.New("Config: invalid environment variable")
+	ErrInvalidPort      = errors.New("Config: ports must be between 1 and 65535")
+	ErrInvalidNAT       = errors.New("Config: nat must be none, upnp or natpmp")
+	ErrInvalidDiscovery = errors.New("Config: discovery must be centrum or static")
+	ErrInvalidLimit     = errors.New("Config: limits must be positive")
+	ErrInvalidDuration  = errors.New("Config: durations can't be negative")
+	ErrInvalidTTL       = errors.New("Config: default_max_ttl must be positive")
+)
+
+// nat traversal modes
+const (
+	NATNone = "none"   // no port forwarding, the ip and port are reachable or forwarded manually
+	NATUPnP = "upnp"   // forward through an upnp internet gateway device
+	NATPMP  = "natpmp" // forward through a nat-pmp gateway
+)
+
+// discovery modes
+const (
+	DiscoveryCentrum = "centrum" // dispatchers are looked up and announced through a centrum registry
+	DiscoveryStatic  = "static"  // dispatchers are read from the bootstrap list
+)
+
+//DefaultPath is where the config file is looked up if no path is given
+var DefaultPath = path.Join(os.Getenv("HOME"), ".gizo", "config.yml")
+
+type (
+	//Config holds the settings of a node
+	Config struct {
+		Env        string           `yaml:"env"`         // dev or prod
+		DataDir    string           `yaml:"data_dir"`    // overrides the blockchain and node db directory
+		CentrumURL string           `yaml:"centrum_url"` // url of the centrum service
+		Discovery  string           `yaml:"discovery"`   // centrum or static
+		Bootstrap  []string         `yaml:"bootstrap"`   // dispatcher addresses (gizo://pub@ip:port) used by static discovery
+		Registry   RegistryConfig   `yaml:"registry"`
+		TLS        TLSConfig        `yaml:"tls"`
+		Dispatcher DispatcherConfig `yaml:"dispatcher"`
+		Worker     WorkerConfig     `yaml:"worker"`
+		Chain      ChainConfig      `yaml:"chain"`
+		Job        JobConfig        `yaml:"job"`
+	}
+
+	//DispatcherConfig holds the settings of a dispatcher node
+	DispatcherConfig struct {
+		Port              int             `yaml:"port"`
+		MaxWorkers        int             `yaml:"max_workers"`
+		TargetPeers       int             `yaml:"target_peers"` // neighbours the dispatcher tries to stay connected to
+		ReadBufferSize    int             `yaml:"read_buffer_size"`
+		WriteBufferSize   int             `yaml:"write_buffer_size"`
+		MessageBufferSize int             `yaml:"message_buffer_size"`
+		MaxMessageSize    int64           `yaml:"max_message_size"`
+		IP                string          `yaml:"ip"`          // public ip, overrides the ip detected through nat
+		PublicPort        int             `yaml:"public_port"` // port announced to the network if it differs from port
+		NAT               string          `yaml:"nat"`         // none, upnp or natpmp
+		UPnP              *bool           `yaml:"upnp"`        //! deprecated, use nat - false maps to none if nat isn't set
+		AdminToken        string          `yaml:"admin_token"`
+		AdminInsecure     bool            `yaml:"admin_insecure"` // serves the admin api without tls
+		DrainTimeout      time.Duration   `yaml:"drain_timeout"`  // time running execs are waited for on shutdown
+		HighWater         int             `yaml:"high_water"`     // queued execs past which submissions are rejected, 0 is unlimited
+		RateLimit         RateLimitConfig `yaml:"rate_limit"`
+	}
+
+	//RateLimitConfig holds the rates allowed per remote ip and per pub key on the dispatcher's endpoints
+	RateLimitConfig struct {
+		Connections RateConfig `yaml:"connections"` // websocket connections on /w and /d
+		Messages    RateConfig `yaml:"messages"`    // peer messages from workers and neighbours
+		RPC         RateConfig `yaml:"rpc"`
+	}
+
+	//RateConfig is a token bucket refilled at rate per second up to burst, a zero rate is unlimited
+	RateConfig struct {
+		Rate  float64 `yaml:"rate"`
+		Burst int     `yaml:"burst"`
+	}
+
+	//WorkerConfig holds the settings of a worker node
+	WorkerConfig struct {
+		Port            int `yaml:"port"`
+		ReadBufferSize  int `yaml:"read_buffer_size"`
+		WriteBufferSize int `yaml:"write_buffer_size"`
+	}
+
+	//TLSConfig holds the transport security settings of a node
+	TLSConfig struct {
+		Enabled  bool   `yaml:"enabled"`
+		CertFile string `yaml:"cert_file"` // a self-signed certificate bound to the node key is used if empty
+		KeyFile  string `yaml:"key_file"`
+		CAFile   string `yaml:"ca_file"` // peers are verified against the ca instead of their node key binding
+		Mutual   bool   `yaml:"mutual"`  // requires dispatchers and workers to present a certificate
+	}
+
+	//RegistryConfig holds the settings of a self-hosted centrum registry
+	RegistryConfig struct {
+		Port int `yaml:"port"`
+	}
+
+	//ChainConfig holds blockchain limits
+	ChainConfig struct {
+		MaxTreeJobs int `yaml:"max_tree_jobs"`
+		Blockrate   int `yaml:"blockrate"`
+	}
+
+	//JobConfig holds job limits
+	JobConfig struct {
+		MaxExecs      int            `yaml:"max_execs"`
+		MaxDAGExecs   int            `yaml:"max_dag_execs"`
+		MaxMapExecs   int            `yaml:"max_map_execs"` // mapper execs of a map/reduce
+		CacheSize     int            `yaml:"cache_size"`    // jobs held in the job cache
+		DefaultMaxTTL time.Duration  `yaml:"default_max_ttl"`
+		AgingRate     time.Duration  `yaml:"aging_rate"`    // time queued execs wait to rise a priority level, 0 disables aging
+		MaxWait       time.Duration  `yaml:"max_wait"`      // time queued execs wait before they're popped ahead of any priority
+		DedupeWindow  time.Duration  `yaml:"dedupe_window"` // time resubmissions of an idempotency key return the first submission, 0 disables dedupe
+		Quota         QuotaConfig    `yaml:"quota"`
+		Weights       map[string]int `yaml:"weights"` // execs popped per round keyed by submitter pub, submitters default to 1
+	}
+
+	//QuotaConfig holds the limits applied to the execs of each submitter, a zero limit is unlimited
+	QuotaConfig struct {
+		MaxQueued  int           `yaml:"max_queued"`
+		MaxRunning int           `yaml:"max_running"`
+		MaxCPU     time.Duration `yaml:"max_cpu"` // exec time used per window
+		Window     time.Duration `yaml:"window"`
+	}
+)
+
+//Default returns the config used when no file or overrides are given
+func Default() *Config {
+	return &Config{
+		Env:        "prod",
+		CentrumURL: "https://f3482d64.ngrok.io",
+		Discovery:  DiscoveryCentrum,
+		Dispatcher: DispatcherConfig{
+			Port:              9999,
+			MaxWorkers:        128,
+			TargetPeers:       8,
+			ReadBufferSize:    100000,
+			WriteBufferSize:   100000,
+			MessageBufferSize: 100000,
+			MaxMessageSize:    100000,
+			NAT:               NATUPnP,
+			DrainTimeout:      time.Second * 30,
+			HighWater:         10000,
+			RateLimit: RateLimitConfig{
+				Connections: RateConfig{Rate: 1, Burst: 10},
+				Messages:    RateConfig{Rate: 100, Burst: 500},
+				RPC:         RateConfig{Rate: 10, Burst: 50},
+			},
+		},
+		Worker: WorkerConfig{
+			Port:            9998,
+			ReadBufferSize:  10000,
+			WriteBufferSize: 10000,
+		},
+		Registry: RegistryConfig{
+			Port: 9997,
+		},
+		Chain: ChainConfig{
+			MaxTreeJobs: 128,
+			Blockrate:   15,
+		},
+		Job: JobConfig{
+			MaxExecs:      10,
+			MaxDAGExecs:   1000,
+			MaxMapExecs:   10000,
+			CacheSize:     128,
+			DefaultMaxTTL: time.Minute * 10,
+			AgingRate:     time.Minute * 2,
+			MaxWait:       time.Minute * 15,
+			DedupeWindow:  time.Hour * 24,
+			Quota: QuotaConfig{
+				Window: time.Hour,
+			},
+		},
+	}
+}
+
+//Override changes settings of a loaded config, such as ones set by command line flags
+type Override func(c *Config)
+
+//Load returns the default config overridden by the config file at p, environment variables and overrides in that order,
+//the config is validated once every override is applied
+func Load(p string, overrides ...Override) (*Config, error) {
+	c := Default()
+	if p == "" {
+		p = DefaultPath
+		if _, err := os.Stat(p); os.IsNotExist(err) {
+			p = ""
+		}
+	}
+	if p != "" {
+		glg.Info("Config: loading " + p)
+		b, err := ioutil.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		if err = yaml.Unmarshal(b, c); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.loadEnv(); err != nil {
+		return nil, err
+	}
+	for _, override := range overrides {
+		override(c)
+	}
+	c.migrate()
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+//maps deprecated settings to the ones that replaced them
+func (c *Config) migrate() {
+	if c.Dispatcher.UPnP != nil {
+		glg.Warn("Config: upnp is deprecated, set nat to upnp, natpmp or none instead")
+		//! nat takes precedence once it's changed from the default
+		if !*c.Dispatcher.UPnP && c.Dispatcher.NAT == Default().Dispatcher.NAT {
+			c.Dispatcher.NAT = NATNone
+		}
+	}
+}
+
+//Validate returns an error if a setting is outside the range the node can run with
+func (c Config) Validate() error {
+	for _, port := range []int{c.Dispatcher.Port, c.Worker.Port, c.Registry.Port} {
+		if port < 1 || port > 65535 {
+			return ErrInvalidPort
+		}
+	}
+	if c.Dispatcher.PublicPort < 0 || c.Dispatcher.PublicPort > 65535 {
+		return ErrInvalidPort
+	}
+	switch c.Dispatcher.NAT {
+	case NATNone, NATUPnP, NATPMP:
+	default:
+		return ErrInvalidNAT
+	}
+	switch c.Discovery {
+	case DiscoveryCentrum, DiscoveryStatic:
+	default:
+		return ErrInvalidDiscovery
+	}
+	for _, limit := range []int{c.Dispatcher.MaxWorkers, c.Chain.MaxTreeJobs, c.Chain.Blockrate, c.Job.MaxExecs, c.Job.MaxDAGExecs, c.Job.MaxMapExecs, c.Job.CacheSize} {
+		if limit <= 0 {
+			return ErrInvalidLimit
+		}
+	}
+	for _, limit := range []int{c.Dispatcher.TargetPeers, c.Dispatcher.HighWater, c.Job.Quota.MaxQueued, c.Job.Quota.MaxRunning} {
+		if limit < 0 {
+			return ErrInvalidLimit
+		}
+	}
+	if c.Job.DefaultMaxTTL <= 0 {
+		return ErrInvalidTTL
+	}
+	for _, d := range []time.Duration{c.Dispatcher.DrainTimeout, c.Job.AgingRate, c.Job.MaxWait, c.Job.DedupeWindow, c.Job.Quota.MaxCPU, c.Job.Quota.Window} {
+		if d < 0 {
+			return ErrInvalidDuration
+		}
+	}
+	return nil
+}
+
+//overrides settings with GIZO_* environment variables
+func (c *Config) loadEnv() error {
+	if env := os.Getenv("ENV"); env != "" {
+		c.Env = env // kept for nodes started with ENV=dev
+	}
+	strs := map[string]*string{
+		"GIZO_ENV":         &c.Env,
+		"GIZO_DATA_DIR":    &c.DataDir,
+		"GIZO_CENTRUM_URL": &c.CentrumURL,
+		"GIZO_DISCOVERY":   &c.Discovery,
+		"GIZO_ADMIN_TOKEN": &c.Dispatcher.AdminToken,
+		"GIZO_IP":          &c.Dispatcher.IP,
+		"GIZO_NAT":         &c.Dispatcher.NAT,
+		"GIZO_TLS_CERT":    &c.TLS.CertFile,
+		"GIZO_TLS_KEY":     &c.TLS.KeyFile,
+		"GIZO_TLS_CA":      &c.TLS.CAFile,
+	}
+	for key, val := range strs {
+		if env := os.Getenv(key); env != "" {
+			*val = env
+		}
+	}
+	ints := map[string]*int{
+		"GIZO_DISPATCHER_PORT": &c.Dispatcher.Port,
+		"GIZO_PUBLIC_PORT":     &c.Dispatcher.PublicPort,
+		"GIZO_MAX_WORKERS":     &c.Dispatcher.MaxWorkers,
+		"GIZO_TARGET_PEERS":    &c.Dispatcher.TargetPeers,
+		"GIZO_HIGH_WATER":      &c.Dispatcher.HighWater,
+		"GIZO_WORKER_PORT":     &c.Worker.Port,
+		"GIZO_REGISTRY_PORT":   &c.Registry.Port,
+		"GIZO_MAX_TREE_JOBS":   &c.Chain.MaxTreeJobs,
+		"GIZO_BLOCKRATE":       &c.Chain.Blockrate,
+		"GIZO_MAX_EXECS":       &c.Job.MaxExecs,
+		"GIZO_MAX_DAG_EXECS":   &c.Job.MaxDAGExecs,
+		"GIZO_MAX_MAP_EXECS":   &c.Job.MaxMapExecs,
+		"GIZO_JOB_CACHE_SIZE":  &c.Job.CacheSize,
+		"GIZO_QUOTA_QUEUED":    &c.Job.Quota.MaxQueued,
+		"GIZO_QUOTA_RUNNING":   &c.Job.Quota.MaxRunning,
+	}
+	for key, val := range ints {
+		if env := os.Getenv(key); env != "" {
+			i, err := strconv.Atoi(env)
+			if err != nil {
+				return ErrInvalidEnv
+			}
+			*val = i
+		}
+	}
+	bools := map[string]*bool{
+		"GIZO_TLS":            &c.TLS.Enabled,
+		"GIZO_TLS_MUTUAL":     &c.TLS.Mutual,
+		"GIZO_ADMIN_INSECURE": &c.Dispatcher.AdminInsecure,
+	}
+	for key, val := range bools {
+		if env := os.Getenv(key); env != "" {
+			b, err := strconv.ParseBool(env)
+			if err != nil {
+				return ErrInvalidEnv
+			}
+			*val = b
+		}
+	}
+	if env := os.Getenv("GIZO_UPNP"); env != "" {
+		b, err := strconv.ParseBool(env)
+		if err != nil {
+			return ErrInvalidEnv
+		}
+		c.Dispatcher.UPnP = &b
+	}
+	if env := os.Getenv("GIZO_BOOTSTRAP"); env != "" {
+		c.Bootstrap = strings.Split(env, ",")
+	}
+	durations := map[string]*time.Duration{
+		"GIZO_DEFAULT_MAX_TTL": &c.Job.DefaultMaxTTL,
+		"GIZO_DRAIN_TIMEOUT":   &c.Dispatcher.DrainTimeout,
+		"GIZO_AGING_RATE":      &c.Job.AgingRate,
+		"GIZO_MAX_WAIT":        &c.Job.MaxWait,
+		"GIZO_DEDUPE_WINDOW":   &c.Job.DedupeWindow,
+		"GIZO_QUOTA_CPU":       &c.Job.Quota.MaxCPU,
+		"GIZO_QUOTA_WINDOW":    &c.Job.Quota.Window,
+	}
+	for key, val := range durations {
+		if env := os.Getenv(key); env != "" {
+			d, err := time.ParseDuration(env)
+			if err != nil {
+				return ErrInvalidEnv
+			}
+			*val = d
+		}
+	}
+	return nil
+}
+
+//IsDev returns true if the node runs in the dev environment
+func (c Config) IsDev() bool {
+	return c.Env == "dev"
+}
+
+//GetDataDir returns the directory the blockchain and node databases are saved in, defaulting to the environment's
+func (c Config) GetDataDir() string {
+	if c.DataDir != "" {
+		return c.DataDir
+	}
+	if c.IsDev() {
+		return core.IndexPathDev
+	}
+	return core.IndexPathProd
+}
+
+//Apply sets the data path and limits used by the core and job packages, it's called once after Load
+//since the packages read them as globals
+func (c Config) Apply() {
+	core.SetDataDir(c.GetDataDir())
+	merkletree.MaxTreeJobs = c.Chain.MaxTreeJobs
+	difficulty.Blockrate = c.Chain.Blockrate
+	job.MaxExecs = c.Job.MaxExecs
+	dag.MaxExecs = c.Job.MaxDAGExecs
+	mapreduce.MaxExecs = c.Job.MaxMapExecs
+	cache.MaxCacheLen = c.Job.CacheSize
+	job.DefaultMaxTTL = c.Job.DefaultMaxTTL
+	queue.AgingRate = c.Job.AgingRate
+	queue.MaxWait = c.Job.MaxWait
+	queue.DedupeWindow = c.Job.DedupeWindow
+	queue.HighWater = c.Dispatcher.HighWater
+	queue.DefaultQuota = queue.Quota{
+		MaxQueued:  c.Job.Quota.MaxQueued,
+		MaxRunning: c.Job.Quota.MaxRunning,
+		MaxCPU:     c.Job.Quota.MaxCPU,
+		Window:     c.Job.Quota.Window,
+	}
+	if c.Job.Weights != nil {
+		queue.Weights = c.Job.Weights
+	}
+}
@@ -0,0 +1,106 @@
+package config_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/gizo-network/gizo/config"
+	"github.com/gizo-network/gizo/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gizo-config")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	p := path.Join(dir, "config.yml")
+	err = ioutil.WriteFile(p, []byte("dispatcher:\n  port: 7000\n  nat: none\njob:\n  max_execs: 4\n"), 0644)
+	assert.NoError(t, err)
+
+	c, err := config.Load(p)
+	assert.NoError(t, err)
+	assert.Equal(t, 7000, c.Dispatcher.Port)
+	assert.Equal(t, config.NATNone, c.Dispatcher.NAT)
+	assert.Equal(t, 4, c.Job.MaxExecs)
+	assert.Equal(t, config.Default().Worker.Port, c.Worker.Port)
+
+	os.Setenv("GIZO_DISPATCHER_PORT", "7001")
+	os.Setenv("GIZO_DEFAULT_MAX_TTL", "1m")
+	defer os.Unsetenv("GIZO_DISPATCHER_PORT")
+	defer os.Unsetenv("GIZO_DEFAULT_MAX_TTL")
+	c, err = config.Load(p)
+	assert.NoError(t, err)
+	assert.Equal(t, 7001, c.Dispatcher.Port)
+	assert.Equal(t, time.Minute, c.Job.DefaultMaxTTL)
+
+	//! overrides take precedence over the environment and are validated
+	c, err = config.Load(p, func(c *config.Config) {
+		c.Dispatcher.Port = 7002
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 7002, c.Dispatcher.Port)
+	_, err = config.Load(p, func(c *config.Config) {
+		c.Dispatcher.Port = 70000
+	})
+	assert.Equal(t, config.ErrInvalidPort, err)
+
+	os.Setenv("GIZO_DISPATCHER_PORT", "port")
+	_, err = config.Load(p)
+	assert.Equal(t, config.ErrInvalidEnv, err)
+}
+
+func TestValidate(t *testing.T) {
+	c := config.Default()
+	assert.NoError(t, c.Validate())
+
+	c.Job.DefaultMaxTTL = 0
+	assert.Equal(t, config.ErrInvalidTTL, c.Validate())
+
+	c = config.Default()
+	c.Dispatcher.NAT = "upnpp"
+	assert.Equal(t, config.ErrInvalidNAT, c.Validate())
+
+	c = config.Default()
+	c.Job.MaxExecs = 0
+	assert.Equal(t, config.ErrInvalidLimit, c.Validate())
+
+	c = config.Default()
+	c.Dispatcher.Port = 70000
+	assert.Equal(t, config.ErrInvalidPort, c.Validate())
+}
+
+func TestGetDataDir(t *testing.T) {
+	c := config.Default()
+	assert.Equal(t, core.IndexPathProd, c.GetDataDir())
+	c.Env = "dev"
+	assert.Equal(t, core.IndexPathDev, c.GetDataDir())
+	c.DataDir = "/tmp/gizo"
+	assert.Equal(t, "/tmp/gizo", c.GetDataDir())
+}
+
+func TestDeprecatedUPnP(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gizo-config")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	p := path.Join(dir, "config.yml")
+
+	assert.NoError(t, ioutil.WriteFile(p, []byte("dispatcher:\n  upnp: false\n"), 0644))
+	c, err := config.Load(p)
+	assert.NoError(t, err)
+	assert.Equal(t, config.NATNone, c.Dispatcher.NAT)
+
+	assert.NoError(t, ioutil.WriteFile(p, []byte("dispatcher:\n  upnp: false\n  nat: natpmp\n"), 0644))
+	c, err = config.Load(p)
+	assert.NoError(t, err)
+	assert.Equal(t, config.NATPMP, c.Dispatcher.NAT)
+
+	assert.NoError(t, ioutil.WriteFile(p, []byte("dispatcher:\n  port: 7000\n"), 0644))
+	os.Setenv("GIZO_UPNP", "false")
+	defer os.Unsetenv("GIZO_UPNP")
+	c, err = config.Load(p)
+	assert.NoError(t, err)
+	assert.Equal(t, config.NATNone, c.Dispatcher.NAT)
+}
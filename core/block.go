@@ -13,6 +13,7 @@ import (
 
 	"github.com/gizo-network/gizo/core/merkletree"
 	"github.com/gizo-network/gizo/helpers"
+	"github.com/gizo-network/gizo/metrics"
 
 	"github.com/kpango/glg"
 )
@@ -75,7 +76,9 @@ func NewBlock(tree merkletree.MerkleTree, pHash []byte, height uint64, difficult
 		By:     by,
 	}
 	pow := NewPOW(block)
+	start := time.Now()
 	pow.run() //! mines block
+	metrics.ObservePOW(int64(difficulty), time.Now().Sub(start).Seconds())
 	err := block.Export()
 	if err != nil {
 		glg.Fatal(err)
@@ -92,11 +95,7 @@ func (b Block) Export() error {
 	}
 	bBytes := b.Serialize()
 	var err error
-	if os.Getenv("ENV") == "dev" {
-		err = ioutil.WriteFile(path.Join(BlockPathDev, fmt.Sprintf(BlockFile, hex.EncodeToString(b.Header.GetHash()))), []byte(helpers.Encode64(bBytes)), os.FileMode(0555))
-	} else {
-		err = ioutil.WriteFile(path.Join(BlockPathProd, fmt.Sprintf(BlockFile, hex.EncodeToString(b.Header.GetHash()))), []byte(helpers.Encode64(bBytes)), os.FileMode(0555))
-	}
+	err = ioutil.WriteFile(path.Join(BlockPath(), fmt.Sprintf(BlockFile, hex.EncodeToString(b.Header.GetHash()))), []byte(helpers.Encode64(bBytes)), os.FileMode(0555))
 	if err != nil {
 		glg.Fatal(err)
 	}
@@ -111,11 +110,7 @@ func (b *Block) Import(hash []byte) {
 	}
 	var read []byte
 	var err error
-	if os.Getenv("ENV") == "dev" {
-		read, err = ioutil.ReadFile(path.Join(BlockPathDev, fmt.Sprintf(BlockFile, hex.EncodeToString(hash))))
-	} else {
-		read, err = ioutil.ReadFile(path.Join(BlockPathProd, fmt.Sprintf(BlockFile, hex.EncodeToString(hash))))
-	}
+	read, err = ioutil.ReadFile(path.Join(BlockPath(), fmt.Sprintf(BlockFile, hex.EncodeToString(hash))))
 	if err != nil {
 		glg.Fatal(err) //FIXME: handle block doesn't exist by asking peer
 	}
@@ -133,11 +128,7 @@ func (b *Block) Import(hash []byte) {
 func (b Block) fileStats() os.FileInfo {
 	var info os.FileInfo
 	var err error
-	if os.Getenv("ENV") == "dev" {
-		info, err = os.Stat(path.Join(BlockPathDev, fmt.Sprintf(BlockFile, hex.EncodeToString(b.Header.GetHash()))))
-	} else {
-		info, err = os.Stat(path.Join(BlockPathProd, fmt.Sprintf(BlockFile, hex.EncodeToString(b.Header.GetHash()))))
-	}
+	info, err = os.Stat(path.Join(BlockPath(), fmt.Sprintf(BlockFile, hex.EncodeToString(b.Header.GetHash()))))
 	if os.IsNotExist(err) {
 		glg.Fatal("Block file doesn't exist")
 	}
@@ -179,11 +170,7 @@ func (b *Block) VerifyBlock() bool {
 func (b Block) DeleteFile() {
 	glg.Info("Core: Deleting blockfile - " + hex.EncodeToString(b.GetHeader().GetHash()))
 	var err error
-	if os.Getenv("ENV") == "dev" {
-		err = os.Remove(path.Join(BlockPathDev, b.fileStats().Name()))
-	} else {
-		err = os.Remove(path.Join(BlockPathProd, b.fileStats().Name()))
-	}
+	err = os.Remove(path.Join(BlockPath(), b.fileStats().Name()))
 	if err != nil {
 		glg.Fatal(err)
 	}
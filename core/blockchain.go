@@ -4,7 +4,6 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
-	"os"
 	"path"
 	"sync"
 	"time"
@@ -13,6 +12,7 @@ import (
 
 	"github.com/gizo-network/gizo/helpers"
 	"github.com/gizo-network/gizo/job"
+	"github.com/gizo-network/gizo/metrics"
 
 	"github.com/gizo-network/gizo/core/merkletree"
 
@@ -25,14 +25,16 @@ import (
 var (
 	ErrUnverifiedBlock = errors.New("Unverified block cannot be added to the blockchain")
 	ErrJobNotFound     = errors.New("Job not found")
+	ErrExecNotFound    = errors.New("Exec not found")
 	ErrBlockNotFound   = errors.New("Blockinfo not found")
 )
 
 //BlockChain - singly linked list of blocks
 type BlockChain struct {
-	tip []byte //! hash of latest block in the blockchain
-	db  *bolt.DB
-	mu  *sync.RWMutex
+	tip       []byte //! hash of latest block in the blockchain
+	db        *bolt.DB
+	mu        *sync.RWMutex
+	listeners []func(Block) // called with each block added
 }
 
 //returns the blockinfo of the latest block in the blockchain
@@ -169,12 +171,20 @@ func (bc BlockChain) GetNextHeight() uint64 {
 	return bc.GetLatestBlock().GetHeight() + 1
 }
 
+//OnBlock registers f to be called with each block added to the blockchain
+func (bc *BlockChain) OnBlock(f func(Block)) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	bc.listeners = append(bc.listeners, f)
+}
+
 //AddBlock adds block to the blockchain
 func (bc *BlockChain) AddBlock(block *Block) error {
 	glg.Info("Core: Adding block to the blockchain - " + hex.EncodeToString(block.GetHeader().GetHash()))
 	if block.VerifyBlock() == false {
 		return ErrUnverifiedBlock
 	}
+	added := false
 	err := bc.getDB().Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(BlockBucket))
 		inDb := b.Get(block.Header.GetHash())
@@ -194,6 +204,8 @@ func (bc *BlockChain) AddBlock(block *Block) error {
 		if err := b.Put(block.GetHeader().GetHash(), blockinfo.Serialize()); err != nil {
 			glg.Fatal(err)
 		}
+		metrics.BlocksAdded.Inc()
+		added = true
 
 		//FIXME: handle a fork
 		latest, err := bc.GetBlockInfo(bc.getTip())
@@ -205,12 +217,21 @@ func (bc *BlockChain) AddBlock(block *Block) error {
 				glg.Fatal(err)
 			}
 			bc.setTip(block.GetHeader().GetHash())
+			metrics.ChainHeight.Set(float64(block.GetHeight()))
 		}
 		return nil
 	})
 	if err != nil {
 		glg.Fatal(err)
 	}
+	if added {
+		bc.mu.RLock()
+		listeners := bc.listeners
+		bc.mu.RUnlock()
+		for _, f := range listeners {
+			f(*block)
+		}
+	}
 	return nil
 }
 
@@ -247,6 +268,27 @@ func (bc *BlockChain) FindJob(id string) (*job.Job, error) {
 	}
 }
 
+//FindIdempotentExec returns the latest exec submitted by submitter with an idempotency key in blocks created after since
+//and the id of its job
+func (bc *BlockChain) FindIdempotentExec(submitter, key string, since int64) (*job.Exec, string, error) {
+	glg.Info("Core: Finding exec in the blockchain - " + key)
+	bci := bc.iterator()
+	for {
+		block := bci.Next()
+		if block.GetHeight() == 0 || block.GetHeader().GetTimestamp() < since {
+			return nil, "", ErrExecNotFound
+		}
+		for _, node := range block.GetNodes() {
+			execs := node.GetJob().GetExecs()
+			for i := len(execs) - 1; i >= 0; i-- {
+				if execs[i].GetIdempotencyKey() == key && execs[i].GetSubmitter() == submitter {
+					return &execs[i], node.GetJob().GetID(), nil
+				}
+			}
+		}
+	}
+}
+
 //FindMerkleNode returns the merklenode from the blockchain
 func (bc *BlockChain) FindMerkleNode(h []byte) (*merkletree.MerkleNode, error) {
 	//FIXME: speed up
@@ -309,16 +351,81 @@ func (bc *BlockChain) GetBlockHashesHex() []string {
 	return funk.Reverse(hashes).([]string)
 }
 
+//HasBlock returns true if the block is in the blockchain
+func (bc *BlockChain) HasBlock(hash []byte) bool {
+	var found bool
+	err := bc.getDB().View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(BlockBucket))
+		found = b.Get(hash) != nil
+		return nil
+	})
+	if err != nil {
+		glg.Fatal(err)
+	}
+	return found
+}
+
+//GetLocator returns block hashes from the tip back to the genesis block, dense for the latest LocatorDense blocks then spaced exponentially
+func (bc *BlockChain) GetLocator() [][]byte {
+	var locator [][]byte
+	bci := bc.iterator()
+	info := bci.NextBlockinfo()
+	next := info.GetHeight()
+	step := uint64(1)
+	for {
+		if info.GetHeight() == next || info.GetHeight() == 0 {
+			locator = append(locator, info.GetHeader().GetHash())
+			if len(locator) >= LocatorDense {
+				step *= 2
+			}
+			if next < step {
+				next = 0
+			} else {
+				next -= step
+			}
+		}
+		if info.GetHeight() == 0 {
+			break
+		}
+		info = bci.NextBlockinfo()
+	}
+	return locator
+}
+
+//GetBlockInfosAfter returns up to max blockinfos following the latest locator hash in the blockchain, oldest first.
+//Blockinfos are returned from the genesis block if none of the hashes are known
+func (bc *BlockChain) GetBlockInfosAfter(locator [][]byte, max int) []BlockInfo {
+	known := make(map[string]bool)
+	for _, hash := range locator {
+		known[hex.EncodeToString(hash)] = true
+	}
+	var infos []BlockInfo
+	bci := bc.iterator()
+	for {
+		info := bci.NextBlockinfo()
+		if known[hex.EncodeToString(info.GetHeader().GetHash())] {
+			break
+		}
+		//! walking back from the tip, only the max blockinfos closest to the locator are kept
+		if len(infos) == max {
+			infos = infos[1:]
+		}
+		infos = append(infos, *info)
+		if info.GetHeight() == 0 {
+			break
+		}
+	}
+	for i, j := 0, len(infos)-1; i < j; i, j = i+1, j-1 {
+		infos[i], infos[j] = infos[j], infos[i]
+	}
+	return infos
+}
+
 //CreateBlockChain initializes a db, set's the tip to GenesisBlock and returns the blockchain
 func CreateBlockChain(nodeID string) *BlockChain {
 	glg.Info("Core: Creating blockchain database")
 	InitializeDataPath()
-	var dbFile string
-	if os.Getenv("ENV") == "dev" {
-		dbFile = path.Join(IndexPathDev, fmt.Sprintf(IndexDB, nodeID[len(nodeID)/2:])) //half the length of the node id
-	} else {
-		dbFile = path.Join(IndexPathProd, fmt.Sprintf(IndexDB, nodeID[len(nodeID)/2:])) //half the length of the node id
-	}
+	dbFile := path.Join(IndexPath(), fmt.Sprintf(IndexDB, nodeID[len(nodeID)/2:])) //half the length of the node id
 	if helpers.FileExists(dbFile) {
 		var tip []byte
 		glg.Warn("Core: Using existing blockchain")
@@ -176,6 +176,38 @@ func TestGetBlockHashes(t *testing.T) {
 	assert.NotNil(t, bc.GetBlockHashes())
 }
 
+func TestGetBlockInfosAfter(t *testing.T) {
+	os.Setenv("ENV", "dev")
+	RemoveDataPath()
+	priv, _ := crypt.GenKeys()
+	j := job.NewJob("func test(){return 1+1}", "test", false, hex.EncodeToString(priv))
+	node1 := merkletree.NewNode(*j, &merkletree.MerkleNode{}, &merkletree.MerkleNode{})
+	node2 := merkletree.NewNode(*j, &merkletree.MerkleNode{}, &merkletree.MerkleNode{})
+
+	nodes := []*merkletree.MerkleNode{node1, node2}
+	tree := merkletree.NewMerkleTree(nodes)
+	bc := CreateBlockChain("test")
+	genesis := bc.GetPrevHash()
+	block1 := NewBlock(*tree, bc.GetPrevHash(), bc.GetNextHeight(), 10, "test")
+	bc.AddBlock(block1)
+	block2 := NewBlock(*tree, bc.GetPrevHash(), bc.GetNextHeight(), 10, "test")
+	bc.AddBlock(block2)
+
+	assert.Len(t, bc.GetLocator(), 3)
+	assert.True(t, bc.HasBlock(block2.GetHeader().GetHash()))
+
+	infos := bc.GetBlockInfosAfter([][]byte{genesis}, 10)
+	assert.Len(t, infos, 2)
+	assert.Equal(t, block1.GetHeader().GetHash(), infos[0].GetHeader().GetHash())
+	assert.Equal(t, block2.GetHeader().GetHash(), infos[1].GetHeader().GetHash())
+
+	assert.Len(t, bc.GetBlockInfosAfter(bc.GetLocator(), 10), 0)
+	infos = bc.GetBlockInfosAfter([][]byte{[]byte("unknown")}, 2)
+	assert.Len(t, infos, 2)
+	assert.Equal(t, genesis, infos[0].GetHeader().GetHash())
+	assert.Equal(t, block1.GetHeader().GetHash(), infos[1].GetHeader().GetHash())
+}
+
 func TestCreateBlockChain(t *testing.T) {
 	os.Setenv("ENV", "dev")
 	RemoveDataPath()
@@ -17,6 +17,31 @@ var BlockPathDev = path.Join(os.Getenv("HOME"), ".gizo-dev", "blocks")
 //IndexPathDev is the path database files are saved on the disk for development
 var IndexPathDev = path.Join(os.Getenv("HOME"), ".gizo-dev")
 
+//directory set by the node's config, the environment's default is used if empty
+var dataDir string
+
+//SetDataDir sets the directory the blockchain and node databases are saved in
+func SetDataDir(dir string) {
+	dataDir = dir
+}
+
+//IndexPath returns the directory database files are saved in
+func IndexPath() string {
+	if dataDir != "" {
+		return dataDir
+	}
+	//! nodes that don't load a config (tests) pick the directory from ENV
+	if os.Getenv("ENV") == "dev" {
+		return IndexPathDev
+	}
+	return IndexPathProd
+}
+
+//BlockPath returns the directory block files are saved in
+func BlockPath() string {
+	return path.Join(IndexPath(), "blocks")
+}
+
 //BlockFile is the format of block filenames
 const BlockFile = "%s.blk"
 
@@ -25,3 +50,6 @@ const BlockBucket = "blocks"
 
 //IndexDB is the database file for the node
 const IndexDB = "bc_%s.db" // node id
+
+//LocatorDense is the number of latest blocks included one by one in a locator
+const LocatorDense = 10
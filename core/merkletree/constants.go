@@ -1,4 +1,4 @@
 package merkletree
 
 // MaxTreeJobs - number of jobs in a block
-const MaxTreeJobs = 128
+var MaxTreeJobs = 128
@@ -0,0 +1,43 @@
+package crypt
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"math/big"
+)
+
+//Sign returns the signature of the sha256 hash of data
+func Sign(priv, data []byte) ([][]byte, error) {
+	privateKey, err := x509.ParseECPrivateKey(priv)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(data)
+	r, s, err := ecdsa.Sign(rand.Reader, privateKey, hash[:])
+	if err != nil {
+		return nil, err
+	}
+	return [][]byte{r.Bytes(), s.Bytes()}, nil
+}
+
+//Verify returns true if sig is a signature of data made with the private key of pub
+func Verify(pub, data []byte, sig [][]byte) bool {
+	if len(sig) != 2 {
+		return false
+	}
+	publicKey, err := x509.ParsePKIXPublicKey(pub)
+	if err != nil {
+		return false
+	}
+	pubConv, ok := publicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return false
+	}
+	var r, s big.Int
+	r.SetBytes(sig[0])
+	s.SetBytes(sig[1])
+	hash := sha256.Sum256(data)
+	return ecdsa.Verify(pubConv, hash[:], &r, &s)
+}
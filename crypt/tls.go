@@ -0,0 +1,109 @@
+package crypt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"time"
+)
+
+//NodeKeyOID identifies the certificate extension binding a tls key to a node key
+var NodeKeyOID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 54392, 1, 1}
+
+//NodeCertValidity is how long self-signed node certificates are valid for
+const NodeCertValidity = time.Hour * 24 * 365
+
+var (
+	ErrNoNodeKey      = errors.New("Crypt: certificate is not bound to a node key")
+	ErrInvalidNodeKey = errors.New("Crypt: invalid node key binding")
+	ErrCertExpired    = errors.New("Crypt: certificate expired or not yet valid")
+)
+
+//nodeKeyBinding is the node's signature over the certificate's public key
+type nodeKeyBinding struct {
+	Pub []byte
+	R   *big.Int
+	S   *big.Int
+}
+
+//NewNodeCertificate returns a self-signed tls certificate whose key is signed by the node key
+func NewNodeCertificate(priv, pub []byte) (tls.Certificate, error) {
+	nodeKey, err := x509.ParseECPrivateKey(priv)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	//! node keys use P224 which tls doesn't support, so the certificate gets its own key signed by the node key
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	spki, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	hash := sha256.Sum256(spki)
+	r, s, err := ecdsa.Sign(rand.Reader, nodeKey, hash[:])
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	binding, err := asn1.Marshal(nodeKeyBinding{Pub: pub, R: r, S: s})
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	template := x509.Certificate{
+		SerialNumber:    serial,
+		Subject:         pkix.Name{CommonName: "gizo node"},
+		NotBefore:       time.Now().Add(-time.Hour),
+		NotAfter:        time.Now().Add(NodeCertValidity),
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		ExtraExtensions: []pkix.Extension{{Id: NodeKeyOID, Value: binding}},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+//NodeKey returns the node key a certificate is bound to
+func NodeKey(cert *x509.Certificate) ([]byte, error) {
+	now := time.Now()
+	if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		return nil, ErrCertExpired
+	}
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(NodeKeyOID) {
+			continue
+		}
+		var binding nodeKeyBinding
+		if _, err := asn1.Unmarshal(ext.Value, &binding); err != nil {
+			return nil, ErrInvalidNodeKey
+		}
+		pubKey, err := x509.ParsePKIXPublicKey(binding.Pub)
+		if err != nil {
+			return nil, ErrInvalidNodeKey
+		}
+		ecPub, ok := pubKey.(*ecdsa.PublicKey)
+		if !ok {
+			return nil, ErrInvalidNodeKey
+		}
+		hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+		if !ecdsa.Verify(ecPub, hash[:], binding.R, binding.S) {
+			return nil, ErrInvalidNodeKey
+		}
+		return binding.Pub, nil
+	}
+	return nil, ErrNoNodeKey
+}
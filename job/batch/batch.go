@@ -11,19 +11,23 @@ import (
 	"github.com/gizo-network/gizo/job"
 	"github.com/gizo-network/gizo/job/queue"
 	"github.com/gizo-network/gizo/job/queue/qItem"
+	"github.com/gizo-network/gizo/job/workflow"
 	"github.com/kpango/glg"
 )
 
 //Batch - Jobs executed in parralele
 type Batch struct {
-	jobs   []job.JobRequestMultiple
-	bc     *core.BlockChain
-	pq     *queue.JobPriorityQueue
-	jc     *cache.JobCache
-	result []job.JobRequestMultiple
-	length int
-	status string
-	cancel chan struct{}
+	jobs          []job.JobRequestMultiple
+	bc            *core.BlockChain
+	pq            *queue.JobPriorityQueue
+	jc            *cache.JobCache
+	result        []job.JobRequestMultiple
+	length        int
+	status        string
+	policy        workflow.Policy
+	compensations job.JobRequestMultiple
+	tracker       *workflow.Tracker
+	cancel        chan struct{}
 }
 
 //NewBatch returns batch
@@ -36,13 +40,16 @@ func NewBatch(j []job.JobRequestMultiple, bc *core.BlockChain, pq *queue.JobPrio
 		return nil, job.ErrJobsLenRange
 	}
 	b := &Batch{
-		jobs:   j,
-		bc:     bc,
-		pq:     pq,
-		jc:     jc,
-		length: length,
-		cancel: make(chan struct{}),
+		jobs:    j,
+		bc:      bc,
+		pq:      pq,
+		jc:      jc,
+		length:  length,
+		policy:  workflow.DefaultPolicy(),
+		tracker: workflow.NewTracker("batch"),
+		cancel:  make(chan struct{}),
 	}
+	b.tracker.AddSteps(j)
 
 	return b, nil
 }
@@ -55,6 +62,40 @@ func (b Batch) GetCancelChan() chan struct{} {
 	return b.cancel
 }
 
+//GetID returns the workflow id
+func (b Batch) GetID() string {
+	return b.tracker.GetID()
+}
+
+//GetRecord returns the workflow record
+func (b Batch) GetRecord() workflow.Record {
+	return b.tracker.GetRecord()
+}
+
+//SetOwner sets the pub of the client that submitted the batch
+func (b *Batch) SetOwner(pub string) {
+	b.tracker.SetOwner(pub)
+}
+
+//SetStore persists the workflow record in store
+func (b *Batch) SetStore(s *workflow.Store) {
+	b.tracker.SetStore(s)
+}
+
+//SetPolicy sets what the batch does when an exec fails
+func (b *Batch) SetPolicy(p workflow.Policy) {
+	b.policy = p
+}
+
+func (b Batch) GetPolicy() workflow.Policy {
+	return b.policy
+}
+
+//GetCompensations returns the execs of the on-failure job
+func (b Batch) GetCompensations() job.JobRequestMultiple {
+	return b.compensations
+}
+
 //GetJobs return jobs
 func (b Batch) GetJobs() []job.JobRequestMultiple {
 	return b.jobs
@@ -107,6 +148,7 @@ func (b *Batch) Dispatch() {
 	//! should be run in a go routine because it blocks till all jobs are complete
 	var items []qItem.Item
 	b.setStatus(job.RUNNING)
+	b.tracker.Start()
 	cancelled := false
 	closeCancel := make(chan struct{})
 	var wg sync.WaitGroup
@@ -139,17 +181,16 @@ func (b *Batch) Dispatch() {
 	var sleepWG sync.WaitGroup
 	for _, jr := range b.GetJobs() {
 		b.setStatus("Queueing execs of job - " + jr.GetID())
+		b.tracker.Queueing(jr.GetID())
 		jobIDs = append(jobIDs, jr.GetID())
 		var j *job.Job
 		var err error
 		j, err = b.getJC().Get(jr.GetID())
-		if j == nil {
-			j, err = b.getBC().FindJob(jr.GetID())
-		}
 		if err != nil {
 			glg.Warn("Batch: Unable to find job - " + jr.GetID())
 			for _, exec := range jr.GetExec() {
 				exec.SetErr("Unable to find job - " + jr.GetID())
+				results <- qItem.NewItem(job.Job{ID: jr.GetID()}, exec, results, b.GetCancelChan())
 			}
 		} else {
 			for _, exec := range jr.GetExec() {
@@ -159,25 +200,29 @@ func (b *Batch) Dispatch() {
 						glg.Warn("Batch: Queuing in " + strconv.FormatFloat(time.Unix(ex.GetExecutionTime(), 0).Sub(time.Now()).Seconds(), 'f', -1, 64) + " nanoseconds")
 						time.Sleep(time.Nanosecond * time.Duration(time.Unix(ex.GetExecutionTime(), 0).Sub(time.Now()).Nanoseconds()))
 					}
-					b.getPQ().Push(*j, ex, results, b.GetCancelChan())
+					if ex.GetStatus() == job.CANCELLED {
+						results <- qItem.NewItem(*j, ex, results, b.GetCancelChan()) //! halted while waiting to be queued
+					} else {
+						b.getPQ().Push(*j, ex, results, b.GetCancelChan())
+					}
 					sleepWG.Done()
 				}(exec)
 			}
 		}
 	}
-	sleepWG.Wait()
 
 	//! wait for all jobs to be done
-	for {
-		if len(results) == cap(results) {
-			close(results)
-			break
-		}
-	}
-
-	for item := range results {
+	for len(items) != b.getLength() {
+		item := <-results
 		items = append(items, item)
+		b.tracker.Returned(item.GetID(), item.GetExec())
+		if b.GetPolicy().FailFast() && workflow.Failed(item.GetExec()) && item.GetExec().GetErr() != workflow.ErrFailFast.Error() {
+			glg.Warn("Batch: Exec failed, cancelling remaining execs")
+			workflow.Halt(b.GetJobs())
+		}
 	}
+	sleepWG.Wait()
+	close(results)
 
 	var grouped []job.JobRequestMultiple
 	for _, jID := range jobIDs {
@@ -190,12 +235,14 @@ func (b *Batch) Dispatch() {
 		}
 		grouped = append(grouped, req)
 	}
+	b.compensations = workflow.Compensate(b.GetPolicy(), grouped, b.GetCancelChan(), b.getPQ(), b.getJC())
 	if cancelled == false {
 		closeCancel <- struct{}{}
-		b.setStatus(job.FINISHED)
+		b.setStatus(workflow.Status(grouped))
 	} else {
 		b.setStatus(job.CANCELLED)
 	}
 	wg.Wait()
 	b.setResults(grouped)
+	b.tracker.Finish(b.GetStatus())
 }
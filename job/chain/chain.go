@@ -13,18 +13,22 @@ import (
 	"github.com/gizo-network/gizo/job"
 	"github.com/gizo-network/gizo/job/queue"
 	"github.com/gizo-network/gizo/job/queue/qItem"
+	"github.com/gizo-network/gizo/job/workflow"
 )
 
 //Chain - Jobs executed one after the other
 type Chain struct {
-	jobs   []job.JobRequestMultiple
-	bc     *core.BlockChain
-	pq     *queue.JobPriorityQueue
-	jc     *cache.JobCache
-	result []job.JobRequestMultiple
-	length int
-	status string
-	cancel chan struct{}
+	jobs          []job.JobRequestMultiple
+	bc            *core.BlockChain
+	pq            *queue.JobPriorityQueue
+	jc            *cache.JobCache
+	result        []job.JobRequestMultiple
+	length        int
+	status        string
+	policy        workflow.Policy
+	compensations job.JobRequestMultiple
+	tracker       *workflow.Tracker
+	cancel        chan struct{}
 }
 
 //NewChain returns chain
@@ -37,13 +41,16 @@ func NewChain(j []job.JobRequestMultiple, bc *core.BlockChain, pq *queue.JobPrio
 		return nil, job.ErrJobsLenRange
 	}
 	c := &Chain{
-		jobs:   j,
-		bc:     bc,
-		pq:     pq,
-		jc:     jc,
-		length: length,
-		cancel: make(chan struct{}),
+		jobs:    j,
+		bc:      bc,
+		pq:      pq,
+		jc:      jc,
+		length:  length,
+		policy:  workflow.DefaultPolicy(),
+		tracker: workflow.NewTracker("chain"),
+		cancel:  make(chan struct{}),
 	}
+	c.tracker.AddSteps(j)
 	return c, nil
 }
 
@@ -55,6 +62,40 @@ func (c Chain) GetCancelChan() chan struct{} {
 	return c.cancel
 }
 
+//GetID returns the workflow id
+func (c Chain) GetID() string {
+	return c.tracker.GetID()
+}
+
+//GetRecord returns the workflow record
+func (c Chain) GetRecord() workflow.Record {
+	return c.tracker.GetRecord()
+}
+
+//SetOwner sets the pub of the client that submitted the chain
+func (c *Chain) SetOwner(pub string) {
+	c.tracker.SetOwner(pub)
+}
+
+//SetStore persists the workflow record in store
+func (c *Chain) SetStore(s *workflow.Store) {
+	c.tracker.SetStore(s)
+}
+
+//SetPolicy sets what the chain does when an exec fails
+func (c *Chain) SetPolicy(p workflow.Policy) {
+	c.policy = p
+}
+
+func (c Chain) GetPolicy() workflow.Policy {
+	return c.policy
+}
+
+//GetCompensations returns the execs of the on-failure job
+func (c Chain) GetCompensations() job.JobRequestMultiple {
+	return c.compensations
+}
+
 //GetJobs returns jobs
 func (c Chain) GetJobs() []job.JobRequestMultiple {
 	return c.jobs
@@ -105,9 +146,11 @@ func (c Chain) Result() []job.JobRequestMultiple {
 //Dispatch executes the chain
 func (c *Chain) Dispatch() {
 	c.setStatus(job.RUNNING)
+	c.tracker.Start()
 	var results []qItem.Item // used to hold results
 	res := make(chan qItem.Item)
 	cancelled := false
+	halted := false // set once an exec fails in a fail-fast chain
 	closeCancel := make(chan struct{})
 	var wg sync.WaitGroup
 	//! watch cancel channel
@@ -136,21 +179,26 @@ func (c *Chain) Dispatch() {
 	var jobIDs []string
 	for _, jr := range c.GetJobs() {
 		c.setStatus("Queueing execs of job - " + jr.GetID())
+		c.tracker.Queueing(jr.GetID())
 		jobIDs = append(jobIDs, jr.GetID())
 		var j *job.Job
 		var err error
 		j, err = c.getJC().Get(jr.GetID())
-		if j == nil {
-			j, err = c.getBC().FindJob(jr.GetID())
-		}
 		if err != nil {
 			glg.Warn("Chain: Unable to find job - " + jr.GetID())
 			for _, exec := range jr.GetExec() {
 				exec.SetErr("Unable to find job - " + jr.GetID())
+				results = append(results, qItem.NewItem(job.Job{ID: jr.GetID()}, exec, res, c.GetCancelChan()))
+				c.tracker.Returned(jr.GetID(), exec)
 			}
+			halted = c.GetPolicy().FailFast()
 		} else {
 			for i := 0; i < len(jr.GetExec()); i++ {
-				if cancelled == true {
+				if halted == true && cancelled == false {
+					jr.GetExec()[i].SetStatus(job.CANCELLED)
+					jr.GetExec()[i].SetErr(workflow.ErrFailFast.Error())
+				}
+				if cancelled == true || halted == true {
 					results = append(results, qItem.NewItem(job.Job{
 						ID:             j.GetID(),
 						Hash:           j.GetHash(),
@@ -160,13 +208,20 @@ func (c *Chain) Dispatch() {
 						SubmissionTime: j.GetSubmissionTime(),
 						Private:        j.GetPrivate(),
 					}, jr.GetExec()[i], res, c.GetCancelChan()))
+					c.tracker.Returned(jr.GetID(), jr.GetExec()[i])
 				} else {
 					if jr.GetExec()[i].GetExecutionTime() != 0 {
 						glg.Warn("Chain: Queuing in " + strconv.FormatFloat(time.Unix(jr.GetExec()[i].GetExecutionTime(), 0).Sub(time.Now()).Seconds(), 'f', -1, 64) + " nanoseconds")
 						time.Sleep(time.Nanosecond * time.Duration(time.Unix(jr.GetExec()[i].GetExecutionTime(), 0).Sub(time.Now()).Nanoseconds()))
 					}
 					c.getPQ().Push(*j, jr.GetExec()[i], res, c.GetCancelChan()) //? queues first job
-					results = append(results, <-res)
+					item := <-res
+					results = append(results, item)
+					c.tracker.Returned(jr.GetID(), item.GetExec())
+					if c.GetPolicy().FailFast() && workflow.Failed(item.GetExec()) {
+						glg.Warn("Chain: Exec failed, cancelling remaining execs")
+						halted = true
+					}
 				}
 			}
 		}
@@ -185,12 +240,14 @@ func (c *Chain) Dispatch() {
 		grouped = append(grouped, req)
 	}
 
+	c.compensations = workflow.Compensate(c.GetPolicy(), grouped, c.GetCancelChan(), c.getPQ(), c.getJC())
 	if cancelled == false {
 		closeCancel <- struct{}{}
-		c.setStatus(job.FINISHED)
+		c.setStatus(workflow.Status(grouped))
 	} else {
 		c.setStatus(job.CANCELLED)
 	}
 	wg.Wait()
 	c.setResults(grouped)
+	c.tracker.Finish(c.GetStatus())
 }
@@ -11,20 +11,27 @@ import (
 	"github.com/gizo-network/gizo/job"
 	"github.com/gizo-network/gizo/job/queue"
 	"github.com/gizo-network/gizo/job/queue/qItem"
+	"github.com/gizo-network/gizo/job/workflow"
 	"github.com/kpango/glg"
 )
 
+//CallbackStep is the name of the callback's step in the workflow record
+const CallbackStep = "callback"
+
 //Chord Jobs executed one after the other and the results passed to a callback
 type Chord struct {
-	jobs     []job.JobRequestMultiple
-	bc       *core.BlockChain
-	pq       *queue.JobPriorityQueue
-	jc       *cache.JobCache
-	callback job.JobRequestMultiple
-	result   job.JobRequestMultiple
-	length   int
-	status   string
-	cancel   chan struct{}
+	jobs          []job.JobRequestMultiple
+	bc            *core.BlockChain
+	pq            *queue.JobPriorityQueue
+	jc            *cache.JobCache
+	callback      job.JobRequestMultiple
+	result        job.JobRequestMultiple
+	length        int
+	status        string
+	policy        workflow.Policy
+	compensations job.JobRequestMultiple
+	tracker       *workflow.Tracker
+	cancel        chan struct{}
 }
 
 //NewChord returns chord
@@ -45,11 +52,35 @@ func NewChord(j []job.JobRequestMultiple, callback job.JobRequestMultiple, bc *c
 		jc:       jc,
 		callback: callback,
 		length:   length,
+		policy:   workflow.DefaultPolicy(),
+		tracker:  workflow.NewTracker("chord"),
 		cancel:   make(chan struct{}),
 	}
+	c.tracker.AddSteps(j)
+	c.tracker.AddStep(CallbackStep, callback.GetID(), len(callback.GetExec()))
 	return c, nil
 }
 
+//GetID returns the workflow id
+func (c Chord) GetID() string {
+	return c.tracker.GetID()
+}
+
+//GetRecord returns the workflow record
+func (c Chord) GetRecord() workflow.Record {
+	return c.tracker.GetRecord()
+}
+
+//SetOwner sets the pub of the client that submitted the chord
+func (c *Chord) SetOwner(pub string) {
+	c.tracker.SetOwner(pub)
+}
+
+//SetStore persists the workflow record in store
+func (c *Chord) SetStore(s *workflow.Store) {
+	c.tracker.SetStore(s)
+}
+
 func (c *Chord) Cancel() {
 	c.cancel <- struct{}{}
 }
@@ -58,6 +89,20 @@ func (c Chord) GetCancelChan() chan struct{} {
 	return c.cancel
 }
 
+//SetPolicy sets what the chord does when an exec fails
+func (c *Chord) SetPolicy(p workflow.Policy) {
+	c.policy = p
+}
+
+func (c Chord) GetPolicy() workflow.Policy {
+	return c.policy
+}
+
+//GetCompensations returns the execs of the on-failure job
+func (c Chord) GetCompensations() job.JobRequestMultiple {
+	return c.compensations
+}
+
 func (c Chord) GetCallback() job.JobRequestMultiple {
 	return c.callback
 }
@@ -116,9 +161,11 @@ func (c Chord) Result() job.JobRequestMultiple {
 //Dispatch executes the chord
 func (c *Chord) Dispatch() {
 	c.setStatus(job.RUNNING)
+	c.tracker.Start()
 	var items []qItem.Item // used to hold results
 	resChan := make(chan qItem.Item)
 	cancelled := false
+	halted := false // set once an exec fails in a fail-fast chord
 	closeCancel := make(chan struct{})
 	var wg sync.WaitGroup
 	//! watch cancel channel
@@ -159,20 +206,25 @@ func (c *Chord) Dispatch() {
 	}()
 	for _, jr := range c.GetJobs() {
 		c.setStatus("Queueing execs of job - " + jr.GetID())
+		c.tracker.Queueing(jr.GetID())
 		var j *job.Job
 		var err error
 		j, err = c.getJC().Get(jr.GetID())
-		if j == nil {
-			j, err = c.getBC().FindJob(jr.GetID())
-		}
 		if err != nil {
 			glg.Warn("Chord: Unable to find job - " + jr.GetID())
 			for _, exec := range jr.GetExec() {
 				exec.SetErr("Unable to find job - " + jr.GetID())
+				items = append(items, qItem.NewItem(job.Job{ID: jr.GetID()}, exec, resChan, c.GetCancelChan()))
+				c.tracker.Returned(jr.GetID(), exec)
 			}
+			halted = c.GetPolicy().FailFast()
 		} else {
 			for i := 0; i < len(jr.GetExec()); i++ {
-				if cancelled == true {
+				if halted == true && cancelled == false {
+					jr.GetExec()[i].SetStatus(job.CANCELLED)
+					jr.GetExec()[i].SetErr(workflow.ErrFailFast.Error())
+				}
+				if cancelled == true || halted == true {
 					items = append(items, qItem.NewItem(job.Job{
 						ID:             j.GetID(),
 						Hash:           j.GetHash(),
@@ -182,6 +234,7 @@ func (c *Chord) Dispatch() {
 						SubmissionTime: j.GetSubmissionTime(),
 						Private:        j.GetPrivate(),
 					}, jr.GetExec()[i], resChan, c.GetCancelChan()))
+					c.tracker.Returned(jr.GetID(), jr.GetExec()[i])
 				} else {
 					if jr.GetExec()[i].GetExecutionTime() != 0 {
 						glg.Warn("Chord: Queuing in " + strconv.FormatFloat(time.Unix(jr.GetExec()[i].GetExecutionTime(), 0).Sub(time.Now()).Seconds(), 'f', -1, 64) + " nanoseconds")
@@ -189,7 +242,13 @@ func (c *Chord) Dispatch() {
 
 					}
 					c.getPQ().Push(*j, jr.GetExec()[i], resChan, c.GetCancelChan()) //? queues first job
-					items = append(items, <-resChan)
+					item := <-resChan
+					items = append(items, item)
+					c.tracker.Returned(jr.GetID(), item.GetExec())
+					if c.GetPolicy().FailFast() && workflow.Failed(item.GetExec()) {
+						glg.Warn("Chord: Exec failed, cancelling remaining execs")
+						halted = true
+					}
 				}
 			}
 		}
@@ -197,10 +256,15 @@ func (c *Chord) Dispatch() {
 	close(resChan)
 
 	var callbackResults []qItem.Item
-	var callbackArgs []interface{}        //holds result of execs
+	var executed []*job.Exec
 	callbackChan := make(chan qItem.Item) //causes program to pause
 	for _, item := range items {
-		callbackArgs = append(callbackArgs, item.GetExec().GetResult())
+		executed = append(executed, item.GetExec())
+	}
+	callbackArgs := workflow.Succeeded(executed) //! results of failed execs are left out so the callback never receives nil inputs
+	if c.GetPolicy().SkipDependents() && len(callbackArgs) != len(executed) {
+		glg.Warn("Chord: Exec failed, skipping callback")
+		halted = true
 	}
 
 	//! sets args as results or jobs
@@ -208,15 +272,21 @@ func (c *Chord) Dispatch() {
 		exec.SetArgs(callbackArgs)
 	}
 
-	cj, err := c.getBC().FindJob(c.GetCallback().GetID())
+	c.tracker.Queueing(CallbackStep)
+	cj, err := c.getJC().Get(c.GetCallback().GetID())
 	if err != nil {
 		glg.Warn("Chord: Unable to find job - " + c.GetCallback().GetID())
 		for _, exec := range c.GetCallback().GetExec() {
 			exec.SetErr("Unable to find job - " + c.GetCallback().GetID())
+			callbackResults = append(callbackResults, qItem.NewItem(job.Job{ID: c.GetCallback().GetID()}, exec, callbackChan, c.GetCancelChan()))
 		}
 	} else {
 		for _, exec := range c.GetCallback().GetExec() {
-			if cancelled == true {
+			if halted == true && cancelled == false {
+				exec.SetStatus(job.CANCELLED)
+				exec.SetErr(workflow.ErrFailFast.Error())
+			}
+			if cancelled == true || halted == true {
 				callbackResults = append(callbackResults, qItem.NewItem(job.Job{
 					ID:             cj.GetID(),
 					Hash:           cj.GetHash(),
@@ -243,14 +313,28 @@ func (c *Chord) Dispatch() {
 	callback.SetID(c.GetCallback().GetID())
 	for _, item := range callbackResults {
 		callback.AppendExec(item.GetExec())
+		c.tracker.Returned(CallbackStep, item.GetExec())
 	}
 
+	results := []job.JobRequestMultiple{callback}
+	for _, jr := range c.GetJobs() {
+		var req job.JobRequestMultiple
+		req.SetID(jr.GetID())
+		for _, item := range items {
+			if item.GetID() == jr.GetID() {
+				req.AppendExec(item.GetExec())
+			}
+		}
+		results = append(results, req)
+	}
+	c.compensations = workflow.Compensate(c.GetPolicy(), results, c.GetCancelChan(), c.getPQ(), c.getJC())
 	if cancelled == false {
 		closeCancel <- struct{}{}
-		c.setStatus(job.FINISHED)
+		c.setStatus(workflow.Status(results))
 	} else {
 		c.setStatus(job.CANCELLED)
 	}
 	wg.Wait()
 	c.setResults(callback)
+	c.tracker.Finish(c.GetStatus())
 }
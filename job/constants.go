@@ -12,17 +12,25 @@ var (
 	ErrRetryDelayOutsideLimit = errors.New("Retry Delay outside limit")
 	ErrExecutionTimeBehind    = errors.New("Execution time is past")
 	ErrJobsLenRange           = errors.New("Number of jobs is more than allowed")
+	ErrReplicasOutsideLimit   = errors.New("Replicas outside limit")
+	ErrKeyOutsideLimit        = errors.New("Idempotency key longer than allowed")
 )
 
 const (
-	MaxExecs        = 10 // max number of jobs allowed in the chain
 	MaxRetries      = 5
 	MaxRetryBackoff = 120 //! 2 minutes
-	DefaultMaxTTL   = time.Minute * 10
+	MaxReplicas     = 5   // max number of workers an exec can be replicated to
+	MaxKeyLength    = 128 // max length of an idempotency key
 	DefaultRetries  = 0
 	DefaultPriority = NORMAL
 )
 
+//! limits overridden by config
+var (
+	MaxExecs      = 10 // max number of jobs allowed in the chain
+	DefaultMaxTTL = time.Minute * 10
+)
+
 //! priorities
 const (
 	HIGH   = 3
@@ -42,4 +50,14 @@ const (
 	RETRYING    = "RETRYING"   //job retrying
 	DISPATHCHED = "DISPATCHED" //job dispatched to worker
 	STARTED     = "STARTED"    //job received by dispatcher (prior to dispatch)
+	REJECTED    = "REJECTED"   //job rejected by the dispatcher's quotas
+	PARTIAL     = "PARTIAL"    //workflow done with some execs failed
+	FAILED      = "FAILED"     //workflow done with every exec failed
+)
+
+//! cache statuses of execs of deterministic jobs
+const (
+	CacheHit    = "HIT"    // answered from a prior exec
+	CacheMiss   = "MISS"   // no prior exec with the same args
+	CacheBypass = "BYPASS" // lookup skipped by the sender
 )
@@ -0,0 +1,450 @@
+package dag
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gizo-network/gizo/cache"
+
+	"github.com/gizo-network/gizo/core"
+	"github.com/gizo-network/gizo/job"
+	"github.com/gizo-network/gizo/job/queue"
+	"github.com/gizo-network/gizo/job/queue/qItem"
+	"github.com/gizo-network/gizo/job/workflow"
+	"github.com/kpango/glg"
+)
+
+var (
+	ErrCycle            = errors.New("DAG: dependencies form a cycle")
+	ErrNodeNotFound     = errors.New("DAG: edge references an unknown node")
+	ErrDependencyFailed = errors.New("DAG: a dependency of the node failed")
+	ErrHalted           = errors.New("DAG: workflow halted before the node ran")
+)
+
+//! limit overridden by config
+var (
+	MaxExecs = 1000 // max number of execs in a dag
+)
+
+// node states
+const (
+	PENDING  = "PENDING"  // waiting for dependencies
+	RUNNING  = "RUNNING"  // execs queued
+	FINISHED = "FINISHED" // every exec finished without an error
+	FAILED   = "FAILED"   // an exec errored, timed out or was cancelled
+	SKIPPED  = "SKIPPED"  // not run because of a failed dependency or a halted workflow
+)
+
+//Edge passes the results of From's execs as args to To's execs
+type Edge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+func NewEdge(from, to string) Edge {
+	return Edge{From: from, To: to}
+}
+
+func (e Edge) GetFrom() string {
+	return e.From
+}
+
+func (e Edge) GetTo() string {
+	return e.To
+}
+
+//DAG Jobs executed once their dependencies are done, independent branches run in parallel.
+//
+//with FAIL_FAST nodes that haven't started are skipped once an exec fails, with SKIP_DEPENDENTS dependents of a failed node
+//are skipped and independent branches keep running, with CONTINUE dependents run without the failed results as args
+type DAG struct {
+	nodes         map[string]job.JobRequestMultiple // job requests keyed by node id
+	deps          map[string][]string               // dependencies of each node in edge order
+	order         []string                          // topological order of the nodes
+	bc            *core.BlockChain
+	pq            *queue.JobPriorityQueue
+	jc            *cache.JobCache
+	policy        workflow.Policy
+	compensations job.JobRequestMultiple
+	mu            *sync.Mutex
+	states        map[string]string
+	result        map[string]job.JobRequestMultiple
+	halted        error // set once the workflow stops starting nodes
+	length        int
+	status        string
+	tracker       *workflow.Tracker
+	cancel        chan struct{}
+}
+
+//NewDAG returns a dag of job requests keyed by node id, edges must reference nodes and not form a cycle
+func NewDAG(nodes map[string]job.JobRequestMultiple, edges []Edge, bc *core.BlockChain, pq *queue.JobPriorityQueue, jc *cache.JobCache) (*DAG, error) {
+	length := 0
+	for _, jr := range nodes {
+		length += len(jr.GetExec())
+	}
+	if length > MaxExecs {
+		return nil, job.ErrJobsLenRange
+	}
+	deps := make(map[string][]string)
+	for _, e := range edges {
+		if _, ok := nodes[e.GetFrom()]; !ok {
+			return nil, ErrNodeNotFound
+		}
+		if _, ok := nodes[e.GetTo()]; !ok {
+			return nil, ErrNodeNotFound
+		}
+		deps[e.GetTo()] = append(deps[e.GetTo()], e.GetFrom())
+	}
+	order, err := topological(nodes, deps)
+	if err != nil {
+		return nil, err
+	}
+	states := make(map[string]string)
+	for id := range nodes {
+		states[id] = PENDING
+	}
+	d := &DAG{
+		nodes:   nodes,
+		deps:    deps,
+		order:   order,
+		bc:      bc,
+		pq:      pq,
+		jc:      jc,
+		policy:  workflow.DefaultPolicy(),
+		mu:      new(sync.Mutex),
+		states:  states,
+		result:  make(map[string]job.JobRequestMultiple),
+		length:  length,
+		tracker: workflow.NewTracker("dag"),
+		cancel:  make(chan struct{}, 1),
+	}
+	for _, id := range order {
+		d.tracker.AddStep(id, nodes[id].GetID(), len(nodes[id].GetExec()))
+	}
+	return d, nil
+}
+
+//orders nodes so each comes after its dependencies (kahn's algorithm), returns ErrCycle if some can't be ordered
+func topological(nodes map[string]job.JobRequestMultiple, deps map[string][]string) ([]string, error) {
+	indegree := make(map[string]int)
+	dependents := make(map[string][]string)
+	for id := range nodes {
+		indegree[id] = len(deps[id])
+		for _, dep := range deps[id] {
+			dependents[dep] = append(dependents[dep], id)
+		}
+	}
+	var ready, order []string
+	for id, degree := range indegree {
+		if degree == 0 {
+			ready = append(ready, id)
+		}
+	}
+	for len(ready) != 0 {
+		id := ready[0]
+		ready = ready[1:]
+		order = append(order, id)
+		for _, dependent := range dependents[id] {
+			indegree[dependent]--
+			if indegree[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+	if len(order) != len(nodes) {
+		return nil, ErrCycle
+	}
+	return order, nil
+}
+
+//GetID returns the workflow id
+func (d *DAG) GetID() string {
+	return d.tracker.GetID()
+}
+
+//GetRecord returns the workflow record
+func (d *DAG) GetRecord() workflow.Record {
+	return d.tracker.GetRecord()
+}
+
+//SetOwner sets the pub of the client that submitted the dag
+func (d *DAG) SetOwner(pub string) {
+	d.tracker.SetOwner(pub)
+}
+
+//SetStore persists the workflow record in store
+func (d *DAG) SetStore(s *workflow.Store) {
+	d.tracker.SetStore(s)
+}
+
+//Cancel cancels the dag, it doesn't block if the dag isn't dispatching
+func (d *DAG) Cancel() {
+	select {
+	case d.cancel <- struct{}{}:
+	default:
+	}
+}
+
+func (d *DAG) GetCancelChan() chan struct{} {
+	return d.cancel
+}
+
+//GetNodes returns the job requests keyed by node id
+func (d *DAG) GetNodes() map[string]job.JobRequestMultiple {
+	return d.nodes
+}
+
+//GetDependencies returns the nodes whose results are passed to a node
+func (d *DAG) GetDependencies(id string) []string {
+	return d.deps[id]
+}
+
+//GetOrder returns the nodes in an order where each comes after its dependencies
+func (d *DAG) GetOrder() []string {
+	return d.order
+}
+
+//SetPolicy sets what the dag does when an exec fails
+func (d *DAG) SetPolicy(p workflow.Policy) {
+	d.policy = p
+}
+
+func (d *DAG) GetPolicy() workflow.Policy {
+	return d.policy
+}
+
+//GetCompensations returns the execs of the on-failure job
+func (d *DAG) GetCompensations() job.JobRequestMultiple {
+	return d.compensations
+}
+
+//GetState returns the state of a node
+func (d *DAG) GetState(id string) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.states[id]
+}
+
+func (d *DAG) setState(id, state string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.states[id] = state
+}
+
+//GetStatus returns status
+func (d *DAG) GetStatus() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.status
+}
+
+//! set by the goroutines of nodes running in parallel
+func (d *DAG) setStatus(s string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.status = s
+}
+
+func (d *DAG) getLength() int {
+	return d.length
+}
+
+func (d *DAG) getPQ() *queue.JobPriorityQueue {
+	return d.pq
+}
+
+func (d *DAG) getBC() *core.BlockChain {
+	return d.bc
+}
+
+func (d *DAG) getJC() *cache.JobCache {
+	return d.jc
+}
+
+func (d *DAG) setResult(id string, res job.JobRequestMultiple) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.result[id] = res
+}
+
+//Result returns the executed job requests keyed by node id
+func (d *DAG) Result() map[string]job.JobRequestMultiple {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.result
+}
+
+//stops nodes that haven't started from running
+func (d *DAG) halt(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.halted == nil {
+		d.halted = err
+	}
+}
+
+//Dispatch executes the dag
+func (d *DAG) Dispatch() {
+	d.setStatus(job.RUNNING)
+	d.tracker.Start()
+	var cancelled int32
+	closeCancel := make(chan struct{})
+	var wg sync.WaitGroup
+	//! watch cancel channel
+	wg.Add(1)
+	go func() {
+		select {
+		case <-d.cancel:
+			atomic.StoreInt32(&cancelled, 1)
+			glg.Warn("DAG: Cancelling jobs")
+			d.halt(ErrHalted) //! nodes that haven't started are skipped
+			d.mu.Lock()
+			var running []job.JobRequestMultiple
+			for id, state := range d.states {
+				if state == RUNNING {
+					running = append(running, d.nodes[id])
+				}
+			}
+			d.mu.Unlock()
+			for _, jr := range running {
+				for _, exec := range jr.GetExec() {
+					if exec.GetStatus() == job.RUNNING || exec.GetStatus() == job.RETRYING {
+						exec.Cancel()
+					}
+					if exec.GetResult() == nil {
+						exec.SetStatus(job.CANCELLED) //! queued execs are returned when popped
+					}
+				}
+			}
+			d.setStatus(job.CANCELLED)
+			break
+		case <-closeCancel:
+			break
+		}
+		wg.Done()
+	}()
+
+	done := make(map[string]chan struct{})
+	for _, id := range d.GetOrder() {
+		done[id] = make(chan struct{})
+	}
+	var nodes sync.WaitGroup
+	for _, id := range d.GetOrder() {
+		nodes.Add(1)
+		go func(id string) {
+			for _, dep := range d.GetDependencies(id) {
+				<-done[dep]
+			}
+			d.run(id)
+			close(done[id])
+			nodes.Done()
+		}(id)
+	}
+	nodes.Wait()
+
+	var results []job.JobRequestMultiple
+	for _, id := range d.GetOrder() {
+		results = append(results, d.Result()[id])
+	}
+	d.compensations = workflow.Compensate(d.GetPolicy(), results, d.GetCancelChan(), d.getPQ(), d.getJC())
+	close(closeCancel) //! never blocks if the dag was cancelled as it finished
+	wg.Wait()
+	if atomic.LoadInt32(&cancelled) == 0 {
+		d.setStatus(workflow.Status(results))
+	} else {
+		d.setStatus(job.CANCELLED)
+	}
+	d.tracker.Finish(d.GetStatus())
+}
+
+//runs the execs of a node once its dependencies are done
+func (d *DAG) run(id string) {
+	jr := d.GetNodes()[id]
+	d.mu.Lock()
+	skip := d.halted
+	var args []interface{}
+	for _, dep := range d.GetDependencies(id) {
+		if d.states[dep] != FINISHED && d.policy.GetMode() != workflow.ContinueOnError && skip == nil {
+			skip = ErrDependencyFailed
+		}
+		args = append(args, workflow.Succeeded(d.result[dep].GetExec())...) //! dependents never receive nil inputs
+	}
+	d.mu.Unlock()
+	if skip != nil {
+		glg.Warn("DAG: Skipping node - " + id)
+		for _, exec := range jr.GetExec() {
+			exec.SetStatus(job.CANCELLED)
+			exec.SetErr(skip.Error())
+			d.tracker.Returned(id, exec)
+		}
+		d.setResult(id, jr)
+		d.setState(id, SKIPPED)
+		return
+	}
+
+	d.setState(id, RUNNING)
+	d.setStatus("Queueing execs of node - " + id)
+	d.tracker.Queueing(id)
+	var j *job.Job
+	var err error
+	j, err = d.getJC().Get(jr.GetID())
+	if err != nil {
+		glg.Warn("DAG: Unable to find job - " + jr.GetID())
+		for _, exec := range jr.GetExec() {
+			exec.SetErr("Unable to find job - " + jr.GetID())
+			d.tracker.Returned(id, exec)
+		}
+		d.finish(id, jr)
+		return
+	}
+
+	//! each exec has its own results channel so results keep the order of the execs
+	results := make([]chan qItem.Item, len(jr.GetExec()))
+	for i, exec := range jr.GetExec() {
+		results[i] = make(chan qItem.Item, 1)
+		exec.SetArgs(append(append([]interface{}{}, exec.GetArgs()...), args...))
+		go func(ex *job.Exec, res chan qItem.Item) {
+			if ex.GetExecutionTime() != 0 {
+				glg.Warn("DAG: Queuing in " + strconv.FormatFloat(time.Unix(ex.GetExecutionTime(), 0).Sub(time.Now()).Seconds(), 'f', -1, 64) + " nanoseconds")
+				time.Sleep(time.Nanosecond * time.Duration(time.Unix(ex.GetExecutionTime(), 0).Sub(time.Now()).Nanoseconds()))
+			}
+			d.getPQ().Push(*j, ex, res, d.GetCancelChan())
+		}(exec, results[i])
+	}
+	var executed job.JobRequestMultiple
+	executed.SetID(jr.GetID())
+	for _, res := range results {
+		exec := (<-res).GetExec()
+		executed.AppendExec(exec)
+		d.tracker.Returned(id, exec)
+	}
+	d.finish(id, executed)
+}
+
+//records the result of a node and applies the failure policy
+func (d *DAG) finish(id string, executed job.JobRequestMultiple) {
+	d.setResult(id, executed)
+	for _, exec := range executed.GetExec() {
+		if workflow.Failed(exec) {
+			glg.Warn("DAG: Node failed - " + id)
+			d.setState(id, FAILED)
+			if d.GetPolicy().FailFast() {
+				d.halt(ErrHalted)
+				d.mu.Lock()
+				var running []job.JobRequestMultiple
+				for node, state := range d.states {
+					if state == RUNNING {
+						running = append(running, d.nodes[node])
+					}
+				}
+				d.mu.Unlock()
+				workflow.Halt(running) //! queued execs of running nodes are returned when popped
+			}
+			return
+		}
+	}
+	d.setState(id, FINISHED)
+}
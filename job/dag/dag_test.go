@@ -0,0 +1,222 @@
+package dag_test
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gizo-network/gizo/cache"
+	"github.com/gizo-network/gizo/core"
+	"github.com/gizo-network/gizo/crypt"
+	"github.com/gizo-network/gizo/job"
+	"github.com/gizo-network/gizo/job/dag"
+	"github.com/gizo-network/gizo/job/queue"
+	"github.com/gizo-network/gizo/job/workflow"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewDAG(t *testing.T) {
+	nodes := make(map[string]job.JobRequestMultiple)
+	for _, id := range []string{"fetch", "parse", "count", "report"} {
+		exec, err := job.NewExec([]interface{}{}, 0, job.NORMAL, 0, 0, 0, 0, "", job.NewEnvVariables(), "")
+		assert.NoError(t, err)
+		nodes[id] = *job.NewJobRequestMultiple(id, exec)
+	}
+	edges := []dag.Edge{
+		dag.NewEdge("fetch", "parse"),
+		dag.NewEdge("fetch", "count"),
+		dag.NewEdge("parse", "report"),
+		dag.NewEdge("count", "report"),
+	}
+
+	d, err := dag.NewDAG(nodes, edges, nil, nil, nil)
+	assert.NoError(t, err)
+	position := make(map[string]int)
+	for i, id := range d.GetOrder() {
+		position[id] = i
+	}
+	for _, e := range edges {
+		assert.True(t, position[e.GetFrom()] < position[e.GetTo()])
+	}
+	assert.Equal(t, []string{"parse", "count"}, d.GetDependencies("report"))
+	assert.Equal(t, dag.PENDING, d.GetState("report"))
+
+	_, err = dag.NewDAG(nodes, append(edges, dag.NewEdge("report", "fetch")), nil, nil, nil)
+	assert.Equal(t, dag.ErrCycle, err)
+	_, err = dag.NewDAG(nodes, []dag.Edge{dag.NewEdge("fetch", "upload")}, nil, nil, nil)
+	assert.Equal(t, dag.ErrNodeNotFound, err)
+}
+
+//runs fetch -> parse, count -> report next to audit -> summary with a fake worker, execs of failing nodes return an error
+//and the others return the number of args they got. fetch is held until audit is dispatched and audit until the dependents of
+//fetch run, so audit is running when fetch fails
+func dispatch(t *testing.T, mode string, failing ...string) *dag.DAG {
+	dir, err := ioutil.TempDir("", "gizo-dag")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	core.SetDataDir(dir)
+	defer core.SetDataDir("")
+	jc := cache.NewJobCache(core.CreateBlockChain("test"))
+	pq := queue.NewJobPriorityQueue()
+
+	priv, _ := crypt.GenKeys()
+	names := make(map[string]string)
+	nodes := make(map[string]job.JobRequestMultiple)
+	for _, id := range []string{"fetch", "parse", "count", "report", "audit", "summary"} {
+		j := job.NewJob("func Node(){return 1}", id, false, hex.EncodeToString(priv))
+		jc.Set(j.GetID(), j.Serialize())
+		names[j.GetID()] = id
+		exec, err := job.NewExec([]interface{}{}, 0, job.NORMAL, 0, 0, 0, 0, "", job.NewEnvVariables(), "")
+		assert.NoError(t, err)
+		nodes[id] = *job.NewJobRequestMultiple(j.GetID(), exec)
+	}
+	edges := []dag.Edge{
+		dag.NewEdge("fetch", "parse"),
+		dag.NewEdge("fetch", "count"),
+		dag.NewEdge("parse", "report"),
+		dag.NewEdge("count", "report"),
+		dag.NewEdge("audit", "summary"),
+	}
+	d, err := dag.NewDAG(nodes, edges, nil, pq, jc)
+	assert.NoError(t, err)
+	policy, err := workflow.NewPolicy(mode, "")
+	assert.NoError(t, err)
+	d.SetPolicy(policy)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	audit := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if pq.Empty() {
+				time.Sleep(time.Millisecond)
+				continue
+			}
+			item := pq.Pop()
+			if item.GetExec() == nil {
+				continue
+			}
+			item.GetExec().SetStatus(job.DISPATHCHED) //! as the dispatcher does, halting leaves it running
+			id := names[item.GetJob().GetID()]
+			if id == "audit" {
+				close(audit)
+			}
+			go func() {
+				if id == "fetch" {
+					<-audit
+				}
+				for id == "audit" && d.GetState("parse") == dag.PENDING {
+					time.Sleep(time.Millisecond)
+				}
+				exec := item.GetExec()
+				exec.SetStatus(job.FINISHED)
+				exec.SetResult(len(exec.GetArgs()))
+				for _, f := range failing {
+					if f == id {
+						exec.SetResult(nil)
+						exec.SetErr("failed")
+					}
+				}
+				item.ResultsChan() <- item
+			}()
+		}
+	}()
+	d.Dispatch()
+	return d
+}
+
+func TestDispatch(t *testing.T) {
+	d := dispatch(t, workflow.SkipDependents)
+	assert.Equal(t, job.FINISHED, d.GetStatus())
+	for _, id := range d.GetOrder() {
+		assert.Equal(t, dag.FINISHED, d.GetState(id))
+	}
+	//! results of dependencies are passed as args in edge order
+	assert.Equal(t, []interface{}{0}, d.Result()["parse"].GetExec()[0].GetArgs())
+	assert.Equal(t, []interface{}{1, 1}, d.Result()["report"].GetExec()[0].GetArgs())
+	assert.Equal(t, 2, d.Result()["report"].GetExec()[0].GetResult())
+}
+
+func TestDispatchSkipDependents(t *testing.T) {
+	d := dispatch(t, workflow.SkipDependents, "parse")
+	assert.Equal(t, job.PARTIAL, d.GetStatus())
+	assert.Equal(t, dag.FAILED, d.GetState("parse"))
+	assert.Equal(t, dag.FINISHED, d.GetState("count"))
+	assert.Equal(t, dag.SKIPPED, d.GetState("report"))
+	assert.Equal(t, dag.ErrDependencyFailed.Error(), d.Result()["report"].GetExec()[0].GetErr())
+	assert.Equal(t, dag.FINISHED, d.GetState("summary"))
+}
+
+func TestDispatchFailFast(t *testing.T) {
+	d := dispatch(t, workflow.FailFast, "fetch")
+	assert.Equal(t, job.PARTIAL, d.GetStatus())
+	assert.Equal(t, dag.FAILED, d.GetState("fetch"))
+	for _, id := range []string{"parse", "count", "report"} {
+		assert.Equal(t, dag.SKIPPED, d.GetState(id))
+	}
+	//! independent branches that haven't started are halted too
+	assert.Equal(t, dag.FINISHED, d.GetState("audit"))
+	assert.Equal(t, dag.SKIPPED, d.GetState("summary"))
+	assert.Equal(t, dag.ErrHalted.Error(), d.Result()["summary"].GetExec()[0].GetErr())
+}
+
+func TestDispatchContinue(t *testing.T) {
+	d := dispatch(t, workflow.ContinueOnError, "parse")
+	assert.Equal(t, job.PARTIAL, d.GetStatus())
+	assert.Equal(t, dag.FAILED, d.GetState("parse"))
+	assert.Equal(t, dag.FINISHED, d.GetState("report"))
+	//! only results of dependencies that succeeded are passed
+	assert.Equal(t, []interface{}{1}, d.Result()["report"].GetExec()[0].GetArgs())
+}
+
+func TestDispatchCancel(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gizo-dag")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	core.SetDataDir(dir)
+	defer core.SetDataDir("")
+	jc := cache.NewJobCache(core.CreateBlockChain("test"))
+	pq := queue.NewJobPriorityQueue()
+
+	priv, _ := crypt.GenKeys()
+	nodes := make(map[string]job.JobRequestMultiple)
+	for _, id := range []string{"fetch", "parse"} {
+		j := job.NewJob("func Node(){return 1}", id, false, hex.EncodeToString(priv))
+		jc.Set(j.GetID(), j.Serialize())
+		exec, err := job.NewExec([]interface{}{}, 0, job.NORMAL, 0, 0, 0, 0, "", job.NewEnvVariables(), "")
+		assert.NoError(t, err)
+		nodes[id] = *job.NewJobRequestMultiple(j.GetID(), exec)
+	}
+	d, err := dag.NewDAG(nodes, []dag.Edge{dag.NewEdge("fetch", "parse")}, nil, pq, jc)
+	assert.NoError(t, err)
+
+	done := make(chan struct{})
+	go func() {
+		d.Dispatch()
+		close(done)
+	}()
+	for pq.Len() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	d.Cancel()
+	for d.GetStatus() != job.CANCELLED {
+		time.Sleep(time.Millisecond)
+	}
+	//! the queued exec is marked cancelled and returned once popped, as the dispatcher does
+	item := pq.Pop()
+	assert.Equal(t, job.CANCELLED, item.GetExec().GetStatus())
+	item.ResultsChan() <- item
+	<-done
+
+	assert.Equal(t, job.CANCELLED, d.GetStatus())
+	assert.Equal(t, dag.SKIPPED, d.GetState("parse"))
+	assert.Equal(t, job.CANCELLED, d.Result()["parse"].GetExec()[0].GetStatus())
+	d.Cancel() //! doesn't block once the dag is done
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/satori/go.uuid"
 
 	"github.com/gizo-network/gizo/helpers"
+	"github.com/gizo-network/gizo/metrics"
 
 	"github.com/kpango/glg"
 	anko_core "github.com/mattn/anko/builtins"
@@ -37,7 +38,8 @@ type Job struct {
 	Task           string    `json:"task"`
 	Signature      [][]byte  `json:"signature"` // signature of owner
 	SubmissionTime time.Time `json:"submission_time"`
-	Private        bool      `json:"private"` //private job flag (default to false - public)
+	Private        bool      `json:"private"`       //private job flag (default to false - public)
+	Deterministic  bool      `json:"deterministic"` // results depend only on args, execs with args run before are answered from prior execs
 }
 
 func (j *Job) Sign(priv []byte) {
@@ -111,6 +113,24 @@ func (j *Job) setPrivate(p bool) {
 	j.Private = p
 }
 
+func (j Job) GetDeterministic() bool {
+	return j.Deterministic
+}
+
+//SetDeterministic flags the job's results as depending only on args and regenerates its hash
+func (j *Job) SetDeterministic(d bool) {
+	j.Deterministic = d
+	j.setHash()
+}
+
+//! only hashed when set so hashes of jobs created before the flag don't change
+func (j Job) deterministicHeader() []byte {
+	if j.GetDeterministic() {
+		return []byte("deterministic")
+	}
+	return []byte{}
+}
+
 func (j Job) GetName() string {
 	return j.Name
 }
@@ -137,6 +157,7 @@ func (j *Job) setHash() {
 			j.GetSignature()[1],
 			[]byte(string(j.GetSubmissionTime().Unix())),
 			[]byte(strconv.FormatBool(j.GetPrivate())),
+			j.deterministicHeader(),
 		},
 		[]byte{},
 	)
@@ -154,6 +175,7 @@ func (j Job) Verify() bool {
 			j.GetSignature()[1],
 			[]byte(string(j.GetSubmissionTime().Unix())),
 			[]byte(strconv.FormatBool(j.GetPrivate())),
+			j.deterministicHeader(),
 		},
 		[]byte{},
 	)
@@ -267,7 +289,7 @@ func argsStringified(args []interface{}) string {
 func (j *Job) Execute(exec *Exec, passphrase string) *Exec {
 	//TODO: kill goroutines running within this function when it exits
 	if j.GetPrivate() == true {
-		if j.VerifySignature(exec.getPub()) == false {
+		if j.VerifySignature(exec.GetPub()) == false {
 			exec.SetErr(ErrUnverifiedSignature)
 			return exec
 		}
@@ -324,6 +346,7 @@ func (j *Job) Execute(exec *Exec, passphrase string) *Exec {
 			goto retry
 		}
 		exec.SetDuration(time.Duration(time.Now().Sub(start).Nanoseconds()))
+		metrics.ExecDuration.Observe(exec.GetDuration().Seconds())
 		exec.SetErr(err)
 		exec.SetResult(result)
 		exec.setHash()
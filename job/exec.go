@@ -5,33 +5,41 @@ import (
 	"crypto/sha256"
 	"encoding/json"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gizo-network/gizo/helpers"
+	"github.com/gizo-network/gizo/metrics"
 
 	"github.com/kpango/glg"
 )
 
 //TODO: add environment variables
 type Exec struct {
-	Hash          []byte        `json:"hash"`
-	Timestamp     int64         `json:"timestamp"`
-	Duration      time.Duration `json:"duaration"` //saved in nanoseconds
-	Args          []interface{} `json:"args"`
-	Err           interface{}   `json:"err"`
-	Priority      int           `json:"priority"`
-	Result        interface{}   `json:"result"`
-	Status        string        `json:"status"`         //job status
-	Retries       int           `json:"retries"`        // number of max retries
-	RetriesCount  int           `json:"retries_count"`  //number of retries
-	Backoff       time.Duration `json:"backoff"`        //backoff time of retries (seconds)
-	ExecutionTime int64         `json:"execution_time"` // time scheduled to run (unix) - should sleep # of seconds before adding to job queue
-	Interval      int           `json:"interval"`       //periodic job exec (seconds)
-	By            string        `json:"by"`             //! ID of the worker node that ran this
-	TTL           time.Duration `json:"ttl"`            //! time limit of job running
-	Pub           string        `json:"pub"`            //! public key for private jobs
-	Envs          []byte        `json:"envs"`
-	cancel        chan struct{}
+	Hash           []byte        `json:"hash"`
+	Timestamp      int64         `json:"timestamp"`
+	Duration       time.Duration `json:"duaration"` //saved in nanoseconds
+	Args           []interface{} `json:"args"`
+	Err            interface{}   `json:"err"`
+	Priority       int           `json:"priority"`
+	Result         interface{}   `json:"result"`
+	Status         string        `json:"status"`         //job status
+	Retries        int           `json:"retries"`        // number of max retries
+	RetriesCount   int           `json:"retries_count"`  //number of retries
+	Backoff        time.Duration `json:"backoff"`        //backoff time of retries (seconds)
+	ExecutionTime  int64         `json:"execution_time"` // time scheduled to run (unix) - should sleep # of seconds before adding to job queue
+	Interval       int           `json:"interval"`       //periodic job exec (seconds)
+	By             string        `json:"by"`             //! ID of the worker node that ran this
+	TTL            time.Duration `json:"ttl"`            //! time limit of job running
+	Pub            string        `json:"pub"`            //! public key for private jobs
+	Envs           []byte        `json:"envs"`
+	Replicas       int           `json:"replicas"`        // number of workers the exec is sent to for cross-verification
+	Verifiers      []string      `json:"verifiers"`       //! public keys of the workers that agreed on the result
+	IdempotencyKey string        `json:"idempotency_key"` // client-supplied key, resubmissions with the same key and submitter within the dedupe window aren't run again
+	Submitter      string        `json:"submitter"`       //! public key of the authenticated client that submitted the exec, set by the dispatcher
+	BypassCache    bool          `json:"bypass_cache"`    // runs an exec of a deterministic job even if a prior exec has the same args
+	CacheStatus    string        `json:"cache_status"`    // HIT if answered from a prior exec, MISS or BYPASS if run, empty for non-deterministic jobs
+	cancel         chan struct{}
 }
 
 func NewExec(args []interface{}, retries, priority int, backoff time.Duration, execTime int64, interval int, ttl time.Duration, pub string, envs EnvironmentVariables, passphrase string) (*Exec, error) {
@@ -39,7 +47,10 @@ func NewExec(args []interface{}, retries, priority int, backoff time.Duration, e
 		return nil, ErrRetriesOutsideLimit
 	}
 
-	encryptEnvs := helpers.Encrypt(envs.Serialize(), passphrase)
+	var encryptEnvs []byte
+	if len(envs) != 0 {
+		encryptEnvs = helpers.Encrypt(envs.Serialize(), passphrase) //! left empty without envs so execs of deterministic jobs can be memoised
+	}
 	ex := &Exec{
 		Args:          args,
 		Retries:       retries,
@@ -57,6 +68,26 @@ func NewExec(args []interface{}, retries, priority int, backoff time.Duration, e
 	return ex, nil
 }
 
+//Copy returns a new exec with the settings of e and args, used by workflows that create execs from a template
+func (e Exec) Copy(args []interface{}) *Exec {
+	return &Exec{
+		BypassCache:   e.GetBypassCache(),
+		Args:          args,
+		Retries:       e.GetRetries(),
+		Priority:      e.GetPriority(),
+		Status:        STARTED,
+		Backoff:       e.GetBackoff(),
+		Interval:      e.GetInterval(),
+		ExecutionTime: e.GetExecutionTime(),
+		TTL:           e.GetTTL(),
+		Envs:          e.Envs,
+		Pub:           e.GetPub(),
+		Submitter:     e.GetSubmitter(),
+		Replicas:      e.GetReplicas(),
+		cancel:        make(chan struct{}),
+	}
+}
+
 func (e *Exec) Cancel() {
 	e.cancel <- struct{}{}
 }
@@ -66,9 +97,17 @@ func (e Exec) GetCancelChan() chan struct{} {
 }
 
 func (e Exec) GetEnvs(passphrase string) EnvironmentVariables {
+	if !e.HasEnvs() {
+		return NewEnvVariables()
+	}
 	return DeserializeEnvs(helpers.Decrypt(e.Envs, passphrase))
 }
 
+//HasEnvs returns true if the exec was created with environment variables
+func (e Exec) HasEnvs() bool {
+	return len(e.Envs) != 0
+}
+
 func (e Exec) GetEnvsMap(passphrase string) map[string]interface{} {
 	temp := make(map[string]interface{})
 	envs := e.GetEnvs(passphrase)
@@ -110,6 +149,28 @@ func (e *Exec) SetPriority(p int) error {
 	return nil
 }
 
+func (e Exec) GetReplicas() int {
+	return e.Replicas
+}
+
+func (e *Exec) SetReplicas(r int) error {
+	if r < 0 || r > MaxReplicas {
+		return ErrReplicasOutsideLimit
+	}
+	e.Replicas = r
+	return nil
+}
+
+func (e Exec) GetVerifiers() []string {
+	return e.Verifiers
+}
+
+//SetVerifiers sets the workers that agreed on the result and regenerates the hash so they're covered by it
+func (e *Exec) SetVerifiers(v []string) {
+	e.Verifiers = v
+	e.setHash()
+}
+
 func (e Exec) GetExecutionTime() int64 {
 	return e.ExecutionTime
 }
@@ -161,6 +222,7 @@ func (e Exec) GetStatus() string {
 
 func (e *Exec) SetStatus(s string) {
 	e.Status = s
+	metrics.ExecStatus.WithLabelValues(s).Inc()
 }
 
 func (e Exec) GetArgs() []interface{} {
@@ -192,6 +254,7 @@ func (e *Exec) setHash() {
 			stringified,
 			result,
 			[]byte(e.GetBy()),
+			[]byte(strings.Join(e.GetVerifiers(), "")),
 		},
 		[]byte{},
 	)
@@ -200,6 +263,20 @@ func (e *Exec) setHash() {
 	e.Hash = hash[:]
 }
 
+//GetResultHash returns the hash of the result and error of the exec, used to compare replicated execs
+func (e Exec) GetResultHash() []byte {
+	stringified, err := json.Marshal(e.GetErr())
+	if err != nil {
+		glg.Error(err)
+	}
+	result, err := json.Marshal(e.GetResult())
+	if err != nil {
+		glg.Error(err)
+	}
+	hash := sha256.Sum256(bytes.Join([][]byte{stringified, result}, []byte{}))
+	return hash[:]
+}
+
 func (e Exec) GetTimestamp() int64 {
 	return e.Timestamp
 }
@@ -240,10 +317,65 @@ func (e *Exec) SetBy(by string) {
 	e.By = by
 }
 
-func (e Exec) getPub() string {
+func (e Exec) GetPub() string {
 	return e.Pub
 }
 
+func (e Exec) GetBypassCache() bool {
+	return e.BypassCache
+}
+
+//SetBypassCache runs the exec even if its job is deterministic and a prior exec has the same args
+func (e *Exec) SetBypassCache(b bool) {
+	e.BypassCache = b
+}
+
+func (e Exec) GetCacheStatus() string {
+	return e.CacheStatus
+}
+
+func (e *Exec) SetCacheStatus(s string) {
+	e.CacheStatus = s
+	metrics.MemoLookups.WithLabelValues(s).Inc()
+}
+
+//Memoise answers the exec with the result of a prior exec of the same job and args
+func (e *Exec) Memoise(prior Exec) {
+	e.Hash = prior.GetHash()
+	e.Timestamp = prior.GetTimestamp()
+	e.Duration = prior.GetDuration()
+	e.Result = prior.GetResult()
+	e.Err = prior.GetErr()
+	e.By = prior.GetBy()
+	e.Verifiers = prior.GetVerifiers()
+	e.SetStatus(FINISHED)
+	e.SetCacheStatus(CacheHit)
+}
+
+//GetIdempotencyKey returns the key the exec is deduplicated by, empty if none was set
+func (e Exec) GetIdempotencyKey() string {
+	return e.IdempotencyKey
+}
+
+//SetIdempotencyKey sets a key so a resubmission of the exec returns the first submission instead of running again
+func (e *Exec) SetIdempotencyKey(key string) error {
+	if len(key) > MaxKeyLength {
+		return ErrKeyOutsideLimit
+	}
+	e.IdempotencyKey = key
+	return nil
+}
+
+//GetSubmitter returns the public key of the client that submitted the exec, empty if it wasn't authenticated
+func (e Exec) GetSubmitter() string {
+	return e.Submitter
+}
+
+//SetSubmitter sets the public key of the client that submitted the exec
+func (e *Exec) SetSubmitter(pub string) {
+	e.Submitter = pub
+}
+
 func (e Exec) Serialize() []byte {
 	temp, err := json.Marshal(e)
 	if err != nil {
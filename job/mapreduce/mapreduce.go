@@ -0,0 +1,446 @@
+package mapreduce
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gizo-network/gizo/cache"
+
+	"github.com/gizo-network/gizo/core"
+	"github.com/gizo-network/gizo/job"
+	"github.com/gizo-network/gizo/job/queue"
+	"github.com/gizo-network/gizo/job/queue/qItem"
+	"github.com/gizo-network/gizo/job/workflow"
+	"github.com/kpango/glg"
+)
+
+var (
+	ErrInvalidChunk = errors.New("MapReduce: chunk size must be at least 1")
+	ErrNoInputs     = errors.New("MapReduce: no inputs")
+	ErrNoTemplate   = errors.New("MapReduce: no exec template")
+	ErrStageFailed  = errors.New("MapReduce: a stage had failed execs")
+)
+
+// stages, steps of the workflow record
+const (
+	MapStage     = "map"
+	CombineStage = "combine"
+	ReduceStage  = "reduce"
+)
+
+//! limit overridden by config
+var (
+	MaxExecs = 10000 // max number of execs in a stage of a map/reduce
+)
+
+//MapReduce Inputs split into chunks mapped in parallel, mapper outputs grouped by key and each group reduced
+//
+//mappers are called with a chunk of inputs and return a map, values of the same key across mappers are grouped,
+//results that aren't maps are grouped under an empty key. combiners and reducers are called with a key and its values.
+//
+//each stage is fed by the one before, with FAIL_FAST a failed exec cancels the rest of its stage and with SKIP_DEPENDENTS
+//the stage finishes, later stages aren't run either way. with CONTINUE later stages run without the failed results
+type MapReduce struct {
+	mapper        string // job id
+	combiner      string // job id, no combine step if empty
+	reducer       string // job id
+	inputs        []interface{}
+	chunk         int       // inputs per mapper exec and values per combiner exec
+	template      *job.Exec // settings of the execs created for each stage
+	bc            *core.BlockChain
+	pq            *queue.JobPriorityQueue
+	jc            *cache.JobCache
+	policy        workflow.Policy
+	compensations job.JobRequestMultiple
+	mapped        job.JobRequestMultiple
+	combined      job.JobRequestMultiple
+	result        job.JobRequestMultiple
+	reduced       map[string]interface{}
+	execs         []*job.Exec // execs queued by every stage, cancelled if the map/reduce is
+	mu            *sync.Mutex
+	err           error
+	length        int
+	status        string
+	tracker       *workflow.Tracker
+	cancel        chan struct{}
+}
+
+//NewMapReduce returns a map/reduce of inputs, combiner can be empty
+func NewMapReduce(mapper, combiner, reducer string, inputs []interface{}, chunk int, template *job.Exec, bc *core.BlockChain, pq *queue.JobPriorityQueue, jc *cache.JobCache) (*MapReduce, error) {
+	if chunk < 1 {
+		return nil, ErrInvalidChunk
+	}
+	if len(inputs) == 0 {
+		return nil, ErrNoInputs
+	}
+	if template == nil {
+		return nil, ErrNoTemplate
+	}
+	length := (len(inputs) + chunk - 1) / chunk
+	if length > MaxExecs {
+		return nil, job.ErrJobsLenRange
+	}
+	mr := &MapReduce{
+		mapper:   mapper,
+		combiner: combiner,
+		reducer:  reducer,
+		inputs:   inputs,
+		chunk:    chunk,
+		template: template,
+		bc:       bc,
+		pq:       pq,
+		jc:       jc,
+		policy:   workflow.DefaultPolicy(),
+		length:   length,
+		mu:       new(sync.Mutex),
+		tracker:  workflow.NewTracker("mapreduce"),
+		cancel:   make(chan struct{}, 1),
+	}
+	return mr, nil
+}
+
+//GetID returns the workflow id
+func (mr *MapReduce) GetID() string {
+	return mr.tracker.GetID()
+}
+
+//GetRecord returns the workflow record, a step is added as each stage starts
+func (mr *MapReduce) GetRecord() workflow.Record {
+	return mr.tracker.GetRecord()
+}
+
+//SetOwner sets the pub of the client that submitted the map/reduce
+func (mr *MapReduce) SetOwner(pub string) {
+	mr.tracker.SetOwner(pub)
+}
+
+//SetStore persists the workflow record in store
+func (mr *MapReduce) SetStore(s *workflow.Store) {
+	mr.tracker.SetStore(s)
+}
+
+//SetPolicy sets what the map/reduce does when an exec fails
+func (mr *MapReduce) SetPolicy(p workflow.Policy) {
+	mr.policy = p
+}
+
+func (mr *MapReduce) GetPolicy() workflow.Policy {
+	return mr.policy
+}
+
+//GetCompensations returns the execs of the on-failure job
+func (mr *MapReduce) GetCompensations() job.JobRequestMultiple {
+	return mr.compensations
+}
+
+//Cancel cancels the map/reduce, it doesn't block if the map/reduce isn't dispatching
+func (mr *MapReduce) Cancel() {
+	select {
+	case mr.cancel <- struct{}{}:
+	default:
+	}
+}
+
+func (mr *MapReduce) GetCancelChan() chan struct{} {
+	return mr.cancel
+}
+
+func (mr *MapReduce) GetMapper() string {
+	return mr.mapper
+}
+
+func (mr *MapReduce) GetCombiner() string {
+	return mr.combiner
+}
+
+func (mr *MapReduce) GetReducer() string {
+	return mr.reducer
+}
+
+func (mr *MapReduce) GetInputs() []interface{} {
+	return mr.inputs
+}
+
+func (mr *MapReduce) GetChunk() int {
+	return mr.chunk
+}
+
+//GetStatus returns status
+func (mr *MapReduce) GetStatus() string {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	return mr.status
+}
+
+//! set by the cancel goroutine while stages run
+func (mr *MapReduce) setStatus(s string) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	mr.status = s
+}
+
+//GetErr returns ErrStageFailed if a stage had failed execs or job.ErrJobsLenRange if a stage had more than MaxExecs
+func (mr *MapReduce) GetErr() error {
+	return mr.err
+}
+
+func (mr *MapReduce) getLength() int {
+	return mr.length
+}
+
+func (mr *MapReduce) getPQ() *queue.JobPriorityQueue {
+	return mr.pq
+}
+
+func (mr *MapReduce) getBC() *core.BlockChain {
+	return mr.bc
+}
+
+func (mr *MapReduce) getJC() *cache.JobCache {
+	return mr.jc
+}
+
+//GetMapped returns the mapper execs
+func (mr *MapReduce) GetMapped() job.JobRequestMultiple {
+	return mr.mapped
+}
+
+//GetCombined returns the combiner execs
+func (mr *MapReduce) GetCombined() job.JobRequestMultiple {
+	return mr.combined
+}
+
+//Result returns the reducer execs
+func (mr *MapReduce) Result() job.JobRequestMultiple {
+	return mr.result
+}
+
+//Reduced returns the reducer results keyed by group
+func (mr *MapReduce) Reduced() map[string]interface{} {
+	return mr.reduced
+}
+
+//splits values into chunks of size
+func split(values []interface{}, size int) [][]interface{} {
+	var chunks [][]interface{}
+	for size < len(values) {
+		chunks = append(chunks, values[:size])
+		values = values[size:]
+	}
+	return append(chunks, values)
+}
+
+//groups mapper or combiner outputs by key, failed execs are left out
+func group(execs []*job.Exec, keyed bool) map[string][]interface{} {
+	groups := make(map[string][]interface{})
+	for _, exec := range execs {
+		if workflow.Failed(exec) {
+			continue
+		}
+		result := exec.GetResult()
+		if keyed {
+			//! combiners return the combined value of the key they were called with
+			key := fmt.Sprint(exec.GetArgs()[0])
+			groups[key] = append(groups[key], result)
+			continue
+		}
+		v := reflect.ValueOf(result)
+		if result == nil || v.Kind() != reflect.Map {
+			groups[""] = append(groups[""], result)
+			continue
+		}
+		for _, k := range v.MapKeys() {
+			key := fmt.Sprint(k.Interface())
+			groups[key] = append(groups[key], v.MapIndex(k).Interface())
+		}
+	}
+	return groups
+}
+
+//returns the keys of groups in order so execs are created deterministically
+func keys(groups map[string][]interface{}) []string {
+	var sorted []string
+	for key := range groups {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+//queues an exec of a job per args in parallel and returns them in the order of args
+func (mr *MapReduce) run(stage, id string, args [][]interface{}, cancelled *int32) (job.JobRequestMultiple, bool) {
+	mr.tracker.AddStep(stage, id, len(args))
+	mr.tracker.Queueing(stage)
+	var executed job.JobRequestMultiple
+	executed.SetID(id)
+	var j *job.Job
+	var err error
+	j, err = mr.getJC().Get(id)
+	if err != nil {
+		glg.Warn("MapReduce: Unable to find job - " + id)
+		for _, a := range args {
+			exec := mr.template.Copy(a)
+			exec.SetErr("Unable to find job - " + id)
+			executed.AppendExec(exec)
+			mr.tracker.Returned(stage, exec)
+		}
+		return executed, false
+	}
+	//! each exec has its own results channel so results keep the order of args
+	results := make([]chan qItem.Item, len(args))
+	var queued job.JobRequestMultiple
+	for i, a := range args {
+		results[i] = make(chan qItem.Item, 1)
+		exec := mr.template.Copy(a)
+		queued.AppendExec(exec)
+		//! checked under the lock so the exec is either cancelled here or by the cancel goroutine
+		mr.mu.Lock()
+		mr.execs = append(mr.execs, exec)
+		stop := atomic.LoadInt32(cancelled) == 1
+		mr.mu.Unlock()
+		if stop {
+			exec.SetStatus(job.CANCELLED)
+			results[i] <- qItem.NewItem(*j, exec, results[i], mr.GetCancelChan())
+			continue
+		}
+		go func(ex *job.Exec, res chan qItem.Item) {
+			if ex.GetExecutionTime() != 0 {
+				glg.Warn("MapReduce: Queuing in " + strconv.FormatFloat(time.Unix(ex.GetExecutionTime(), 0).Sub(time.Now()).Seconds(), 'f', -1, 64) + " nanoseconds")
+				time.Sleep(time.Nanosecond * time.Duration(time.Unix(ex.GetExecutionTime(), 0).Sub(time.Now()).Nanoseconds()))
+			}
+			mr.getPQ().Push(*j, ex, res, mr.GetCancelChan())
+		}(exec, results[i])
+	}
+	ok := true
+	for _, res := range results {
+		exec := (<-res).GetExec()
+		if workflow.Failed(exec) && exec.GetErr() != workflow.ErrFailFast.Error() {
+			ok = false
+			if mr.GetPolicy().FailFast() {
+				glg.Warn("MapReduce: Exec failed, cancelling remaining execs of stage - " + stage)
+				workflow.Halt([]job.JobRequestMultiple{queued})
+			}
+		}
+		executed.AppendExec(exec)
+		mr.tracker.Returned(stage, exec)
+	}
+	return executed, ok
+}
+
+//Dispatch executes the map/reduce
+func (mr *MapReduce) Dispatch() {
+	mr.setStatus(job.RUNNING)
+	mr.tracker.Start()
+	var cancelled int32
+	closeCancel := make(chan struct{})
+	var wg sync.WaitGroup
+	//! watch cancel channel
+	wg.Add(1)
+	go func() {
+		select {
+		case <-mr.cancel:
+			atomic.StoreInt32(&cancelled, 1)
+			glg.Warn("MapReduce: Cancelling jobs")
+			mr.mu.Lock()
+			execs := append([]*job.Exec{}, mr.execs...)
+			mr.mu.Unlock()
+			for _, exec := range execs {
+				if exec.GetStatus() == job.RUNNING || exec.GetStatus() == job.RETRYING {
+					exec.Cancel()
+				}
+				if exec.GetResult() == nil {
+					exec.SetStatus(job.CANCELLED) //! queued execs are returned when popped
+				}
+			}
+			mr.setStatus(job.CANCELLED)
+			break
+		case <-closeCancel:
+			break
+		}
+		wg.Done()
+	}()
+	mr.dispatch(&cancelled)
+	mr.compensations = workflow.Compensate(mr.GetPolicy(), []job.JobRequestMultiple{mr.GetMapped(), mr.GetCombined(), mr.Result()}, mr.GetCancelChan(), mr.getPQ(), mr.getJC())
+	close(closeCancel) //! never blocks if the map/reduce was cancelled as it finished
+	wg.Wait()
+	if atomic.LoadInt32(&cancelled) == 0 {
+		if mr.GetErr() == job.ErrJobsLenRange {
+			mr.setStatus(job.FAILED) //! a stage wasn't run
+		} else {
+			mr.setStatus(workflow.Status([]job.JobRequestMultiple{mr.GetMapped(), mr.GetCombined(), mr.Result()}))
+		}
+	} else {
+		mr.setStatus(job.CANCELLED)
+	}
+	mr.tracker.Finish(mr.GetStatus())
+}
+
+//returns false if later stages shouldn't run after a stage with failed execs
+func (mr *MapReduce) proceed(ok bool) bool {
+	if ok {
+		return true
+	}
+	mr.err = ErrStageFailed
+	return mr.GetPolicy().GetMode() == workflow.ContinueOnError
+}
+
+//runs the map, combine and reduce stages, stopping at a stage with more than MaxExecs or after one with failed execs
+//unless the policy continues on error
+func (mr *MapReduce) dispatch(cancelled *int32) {
+	mr.setStatus("Mapping inputs with job - " + mr.GetMapper())
+	var ok bool
+	var args [][]interface{}
+	for _, chunk := range split(mr.GetInputs(), mr.GetChunk()) {
+		args = append(args, []interface{}{chunk})
+	}
+	mr.mapped, ok = mr.run(MapStage, mr.GetMapper(), args, cancelled)
+	if !mr.proceed(ok) {
+		return
+	}
+	groups := group(mr.GetMapped().GetExec(), false)
+
+	if mr.GetCombiner() != "" {
+		mr.setStatus("Combining groups with job - " + mr.GetCombiner())
+		args = nil
+		for _, key := range keys(groups) {
+			for _, values := range split(groups[key], mr.GetChunk()) {
+				args = append(args, []interface{}{key, values})
+			}
+		}
+		if len(args) > MaxExecs {
+			mr.err = job.ErrJobsLenRange
+			return
+		}
+		mr.combined, ok = mr.run(CombineStage, mr.GetCombiner(), args, cancelled)
+		if !mr.proceed(ok) {
+			return
+		}
+		groups = group(mr.GetCombined().GetExec(), true)
+	}
+
+	mr.setStatus("Reducing groups with job - " + mr.GetReducer())
+	args = nil
+	sorted := keys(groups)
+	for _, key := range sorted {
+		args = append(args, []interface{}{key, groups[key]})
+	}
+	if len(args) > MaxExecs {
+		mr.err = job.ErrJobsLenRange
+		return
+	}
+	mr.result, ok = mr.run(ReduceStage, mr.GetReducer(), args, cancelled)
+	mr.proceed(ok)
+	mr.reduced = make(map[string]interface{})
+	for i, exec := range mr.Result().GetExec() {
+		if workflow.Failed(exec) {
+			continue
+		}
+		mr.reduced[sorted[i]] = exec.GetResult()
+	}
+}
@@ -0,0 +1,38 @@
+package mapreduce
+
+import (
+	"testing"
+
+	"github.com/gizo-network/gizo/job"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroup(t *testing.T) {
+	template, err := job.NewExec([]interface{}{}, 0, job.NORMAL, 0, 0, 0, 0, "", job.NewEnvVariables(), "")
+	assert.NoError(t, err)
+	assert.Equal(t, [][]interface{}{{1, 2}, {3, 4}, {5}}, split([]interface{}{1, 2, 3, 4, 5}, 2))
+
+	first := template.Copy([]interface{}{[]interface{}{"a b"}})
+	first.SetResult(map[string]interface{}{"a": 1, "b": 1})
+	second := template.Copy([]interface{}{[]interface{}{"a"}})
+	second.SetResult(map[string]interface{}{"a": 1})
+	third := template.Copy([]interface{}{[]interface{}{}})
+	third.SetResult(nil)
+	groups := group([]*job.Exec{first, second, third}, false)
+	assert.Equal(t, []interface{}{1, 1}, groups["a"])
+	assert.Equal(t, []interface{}{1}, groups["b"])
+	assert.Equal(t, []interface{}{nil}, groups[""])
+	assert.Equal(t, []string{"", "a", "b"}, keys(groups))
+
+	combined := template.Copy([]interface{}{"a", []interface{}{1, 1}})
+	combined.SetResult(2)
+	assert.Equal(t, map[string][]interface{}{"a": {2}}, group([]*job.Exec{combined}, true))
+
+	_, err = NewMapReduce("mapper", "", "reducer", []interface{}{1}, 0, template, nil, nil, nil)
+	assert.Equal(t, ErrInvalidChunk, err)
+	_, err = NewMapReduce("mapper", "", "reducer", nil, 1, template, nil, nil, nil)
+	assert.Equal(t, ErrNoInputs, err)
+	mr, err := NewMapReduce("mapper", "", "reducer", []interface{}{1, 2, 3}, 2, template, nil, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, mr.getLength())
+}
@@ -0,0 +1,172 @@
+package mapreduce_test
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gizo-network/gizo/cache"
+	"github.com/gizo-network/gizo/core"
+	"github.com/gizo-network/gizo/crypt"
+	"github.com/gizo-network/gizo/job"
+	"github.com/gizo-network/gizo/job/mapreduce"
+	"github.com/gizo-network/gizo/job/queue"
+	"github.com/stretchr/testify/assert"
+)
+
+//sets up a job cache with mapper, combiner and reducer jobs, returns the job ids keyed by name
+func setup(t *testing.T) (*cache.JobCache, map[string]string, func()) {
+	dir, err := ioutil.TempDir("", "gizo-mapreduce")
+	assert.NoError(t, err)
+	core.SetDataDir(dir)
+	jc := cache.NewJobCache(core.CreateBlockChain("test"))
+	priv, _ := crypt.GenKeys()
+	ids := make(map[string]string)
+	for _, name := range []string{"mapper", "combiner", "reducer"} {
+		j := job.NewJob("func Stage(){return 1}", name, false, hex.EncodeToString(priv))
+		jc.Set(j.GetID(), j.Serialize())
+		ids[name] = j.GetID()
+	}
+	return jc, ids, func() {
+		core.SetDataDir("")
+		os.RemoveAll(dir)
+	}
+}
+
+//counts words with a fake worker, mappers count the words of their chunk, combiners and reducers sum the counts of a key
+func work(pq *queue.JobPriorityQueue, ids map[string]string, stop chan struct{}) {
+	names := make(map[string]string)
+	for name, id := range ids {
+		names[id] = name
+	}
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		if pq.Empty() {
+			time.Sleep(time.Millisecond)
+			continue
+		}
+		item := pq.Pop()
+		exec := item.GetExec()
+		if exec == nil {
+			continue
+		}
+		args := exec.GetArgs()
+		if names[item.GetJob().GetID()] == "mapper" {
+			counts := make(map[string]interface{})
+			for _, word := range args[0].([]interface{}) {
+				n, _ := counts[word.(string)].(int)
+				counts[word.(string)] = n + 1
+			}
+			exec.SetResult(counts)
+		} else {
+			sum := 0
+			for _, n := range args[1].([]interface{}) {
+				sum += n.(int)
+			}
+			exec.SetResult(sum)
+		}
+		exec.SetStatus(job.FINISHED)
+		item.ResultsChan() <- item
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	jc, ids, cleanup := setup(t)
+	defer cleanup()
+	pq := queue.NewJobPriorityQueue()
+	template, err := job.NewExec([]interface{}{}, 0, job.NORMAL, 0, 0, 0, 0, "", job.NewEnvVariables(), "")
+	assert.NoError(t, err)
+	stop := make(chan struct{})
+	defer close(stop)
+	go work(pq, ids, stop)
+
+	inputs := []interface{}{"a", "b", "a", "c", "a"}
+	mr, err := mapreduce.NewMapReduce(ids["mapper"], ids["combiner"], ids["reducer"], inputs, 2, template, nil, pq, jc)
+	assert.NoError(t, err)
+	mr.Dispatch()
+	assert.Equal(t, job.FINISHED, mr.GetStatus())
+	assert.NoError(t, mr.GetErr())
+	assert.Len(t, mr.GetMapped().GetExec(), 3)
+	assert.Len(t, mr.GetCombined().GetExec(), 4) //! the three counts of a are combined in chunks of two
+	assert.Equal(t, map[string]interface{}{"a": 3, "b": 1, "c": 1}, mr.Reduced())
+
+	_, err = mapreduce.NewMapReduce(ids["mapper"], "", ids["reducer"], inputs, 2, nil, nil, pq, jc)
+	assert.Equal(t, mapreduce.ErrNoTemplate, err)
+}
+
+func TestDispatchStageLimit(t *testing.T) {
+	jc, ids, cleanup := setup(t)
+	defer cleanup()
+	pq := queue.NewJobPriorityQueue()
+	template, err := job.NewExec([]interface{}{}, 0, job.NORMAL, 0, 0, 0, 0, "", job.NewEnvVariables(), "")
+	assert.NoError(t, err)
+	stop := make(chan struct{})
+	defer close(stop)
+	go work(pq, ids, stop)
+	defer func(max int) { mapreduce.MaxExecs = max }(mapreduce.MaxExecs)
+	mapreduce.MaxExecs = 2
+
+	//! a single mapper outputs three keys, more reducers than the limit
+	inputs := []interface{}{"a", "b", "c"}
+	_, err = mapreduce.NewMapReduce(ids["mapper"], "", ids["reducer"], inputs, 1, template, nil, pq, jc)
+	assert.Equal(t, job.ErrJobsLenRange, err)
+	mr, err := mapreduce.NewMapReduce(ids["mapper"], "", ids["reducer"], inputs, 3, template, nil, pq, jc)
+	assert.NoError(t, err)
+	mr.Dispatch()
+	assert.Equal(t, job.ErrJobsLenRange, mr.GetErr())
+	assert.Equal(t, job.FAILED, mr.GetStatus())
+	assert.Len(t, mr.GetMapped().GetExec(), 1)
+	assert.Empty(t, mr.Result().GetExec())
+
+	//! same for combiners
+	mr, err = mapreduce.NewMapReduce(ids["mapper"], ids["combiner"], ids["reducer"], inputs, 3, template, nil, pq, jc)
+	assert.NoError(t, err)
+	mr.Dispatch()
+	assert.Equal(t, job.ErrJobsLenRange, mr.GetErr())
+	assert.Empty(t, mr.GetCombined().GetExec())
+	assert.Empty(t, mr.Result().GetExec())
+}
+
+func TestCancel(t *testing.T) {
+	jc, ids, cleanup := setup(t)
+	defer cleanup()
+	pq := queue.NewJobPriorityQueue()
+	template, err := job.NewExec([]interface{}{}, 0, job.NORMAL, 0, 0, 0, 0, "", job.NewEnvVariables(), "")
+	assert.NoError(t, err)
+
+	mr, err := mapreduce.NewMapReduce(ids["mapper"], "", ids["reducer"], []interface{}{"a", "b"}, 1, template, nil, pq, jc)
+	assert.NoError(t, err)
+	mr.Cancel() //! doesn't block before the map/reduce is dispatched
+	<-mr.GetCancelChan()
+
+	done := make(chan struct{})
+	go func() {
+		mr.Dispatch()
+		close(done)
+	}()
+	for pq.Len() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	mr.Cancel()
+	for mr.GetStatus() != job.CANCELLED {
+		time.Sleep(time.Millisecond)
+	}
+	//! queued execs are marked cancelled and returned once popped, as the dispatcher does
+	for !pq.Empty() {
+		item := pq.Pop()
+		assert.Equal(t, job.CANCELLED, item.GetExec().GetStatus())
+		item.ResultsChan() <- item
+	}
+	<-done
+
+	assert.Equal(t, job.CANCELLED, mr.GetStatus())
+	assert.Len(t, mr.GetMapped().GetExec(), 2)
+	assert.Empty(t, mr.Result().GetExec()) //! the reduce stage isn't run
+	mr.Cancel()                            //! doesn't block once the map/reduce is done
+}
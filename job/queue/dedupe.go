@@ -0,0 +1,141 @@
+package queue
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/gizo-network/gizo/job"
+	"github.com/gizo-network/gizo/job/queue/qItem"
+	"github.com/kpango/glg"
+)
+
+var (
+	ErrKeyReused     = errors.New("JobPriorityQueue: idempotency key was used for an exec of another job or with other args")
+	ErrClaimReleased = errors.New("JobPriorityQueue: exec of idempotency key was dropped before it was done, submit it again")
+)
+
+//! dedupe overridden by config
+var (
+	DedupeWindow = time.Hour * 24 // time a submission is returned to resubmissions with the same idempotency key, 0 disables dedupe
+)
+
+//first submission of an idempotency key
+type claim struct {
+	exec        *job.Exec
+	fingerprint []byte // resubmissions must be of the same job and args
+	at          time.Time
+	result      *job.Exec    // set once the exec is done
+	waiters     []qItem.Item // resubmissions waiting for the result
+}
+
+//returns the key an exec is deduplicated by, empty if it has no idempotency key or its submitter wasn't authenticated
+func dedupeKey(exec *job.Exec) string {
+	if exec.GetIdempotencyKey() == "" || exec.GetSubmitter() == "" || DedupeWindow <= 0 {
+		return ""
+	}
+	return exec.GetSubmitter() + ":" + exec.GetIdempotencyKey()
+}
+
+//returns a hash of the job id and args of an exec
+func fingerprint(jobID string, exec *job.Exec) []byte {
+	args, err := json.Marshal(exec.GetArgs())
+	if err != nil {
+		glg.Error(err)
+	}
+	hash := sha256.Sum256(append([]byte(jobID+":"), args...))
+	return hash[:]
+}
+
+//sends a copy of result to a resubmission's sender
+func deliver(item qItem.Item, result *job.Exec) {
+	temp := *result
+	item.SetExec(&temp)
+	go func() {
+		item.ResultsChan() <- item
+	}()
+}
+
+//drops claims older than DedupeWindow, waiting claims are kept until their exec is done
+func (pq JobPriorityQueue) expire() {
+	for key, c := range pq.claims {
+		if c.result != nil && time.Since(c.at) > DedupeWindow {
+			delete(pq.claims, key)
+		}
+	}
+}
+
+//returns true if key was claimed, the item is answered with the claim's result or waits for it.
+//returns ErrKeyReused if the claim is of another job or args
+func (pq JobPriorityQueue) duplicate(key string, item qItem.Item) (bool, error) {
+	pq.expire()
+	c, ok := pq.claims[key]
+	if !ok {
+		return false, nil
+	}
+	if !bytes.Equal(c.fingerprint, fingerprint(item.GetJob().GetID(), item.GetExec())) {
+		return false, ErrKeyReused
+	}
+	glg.Warn("JobPriorityQueue: duplicate submission - " + item.GetExec().GetIdempotencyKey())
+	if c.result != nil {
+		deliver(item, c.result)
+	} else {
+		c.waiters = append(c.waiters, item)
+	}
+	return true, nil
+}
+
+//looks up an exec of key in blocks created within DedupeWindow, returns it as a claim
+func (pq JobPriorityQueue) lookup(exec *job.Exec) *claim {
+	if pq.bc == nil {
+		return nil
+	}
+	found, jobID, err := pq.bc.FindIdempotentExec(exec.GetSubmitter(), exec.GetIdempotencyKey(), time.Now().Add(-DedupeWindow).Unix())
+	if err != nil {
+		return nil
+	}
+	return &claim{exec: found, fingerprint: fingerprint(jobID, found), at: time.Now(), result: found}
+}
+
+//records the result of a claimed exec and answers resubmissions waiting for it,
+//cancelled and rejected execs release their key so it can be submitted again
+func (pq JobPriorityQueue) settle(exec *job.Exec, result job.Exec) {
+	for key, c := range pq.claims {
+		if c.exec != exec {
+			continue
+		}
+		for _, waiter := range c.waiters {
+			deliver(waiter, &result)
+		}
+		c.waiters = nil
+		c.result = &result
+		c.at = time.Now()
+		switch result.GetStatus() {
+		case job.CANCELLED, job.REJECTED:
+			delete(pq.claims, key)
+		}
+		return
+	}
+}
+
+//releases the key of a claimed exec that's dropped before it's done, resubmissions waiting for it are answered with
+//the exec cancelled so they can be submitted again
+func (pq JobPriorityQueue) release(exec *job.Exec) {
+	for key, c := range pq.claims {
+		if c.exec != exec {
+			continue
+		}
+		if c.result == nil {
+			result := *exec
+			result.SetStatus(job.CANCELLED)
+			result.SetErr(ErrClaimReleased.Error())
+			for _, waiter := range c.waiters {
+				deliver(waiter, &result)
+			}
+			delete(pq.claims, key)
+		}
+		return
+	}
+}
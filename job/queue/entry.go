@@ -0,0 +1,83 @@
+package queue
+
+import (
+	"time"
+
+	"github.com/gizo-network/gizo/job"
+	"github.com/gizo-network/gizo/job/queue/qItem"
+)
+
+//Entry is a snapshot of an item waiting in the job queue
+type Entry struct {
+	ID        string     `json:"id"`
+	Item      qItem.Item `json:"item"`
+	Priority  int        `json:"priority"`
+	Effective int        `json:"effective"` // priority raised by the time waited
+	QueuedAt  int64      `json:"queued_at"`
+	queued    time.Time
+}
+
+func (e Entry) GetID() string {
+	return e.ID
+}
+
+func (e Entry) GetItem() qItem.Item {
+	return e.Item
+}
+
+func (e Entry) GetPriority() int {
+	return e.Priority
+}
+
+func (e Entry) GetEffective() int {
+	return e.Effective
+}
+
+func (e Entry) GetQueuedAt() int64 {
+	return e.QueuedAt
+}
+
+//Waited returns how long the item has been waiting in the queue
+func (e Entry) Waited() time.Duration {
+	return time.Since(e.queued)
+}
+
+//effective priority of the item after waiting, it rises a level every AgingRate up to HIGH and past HIGH after MaxWait
+func (e Entry) age() int {
+	waited := e.Waited()
+	if MaxWait > 0 && waited >= MaxWait {
+		return Overdue
+	}
+	effective := e.GetPriority()
+	if AgingRate > 0 {
+		effective += int(waited / AgingRate)
+	}
+	if effective > job.HIGH {
+		effective = job.HIGH
+	}
+	if effective < e.GetEffective() {
+		return e.GetEffective()
+	}
+	return effective
+}
+
+//returns when the item's effective priority next rises, zero if it won't rise
+func (e Entry) promotes() time.Time {
+	var next time.Time
+	if e.GetEffective() == Overdue {
+		return next
+	}
+	if MaxWait > 0 {
+		next = e.queued.Add(MaxWait)
+	}
+	if AgingRate > 0 && e.GetEffective() < job.HIGH {
+		levels := int(e.Waited() / AgingRate)
+		if raised := e.GetEffective() - e.GetPriority(); raised > levels {
+			levels = raised
+		}
+		if rises := e.queued.Add(AgingRate * time.Duration(levels+1)); next.IsZero() || rises.Before(next) {
+			next = rises
+		}
+	}
+	return next
+}
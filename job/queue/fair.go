@@ -0,0 +1,216 @@
+package queue
+
+import (
+	"time"
+
+	lane "github.com/Lobarr/lane"
+	"github.com/gizo-network/gizo/job"
+)
+
+//Quota limits the execs of a submitter, a zero limit is unlimited
+type Quota struct {
+	MaxQueued  int           // execs waiting in the queue
+	MaxRunning int           // execs dispatched at once, execs past the limit wait in the queue
+	MaxCPU     time.Duration // exec time used per window
+	Window     time.Duration
+}
+
+//! fair-share overridden by config
+var (
+	DefaultQuota  = Quota{Window: time.Hour}
+	DefaultWeight = 1
+	Weights       = map[string]int{} // execs popped per round keyed by submitter
+)
+
+//Usage is a snapshot of a submitter's share of the queue
+type Usage struct {
+	Pub     string        `json:"pub"`
+	Weight  int           `json:"weight"`
+	Queued  int           `json:"queued"`
+	Running int           `json:"running"`
+	CPU     time.Duration `json:"cpu"` // exec time used in the current window
+}
+
+//exec time charged to a submitter
+type charge struct {
+	at       time.Time
+	duration time.Duration
+}
+
+//execs and usage of a submitter
+type submitter struct {
+	lane    *lane.PQueue
+	running int
+	charges []charge
+	credit  int // execs left to pop in the current round
+}
+
+//returns the exec time used by the submitter in the quota window
+func (s *submitter) cpu() time.Duration {
+	var kept []charge
+	var used time.Duration
+	for _, c := range s.charges {
+		if time.Since(c.at) < DefaultQuota.Window {
+			kept = append(kept, c)
+			used += c.duration
+		}
+	}
+	s.charges = kept
+	return used
+}
+
+//weighted round-robin over the submitters with queued execs
+type fairShare struct {
+	submitters map[string]*submitter
+	order      []string
+	cursor     int
+	running    map[*job.Exec]string // dispatched execs and their submitter
+}
+
+func newFairShare() *fairShare {
+	return &fairShare{
+		submitters: make(map[string]*submitter),
+		running:    make(map[*job.Exec]string),
+	}
+}
+
+//returns the key an exec is charged to, its authenticated submitter. execs of unauthenticated clients share a submitter
+//since the pub of an exec is set by the client
+func submitterOf(exec *job.Exec) string {
+	return exec.GetSubmitter()
+}
+
+//returns the weight of a submitter
+func weight(pub string) int {
+	if w, ok := Weights[pub]; ok && w > 0 {
+		return w
+	}
+	return DefaultWeight
+}
+
+//returns the submitter of pub, adding it to the round
+func (f *fairShare) get(pub string) *submitter {
+	s, ok := f.submitters[pub]
+	if !ok {
+		s = &submitter{lane: lane.NewPQueue(lane.MAXPQ)}
+		f.submitters[pub] = s
+		f.order = append(f.order, pub)
+	}
+	return s
+}
+
+//checks the quota of a submitter before an exec is queued
+func (f *fairShare) admit(pub string) error {
+	s := f.get(pub)
+	if DefaultQuota.MaxQueued > 0 && s.lane.Size() >= DefaultQuota.MaxQueued {
+		return ErrQueuedQuota
+	}
+	if DefaultQuota.MaxCPU > 0 && s.cpu() >= DefaultQuota.MaxCPU {
+		return ErrCPUQuota
+	}
+	return nil
+}
+
+//returns true if a submitter has an exec that can be popped
+func (f *fairShare) ready(s *submitter) bool {
+	return s.lane.Size() != 0 && (DefaultQuota.MaxRunning <= 0 || s.running < DefaultQuota.MaxRunning)
+}
+
+//returns the submitter whose exec is popped next or nil if none can be popped
+func (f *fairShare) next() *submitter {
+	f.prune()
+	for range f.order {
+		if f.cursor >= len(f.order) {
+			f.cursor = 0
+		}
+		pub := f.order[f.cursor]
+		s := f.submitters[pub]
+		if !f.ready(s) {
+			s.credit = 0
+			f.cursor++
+			continue
+		}
+		if s.credit == 0 {
+			s.credit = weight(pub)
+		}
+		s.credit--
+		if s.credit == 0 {
+			f.cursor++
+		}
+		return s
+	}
+	return nil
+}
+
+//drops submitters without queued or running execs and charges in the window
+func (f *fairShare) prune() {
+	var order []string
+	for i, pub := range f.order {
+		s := f.submitters[pub]
+		if s.lane.Size() == 0 && s.running == 0 && s.cpu() == 0 {
+			delete(f.submitters, pub)
+			if i < f.cursor {
+				f.cursor--
+			}
+			continue
+		}
+		order = append(order, pub)
+	}
+	f.order = order
+}
+
+//marks an exec as dispatched
+func (f *fairShare) start(exec *job.Exec) {
+	pub := submitterOf(exec)
+	f.get(pub).running++
+	f.running[exec] = pub
+}
+
+//marks an exec as no longer dispatched, its exec time is charged to the submitter
+func (f *fairShare) stop(exec *job.Exec, duration time.Duration) {
+	pub, ok := f.running[exec]
+	if !ok {
+		return
+	}
+	delete(f.running, exec)
+	s := f.get(pub)
+	s.running--
+	if duration > 0 {
+		s.charges = append(s.charges, charge{at: time.Now(), duration: duration})
+	}
+}
+
+//returns the number of queued execs
+func (f *fairShare) len() int {
+	var size int
+	for _, s := range f.submitters {
+		size += s.lane.Size()
+	}
+	return size
+}
+
+//returns true if no exec can be popped
+func (f *fairShare) empty() bool {
+	for _, s := range f.submitters {
+		if f.ready(s) {
+			return false
+		}
+	}
+	return true
+}
+
+//returns the usage of every submitter
+func (f *fairShare) usage() []Usage {
+	var usage []Usage
+	for _, pub := range f.order {
+		s := f.submitters[pub]
+		usage = append(usage, Usage{
+			Pub:     pub,
+			Weight:  weight(pub),
+			Queued:  s.lane.Size(),
+			Running: s.running,
+			CPU:     s.cpu(),
+		})
+	}
+	return usage
+}
@@ -0,0 +1,24 @@
+package queue
+
+import (
+	"github.com/gizo-network/gizo/job"
+	"github.com/kpango/glg"
+)
+
+//returns a prior exec of a deterministic job with the exec's args and pub, nil if the exec has to be run
+func (pq JobPriorityQueue) memo(j job.Job, exec *job.Exec) *job.Exec {
+	if !j.GetDeterministic() || pq.jc == nil {
+		return nil
+	}
+	if exec.GetBypassCache() {
+		exec.SetCacheStatus(job.CacheBypass)
+		return nil
+	}
+	prior, err := pq.jc.FindExec(j, exec)
+	if err != nil {
+		exec.SetCacheStatus(job.CacheMiss)
+		return nil
+	}
+	glg.Info("JobPriorityQueue: answered exec from prior exec - " + j.GetID())
+	return prior
+}
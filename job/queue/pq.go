@@ -1,17 +1,70 @@
 package queue
 
 import (
-	lane "github.com/Lobarr/lane"
+	"bytes"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/gizo-network/gizo/cache"
+	"github.com/gizo-network/gizo/core"
 	"github.com/gizo-network/gizo/job"
 	"github.com/gizo-network/gizo/job/queue/qItem"
+	"github.com/gizo-network/gizo/metrics"
 	"github.com/kpango/glg"
+	uuid "github.com/satori/go.uuid"
+)
+
+var (
+	ErrEntryNotFound = errors.New("JobPriorityQueue: entry not found")
+	ErrQueuedQuota   = errors.New("JobPriorityQueue: submitter reached its quota of queued execs")
+	ErrCPUQuota      = errors.New("JobPriorityQueue: submitter reached its quota of exec time for the window")
+)
+
+//QueueFullError rejects submissions while the queue is over its high-water mark
+type QueueFullError struct {
+	RetryAfter time.Duration // time the submitter should wait before submitting again
+}
+
+func (e QueueFullError) Error() string {
+	return "JobPriorityQueue: queue is over its high-water mark, retry after " + e.RetryAfter.String()
+}
+
+const (
+	JournalBucket   = "jobqueue"      // bolt bucket of a persistent queue
+	ResultRetention = time.Hour * 24  // time results of done execs are kept in the journal
+	Overdue         = job.HIGH + 1    // effective priority of items waiting longer than MaxWait
+	FullRetryAfter  = time.Second * 5 // retry-after of submissions rejected past the high-water mark
+	AgeInterval     = time.Second     // minimum time between agings of the queue
 )
 
+//! aging and back-pressure overridden by config
+var (
+	AgingRate = time.Minute * 2  // time waited for an item to rise a priority level, 0 disables aging
+	MaxWait   = time.Minute * 15 // time waited for an item to be popped before any other priority, 0 disables the bound
+	HighWater = 0                // queued items past which submissions are rejected, 0 is unlimited
+)
+
+//JobPriorityQueue queues execs by priority per submitter and pops them fairly across submitters
 type JobPriorityQueue struct {
-	pq *lane.PQueue
+	fair   *fairShare
+	mu     *sync.Mutex
+	index  map[string]Entry     // items in the queue keyed by entry id
+	queued map[*job.Exec]string // entry ids of the items in the queue
+	aging  *time.Time           // when the next item in the queue rises a priority level
+	db     *bolt.DB             // journal of a persistent queue, nil for an in-memory queue
+	ids    map[*job.Exec]string
+	claims map[string]*claim // first submissions keyed by submitter and idempotency key
+	bc     *core.BlockChain  // searched for execs of idempotency keys that aren't claimed, nil to skip
+	jc     *cache.JobCache   // searched for prior execs of deterministic jobs, nil to skip
 }
 
-func (pq JobPriorityQueue) Push(j job.Job, exec *job.Exec, results chan<- qItem.Item, cancel chan struct{}) {
+//Push queues an exec submitted to the dispatcher, it's rejected and returned to its sender if the submitter is over quota.
+//resubmissions of an idempotency key within DedupeWindow aren't queued, they're returned the first submission's result
+//and rejected if they're of another job or args.
+//execs of deterministic jobs with the args of a prior exec are answered with it instead of being queued
+func (pq JobPriorityQueue) Push(j job.Job, exec *job.Exec, results chan<- qItem.Item, cancel chan struct{}) error {
 	temp := job.Job{
 		ID:             j.GetID(),
 		Hash:           j.GetHash(),
@@ -20,29 +73,384 @@ func (pq JobPriorityQueue) Push(j job.Job, exec *job.Exec, results chan<- qItem.
 		Signature:      j.GetSignature(),
 		SubmissionTime: j.GetSubmissionTime(),
 		Private:        j.GetPrivate(),
+		Deterministic:  j.GetDeterministic(),
 	}
-	pq.GetPQ().Push(qItem.NewItem(temp, exec, results, cancel), exec.GetPriority())
-	glg.Info("JobPriotityQueue: received job")
+	item := qItem.NewItem(temp, exec, results, cancel)
+	if exec.GetReplicas() < 0 || exec.GetReplicas() > job.MaxReplicas {
+		return pq.reject(item, job.ErrReplicasOutsideLimit)
+	}
+	key := dedupeKey(exec)
+	var found *claim
+	if key != "" {
+		pq.mu.Lock()
+		_, claimed := pq.claims[key]
+		pq.mu.Unlock()
+		if !claimed {
+			found = pq.lookup(exec) //! blocks are searched without holding the lock
+		}
+	}
+	var prior *job.Exec
+	if found == nil {
+		prior = pq.memo(j, exec)
+	}
+	pq.mu.Lock()
+	if key != "" {
+		if _, claimed := pq.claims[key]; !claimed && found != nil {
+			pq.claims[key] = found
+		}
+		duplicate, err := pq.duplicate(key, item)
+		if err != nil {
+			pq.mu.Unlock()
+			return pq.reject(item, err)
+		}
+		if duplicate {
+			pq.mu.Unlock()
+			return nil
+		}
+	}
+	if prior != nil {
+		exec.Memoise(*prior)
+		if key != "" {
+			pq.claims[key] = &claim{exec: exec, fingerprint: fingerprint(j.GetID(), exec), at: time.Now(), result: exec}
+		}
+		pq.mu.Unlock()
+		go func() {
+			item.ResultsChan() <- item
+		}()
+		return nil
+	}
+	var err error = QueueFullError{RetryAfter: FullRetryAfter}
+	if HighWater <= 0 || pq.fair.len() < HighWater {
+		err = pq.fair.admit(submitterOf(exec))
+	}
+	if err == nil && key != "" {
+		pq.claims[key] = &claim{exec: exec, fingerprint: fingerprint(j.GetID(), exec), at: time.Now()}
+	}
+	pq.mu.Unlock()
+	if err != nil {
+		return pq.reject(item, err)
+	}
+	pq.PushItem(item, exec.GetPriority())
+	return nil
+}
+
+//returns an exec that can't be queued to its sender
+func (pq JobPriorityQueue) reject(item qItem.Item, err error) error {
+	glg.Warn("JobPriorityQueue: rejected exec - " + err.Error())
+	item.GetExec().SetStatus(job.REJECTED)
+	item.GetExec().SetErr(err.Error())
+	go func() {
+		item.ResultsChan() <- item
+	}()
+	return err
 }
 
 func (pq JobPriorityQueue) PushItem(i qItem.Item, piority int) {
-	pq.GetPQ().Push(i, piority)
+	if i.GetExec().GetStatus() != job.CANCELLED {
+		i.GetExec().SetStatus(job.QUEUED)
+	}
+	pq.mu.Lock()
+	now := time.Now()
+	entry := Entry{Item: i, Priority: piority, Effective: piority, QueuedAt: now.Unix(), queued: now}
+	id := uuid.NewV4().String()
+	pq.index[id] = entry
+	pq.queued[i.GetExec()] = id
+	if next := entry.promotes(); !next.IsZero() && (pq.aging.IsZero() || next.Before(*pq.aging)) {
+		*pq.aging = next
+	}
+	pq.journal(i, piority)
+	pq.fair.stop(i.GetExec(), 0) //! requeued execs are no longer running
+	pq.fair.get(submitterOf(i.GetExec())).lane.Push(i, piority)
+	pq.mu.Unlock()
 	glg.Info("JobPriotityQueue: received job")
 
 }
 
+//Pop returns the next exec of the submitter whose turn it is, Empty should be checked first
 func (pq JobPriorityQueue) Pop() qItem.Item {
-	i, _ := pq.GetPQ().Pop()
-	return i.(qItem.Item)
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+	if !pq.aging.IsZero() && !time.Now().Before(*pq.aging) {
+		pq.age()
+	}
+	s := pq.fair.next()
+	if s == nil {
+		return qItem.Item{}
+	}
+	i, _ := s.lane.Pop()
+	item := i.(qItem.Item)
+	pq.fair.start(item.GetExec())
+	if id, ok := pq.queued[item.GetExec()]; ok {
+		metrics.ObserveQueueWait(pq.index[id].GetPriority(), pq.index[id].Waited().Seconds())
+		delete(pq.index, id)
+		delete(pq.queued, item.GetExec())
+	}
+	return item
+}
+
+//raises the priority of items that have been waiting so lower priorities aren't starved, it's run by Pop once an item
+//is due to rise a level and at most every AgeInterval
+func (pq JobPriorityQueue) age() {
+	now := time.Now()
+	var next time.Time
+	aged := make(map[string]bool)
+	priorities := make(map[*job.Exec]int)
+	for id, entry := range pq.index {
+		if effective := entry.age(); effective != entry.GetEffective() {
+			entry.Effective = effective
+			pq.index[id] = entry
+			aged[submitterOf(entry.GetItem().GetExec())] = true
+		}
+		priorities[entry.GetItem().GetExec()] = entry.GetEffective()
+		if promotes := entry.promotes(); !promotes.IsZero() && (next.IsZero() || promotes.Before(next)) {
+			next = promotes
+		}
+	}
+	if !next.IsZero() && next.Before(now.Add(AgeInterval)) {
+		next = now.Add(AgeInterval)
+	}
+	*pq.aging = next
+	//! execs of a job with the same args share a hash so lanes are rebuilt instead of removing by hash
+	for pub := range aged {
+		l := pq.fair.get(pub).lane
+		var items []qItem.Item
+		for l.Empty() == false {
+			i, _ := l.Pop()
+			items = append(items, i.(qItem.Item))
+		}
+		for _, item := range items {
+			l.Push(item, priorities[item.GetExec()])
+		}
+	}
 }
 
 func (pq JobPriorityQueue) Remove(hash []byte) {
-	pq.pq.RemoveHash(hash)
+	pq.mu.Lock()
+	for _, s := range pq.fair.submitters {
+		s.lane.RemoveHash(hash)
+	}
+	for id, entry := range pq.index {
+		if bytes.Compare(entry.GetItem().GetExec().GetHash(), hash) == 0 {
+			delete(pq.index, id)
+			delete(pq.queued, entry.GetItem().GetExec())
+			pq.forget(entry.GetItem().GetExec())
+		}
+	}
+	pq.mu.Unlock()
 }
 
-func (pq JobPriorityQueue) GetPQ() *lane.PQueue {
-	return pq.pq
+//Entries returns a snapshot of the items waiting in the queue
+func (pq JobPriorityQueue) Entries() []Entry {
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+	var entries []Entry
+	for id, entry := range pq.index {
+		entry.ID = id
+		entries = append(entries, entry)
+	}
+	return entries
+}
 
+//Cancel marks a queued item as cancelled, it's returned to its sender when popped
+func (pq JobPriorityQueue) Cancel(id string) error {
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+	entry, ok := pq.index[id]
+	if !ok {
+		return ErrEntryNotFound
+	}
+	entry.GetItem().GetExec().SetStatus(job.CANCELLED)
+	pq.journal(entry.GetItem(), entry.GetPriority())
+	return nil
+}
+
+//Update saves the current state of an exec to the journal
+func (pq JobPriorityQueue) Update(exec *job.Exec) {
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+	if id, ok := pq.ids[exec]; ok {
+		pq.update(id, func(r *Record) {
+			r.Item.SetExec(exec)
+			r.Status = exec.GetStatus()
+		})
+	}
+}
+
+//Complete saves the result of an exec to the journal, it's kept for ResultRetention
+func (pq JobPriorityQueue) Complete(exec *job.Exec, result job.Exec) {
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+	pq.fair.stop(exec, result.GetDuration())
+	pq.settle(exec, result)
+	id, ok := pq.ids[exec]
+	if !ok {
+		return
+	}
+	delete(pq.ids, exec)
+	pq.update(id, func(r *Record) {
+		r.Result = &result
+		r.Status = result.GetStatus()
+		if !r.Done() {
+			r.Status = job.FINISHED
+		}
+	})
+}
+
+//Forget removes an exec from the journal and releases its idempotency key, used when another node takes over the exec
+func (pq JobPriorityQueue) Forget(exec *job.Exec) {
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+	pq.fair.stop(exec, 0)
+	pq.forget(exec)
+}
+
+//removes an exec from the journal, the exec won't be completed by this queue so its claim is released
+func (pq JobPriorityQueue) forget(exec *job.Exec) {
+	pq.release(exec)
+	id, ok := pq.ids[exec]
+	if !ok {
+		return
+	}
+	delete(pq.ids, exec)
+	err := pq.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(JournalBucket)).Delete([]byte(id))
+	})
+	if err != nil {
+		glg.Fatal(err)
+	}
+}
+
+//Results returns the journal records of a job's execs
+func (pq JobPriorityQueue) Results(id string) []Record {
+	var records []Record
+	if pq.db == nil {
+		return records
+	}
+	err := pq.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(JournalBucket)).ForEach(func(k, v []byte) error {
+			r, err := DeserializeRecord(v)
+			if err == nil && r.GetItem().GetID() == id {
+				records = append(records, r)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		glg.Fatal(err)
+	}
+	return records
+}
+
+//writes an item to the journal, reusing its record if the exec was pushed before
+func (pq JobPriorityQueue) journal(i qItem.Item, priority int) {
+	if pq.db == nil {
+		return
+	}
+	id, ok := pq.ids[i.GetExec()]
+	if !ok {
+		id = uuid.NewV4().String()
+		pq.ids[i.GetExec()] = id
+	}
+	r := Record{ID: id, Item: i, Priority: priority, Status: i.GetExec().GetStatus(), UpdatedAt: time.Now().Unix()}
+	err := pq.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(JournalBucket)).Put([]byte(id), r.Serialize())
+	})
+	if err != nil {
+		glg.Fatal(err)
+	}
+}
+
+//applies a change to a journal record
+func (pq JobPriorityQueue) update(id string, change func(r *Record)) {
+	err := pq.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(JournalBucket))
+		r, err := DeserializeRecord(b.Get([]byte(id)))
+		if err != nil {
+			return err
+		}
+		change(&r)
+		r.UpdatedAt = time.Now().Unix()
+		return b.Put([]byte(id), r.Serialize())
+	})
+	if err != nil {
+		glg.Error("JobPriorityQueue: unable to update journal - " + err.Error())
+	}
+}
+
+//requeues execs that weren't done when the journal was last written and drops expired results
+func (pq JobPriorityQueue) recover() {
+	var pending []Record
+	err := pq.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(JournalBucket))
+		if err != nil {
+			return err
+		}
+		var expired [][]byte
+		err = b.ForEach(func(k, v []byte) error {
+			r, err := DeserializeRecord(v)
+			if err != nil || r.GetItem().GetExec() == nil {
+				expired = append(expired, k)
+			} else if !r.Done() {
+				pending = append(pending, r)
+			} else if time.Since(time.Unix(r.GetUpdatedAt(), 0)) > ResultRetention {
+				expired = append(expired, k)
+			} else if key := dedupeKey(r.GetItem().GetExec()); key != "" && r.GetResult() != nil {
+				pq.claims[key] = &claim{exec: r.GetItem().GetExec(), fingerprint: fingerprint(r.GetItem().GetJob().GetID(), r.GetItem().GetExec()), at: time.Unix(r.GetUpdatedAt(), 0), result: r.GetResult()}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range expired {
+			if err = b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		glg.Fatal(err)
+	}
+	for _, r := range pending {
+		//! the sender's results channel didn't survive the restart, results are read from the journal
+		results := make(chan qItem.Item, 1)
+		go func() {
+			<-results
+		}()
+		item := qItem.NewItem(r.GetItem().GetJob(), r.GetItem().GetExec(), results, nil)
+		pq.mu.Lock()
+		pq.ids[item.GetExec()] = r.GetID()
+		if key := dedupeKey(item.GetExec()); key != "" {
+			pq.claims[key] = &claim{exec: item.GetExec(), fingerprint: fingerprint(item.GetJob().GetID(), item.GetExec()), at: time.Now()}
+		}
+		pq.mu.Unlock()
+		pq.PushItem(item, r.GetPriority())
+	}
+	if len(pending) != 0 {
+		glg.Info("JobPriorityQueue: recovered queued execs from journal")
+	}
+}
+
+//Len returns the number of items in the queue
+func (pq JobPriorityQueue) Len() int {
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+	return pq.fair.len()
+}
+
+//Empty returns true if no item can be popped, items of submitters at their running quota wait in the queue
+func (pq JobPriorityQueue) Empty() bool {
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+	return pq.fair.empty()
+}
+
+//Usage returns the share of the queue used by each submitter
+func (pq JobPriorityQueue) Usage() []Usage {
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+	return pq.fair.usage()
 }
 
 // func (pq JobPriorityQueue) watch() {
@@ -62,10 +470,26 @@ func (pq JobPriorityQueue) GetPQ() *lane.PQueue {
 // }
 
 func NewJobPriorityQueue() *JobPriorityQueue {
-	pq := lane.NewPQueue(lane.MAXPQ)
 	q := &JobPriorityQueue{
-		pq: pq,
+		fair:   newFairShare(),
+		mu:     new(sync.Mutex),
+		index:  make(map[string]Entry),
+		queued: make(map[*job.Exec]string),
+		aging:  new(time.Time),
+		ids:    make(map[*job.Exec]string),
+		claims: make(map[string]*claim),
 	}
 	// go q.watch()
 	return q
 }
+
+//NewPersistentJobPriorityQueue returns a queue journaled in a bolt db, execs that weren't done are requeued.
+//bc is searched for execs of resubmitted idempotency keys and jc for prior execs of deterministic jobs, either can be nil
+func NewPersistentJobPriorityQueue(db *bolt.DB, bc *core.BlockChain, jc *cache.JobCache) *JobPriorityQueue {
+	q := NewJobPriorityQueue()
+	q.db = db
+	q.bc = bc
+	q.jc = jc
+	q.recover()
+	return q
+}
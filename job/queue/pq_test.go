@@ -0,0 +1,245 @@
+package queue_test
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gizo-network/gizo/crypt"
+	"github.com/gizo-network/gizo/job"
+	"github.com/gizo-network/gizo/job/queue"
+	"github.com/gizo-network/gizo/job/queue/qItem"
+)
+
+func TestPersistentJobPriorityQueue(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jobqueue")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	db, err := bolt.Open(path.Join(dir, "queue.db"), 0600, nil)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	priv, pub := crypt.GenKeys()
+	j := job.NewJob(`
+	func Test(){
+		return "Testing"
+	}`, "Test", false, hex.EncodeToString(priv))
+	queued, err := job.NewExec([]interface{}{}, 5, job.NORMAL, 0, 0, 0, 0, hex.EncodeToString(pub), job.NewEnvVariables(), "")
+	assert.NoError(t, err)
+	finished, err := job.NewExec([]interface{}{}, 5, job.HIGH, 0, 0, 0, 0, hex.EncodeToString(pub), job.NewEnvVariables(), "")
+	assert.NoError(t, err)
+
+	pq := queue.NewPersistentJobPriorityQueue(db, nil, nil)
+	pq.Push(*j, queued, make(chan qItem.Item, 1), nil)
+	pq.Push(*j, finished, make(chan qItem.Item, 1), nil)
+	item := pq.Pop()
+	assert.Equal(t, finished, item.GetExec())
+	result := *item.GetExec()
+	result.SetStatus(job.FINISHED)
+	pq.Complete(item.GetExec(), result)
+
+	recovered := queue.NewPersistentJobPriorityQueue(db, nil, nil)
+	assert.Equal(t, 1, recovered.Len())
+	assert.Equal(t, queued.GetHash(), recovered.Pop().GetExec().GetHash())
+	records := recovered.Results(j.GetID())
+	assert.Len(t, records, 2)
+	for _, r := range records {
+		if r.Done() {
+			assert.Equal(t, finished.GetHash(), r.GetResult().GetHash())
+		}
+	}
+}
+
+func TestJobPriorityQueueAging(t *testing.T) {
+	defer func(rate, wait time.Duration) {
+		queue.AgingRate, queue.MaxWait = rate, wait
+	}(queue.AgingRate, queue.MaxWait)
+	queue.AgingRate, queue.MaxWait = 0, time.Millisecond*50
+
+	priv, pub := crypt.GenKeys()
+	j := job.NewJob(`
+	func Test(){
+		return "Testing"
+	}`, "Test", false, hex.EncodeToString(priv))
+	normal, err := job.NewExec([]interface{}{}, 5, job.NORMAL, 0, 0, 0, 0, hex.EncodeToString(pub), job.NewEnvVariables(), "")
+	assert.NoError(t, err)
+	high, err := job.NewExec([]interface{}{}, 5, job.HIGH, 0, 0, 0, 0, hex.EncodeToString(pub), job.NewEnvVariables(), "")
+	assert.NoError(t, err)
+
+	pq := queue.NewJobPriorityQueue()
+	pq.Push(*j, normal, make(chan qItem.Item, 1), nil)
+	time.Sleep(queue.MaxWait)
+	pq.Push(*j, high, make(chan qItem.Item, 1), nil)
+	assert.Equal(t, normal, pq.Pop().GetExec())
+	assert.Equal(t, high, pq.Pop().GetExec())
+
+	//! items rise a level every AgingRate
+	queue.AgingRate, queue.MaxWait = time.Millisecond*50, 0
+	low, err := job.NewExec([]interface{}{}, 5, job.LOW, 0, 0, 0, 0, hex.EncodeToString(pub), job.NewEnvVariables(), "")
+	assert.NoError(t, err)
+	pq.Push(*j, normal, make(chan qItem.Item, 1), nil)
+	time.Sleep(queue.AgingRate * 2)
+	pq.Push(*j, low, make(chan qItem.Item, 1), nil)
+	assert.Equal(t, normal, pq.Pop().GetExec())
+	assert.Equal(t, low, pq.Pop().GetExec())
+}
+
+func TestJobPriorityQueueFairShare(t *testing.T) {
+	defer func(quota queue.Quota) {
+		queue.DefaultQuota = quota
+	}(queue.DefaultQuota)
+	queue.DefaultQuota = queue.Quota{MaxQueued: 3, Window: time.Hour}
+
+	priv, pub := crypt.GenKeys()
+	_, other := crypt.GenKeys()
+	j := job.NewJob(`
+	func Test(){
+		return "Testing"
+	}`, "Test", false, hex.EncodeToString(priv))
+	pq := queue.NewJobPriorityQueue()
+	for i := 0; i < 3; i++ {
+		exec, err := job.NewExec([]interface{}{i}, 5, job.HIGH, 0, 0, 0, 0, hex.EncodeToString(pub), job.NewEnvVariables(), "")
+		assert.NoError(t, err)
+		exec.SetSubmitter(hex.EncodeToString(pub))
+		assert.NoError(t, pq.Push(*j, exec, make(chan qItem.Item, 1), nil))
+	}
+	rejected, err := job.NewExec([]interface{}{}, 5, job.HIGH, 0, 0, 0, 0, hex.EncodeToString(other), job.NewEnvVariables(), "")
+	assert.NoError(t, err)
+	rejected.SetSubmitter(hex.EncodeToString(pub)) //! quotas follow the submitter, not the pub of the exec
+	results := make(chan qItem.Item, 1)
+	assert.Equal(t, queue.ErrQueuedQuota, pq.Push(*j, rejected, results, nil))
+	assert.Equal(t, job.REJECTED, (<-results).GetExec().GetStatus())
+
+	exec, err := job.NewExec([]interface{}{}, 5, job.NORMAL, 0, 0, 0, 0, hex.EncodeToString(pub), job.NewEnvVariables(), "")
+	assert.NoError(t, err)
+	exec.SetSubmitter(hex.EncodeToString(other))
+	assert.NoError(t, pq.Push(*j, exec, make(chan qItem.Item, 1), nil))
+	assert.Equal(t, 4, pq.Len())
+
+	var order []string
+	for pq.Empty() == false {
+		order = append(order, pq.Pop().GetExec().GetSubmitter())
+	}
+	assert.Equal(t, []string{hex.EncodeToString(pub), hex.EncodeToString(other), hex.EncodeToString(pub), hex.EncodeToString(pub)}, order)
+}
+
+func TestJobPriorityQueueDedupe(t *testing.T) {
+	priv, pub := crypt.GenKeys()
+	j := job.NewJob(`
+	func Test(){
+		return "Testing"
+	}`, "Test", false, hex.EncodeToString(priv))
+	first, err := job.NewExec([]interface{}{}, 5, job.NORMAL, 0, 0, 0, 0, hex.EncodeToString(pub), job.NewEnvVariables(), "")
+	assert.NoError(t, err)
+	assert.NoError(t, first.SetIdempotencyKey("key"))
+	first.SetSubmitter(hex.EncodeToString(pub))
+	retry := first.Copy([]interface{}{})
+	assert.NoError(t, retry.SetIdempotencyKey("key"))
+	late := first.Copy([]interface{}{})
+	assert.NoError(t, late.SetIdempotencyKey("key"))
+	reused := first.Copy([]interface{}{"other"})
+	assert.NoError(t, reused.SetIdempotencyKey("key"))
+	anonymous := first.Copy([]interface{}{})
+	assert.NoError(t, anonymous.SetIdempotencyKey("key"))
+	anonymous.SetSubmitter("")
+
+	pq := queue.NewJobPriorityQueue()
+	assert.NoError(t, pq.Push(*j, first, make(chan qItem.Item, 1), nil))
+	waiting := make(chan qItem.Item, 1)
+	assert.NoError(t, pq.Push(*j, retry, waiting, nil))
+	assert.Equal(t, 1, pq.Len())
+	//! a key can't be reused for other args
+	rejected := make(chan qItem.Item, 1)
+	assert.Equal(t, queue.ErrKeyReused, pq.Push(*j, reused, rejected, nil))
+	assert.Equal(t, job.REJECTED, (<-rejected).GetExec().GetStatus())
+	//! keys are only deduplicated for authenticated submitters
+	assert.NoError(t, pq.Push(*j, anonymous, make(chan qItem.Item, 1), nil))
+	assert.Equal(t, 2, pq.Len())
+
+	item, other := pq.Pop(), pq.Pop()
+	if item.GetExec() == anonymous {
+		item, other = other, item
+	}
+	assert.Equal(t, anonymous, other.GetExec())
+	result := *item.GetExec()
+	result.SetStatus(job.FINISHED)
+	result.SetResult("Testing")
+	pq.Complete(item.GetExec(), result)
+	assert.Equal(t, "Testing", (<-waiting).GetExec().GetResult())
+
+	done := make(chan qItem.Item, 1)
+	assert.NoError(t, pq.Push(*j, late, done, nil))
+	assert.Equal(t, job.FINISHED, (<-done).GetExec().GetStatus())
+	assert.True(t, pq.Empty())
+}
+
+func TestJobPriorityQueueDedupeRemove(t *testing.T) {
+	priv, pub := crypt.GenKeys()
+	j := job.NewJob(`
+	func Test(){
+		return "Testing"
+	}`, "Test", false, hex.EncodeToString(priv))
+	first, err := job.NewExec([]interface{}{}, 5, job.NORMAL, 0, 0, 0, 0, hex.EncodeToString(pub), job.NewEnvVariables(), "")
+	assert.NoError(t, err)
+	assert.NoError(t, first.SetIdempotencyKey("key"))
+	first.SetSubmitter(hex.EncodeToString(pub))
+	retry := first.Copy([]interface{}{})
+	assert.NoError(t, retry.SetIdempotencyKey("key"))
+	again := first.Copy([]interface{}{})
+	assert.NoError(t, again.SetIdempotencyKey("key"))
+
+	pq := queue.NewJobPriorityQueue()
+	assert.NoError(t, pq.Push(*j, first, make(chan qItem.Item, 1), nil))
+	waiting := make(chan qItem.Item, 1)
+	assert.NoError(t, pq.Push(*j, retry, waiting, nil))
+	assert.Equal(t, 1, pq.Len())
+
+	//! the waiting duplicate is answered once the exec it waits for is removed
+	pq.Remove(first.GetHash())
+	select {
+	case item := <-waiting:
+		assert.Equal(t, job.CANCELLED, item.GetExec().GetStatus())
+		assert.Equal(t, queue.ErrClaimReleased.Error(), item.GetExec().GetErr())
+	case <-time.After(time.Second * 5):
+		t.Fatal("duplicate wasn't answered")
+	}
+	//! the key can be submitted again
+	assert.NoError(t, pq.Push(*j, again, make(chan qItem.Item, 1), nil))
+	entries := pq.Entries()
+	assert.Len(t, entries, 1)
+	assert.Equal(t, again, entries[0].GetItem().GetExec())
+}
+
+func TestJobPriorityQueueSharedPub(t *testing.T) {
+	defer func(quota queue.Quota) {
+		queue.DefaultQuota = quota
+	}(queue.DefaultQuota)
+	queue.DefaultQuota = queue.Quota{MaxQueued: 1, Window: time.Hour}
+
+	priv, pub := crypt.GenKeys()
+	j := job.NewJob(`
+	func Test(){
+		return "Testing"
+	}`, "Test", false, hex.EncodeToString(priv))
+	pq := queue.NewJobPriorityQueue()
+	//! submitters that set the same pub on their execs still have their own quotas
+	for _, submitter := range []string{"a", "b"} {
+		exec, err := job.NewExec([]interface{}{}, 5, job.NORMAL, 0, 0, 0, 0, hex.EncodeToString(pub), job.NewEnvVariables(), "")
+		assert.NoError(t, err)
+		exec.SetSubmitter(submitter)
+		assert.NoError(t, pq.Push(*j, exec, make(chan qItem.Item, 1), nil))
+	}
+	exec, err := job.NewExec([]interface{}{}, 5, job.NORMAL, 0, 0, 0, 0, "rotated", job.NewEnvVariables(), "")
+	assert.NoError(t, err)
+	exec.SetSubmitter("a")
+	assert.Equal(t, queue.ErrQueuedQuota, pq.Push(*j, exec, make(chan qItem.Item, 1), nil))
+	assert.Equal(t, 2, pq.Len())
+	usage := pq.Usage()
+	assert.Len(t, usage, 2)
+}
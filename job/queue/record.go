@@ -0,0 +1,67 @@
+package queue
+
+import (
+	"encoding/json"
+
+	"github.com/gizo-network/gizo/job"
+	"github.com/gizo-network/gizo/job/queue/qItem"
+	"github.com/kpango/glg"
+)
+
+//Record is the journal entry of an exec pushed to a persistent queue
+type Record struct {
+	ID        string     `json:"id"`
+	Item      qItem.Item `json:"item"`
+	Priority  int        `json:"priority"`
+	Status    string     `json:"status"`
+	Result    *job.Exec  `json:"result"` // set once the exec is done
+	UpdatedAt int64      `json:"updated_at"`
+}
+
+func (r Record) GetID() string {
+	return r.ID
+}
+
+func (r Record) GetItem() qItem.Item {
+	return r.Item
+}
+
+func (r Record) GetPriority() int {
+	return r.Priority
+}
+
+func (r Record) GetStatus() string {
+	return r.Status
+}
+
+func (r Record) GetResult() *job.Exec {
+	return r.Result
+}
+
+func (r Record) GetUpdatedAt() int64 {
+	return r.UpdatedAt
+}
+
+//Done returns true if the exec won't be run again
+func (r Record) Done() bool {
+	switch r.GetStatus() {
+	case job.FINISHED, job.CANCELLED, job.TIMEOUT:
+		return true
+	default:
+		return false
+	}
+}
+
+func (r Record) Serialize() []byte {
+	bytes, err := json.Marshal(r)
+	if err != nil {
+		glg.Fatal(err)
+	}
+	return bytes
+}
+
+func DeserializeRecord(b []byte) (Record, error) {
+	var temp Record
+	err := json.Unmarshal(b, &temp)
+	return temp, err
+}
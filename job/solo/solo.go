@@ -11,27 +11,33 @@ import (
 	"github.com/gizo-network/gizo/job"
 	"github.com/gizo-network/gizo/job/queue"
 	"github.com/gizo-network/gizo/job/queue/qItem"
+	"github.com/gizo-network/gizo/job/workflow"
 	"github.com/kpango/glg"
 )
 
 //Solo - Jobs executed one after the other
 type Solo struct {
-	jr     job.JobRequestSingle
-	bc     *core.BlockChain
-	pq     *queue.JobPriorityQueue
-	jc     *cache.JobCache
-	result job.JobRequestSingle
-	status string
-	cancel chan struct{}
+	jr      job.JobRequestSingle
+	bc      *core.BlockChain
+	pq      *queue.JobPriorityQueue
+	jc      *cache.JobCache
+	result  job.JobRequestSingle
+	status  string
+	tracker *workflow.Tracker
+	cancel  chan struct{}
 }
 
 func NewSolo(jr job.JobRequestSingle, bc *core.BlockChain, pq *queue.JobPriorityQueue, jc *cache.JobCache) *Solo {
-	return &Solo{
-		jr: jr,
-		bc: bc,
-		pq: pq,
-		jc: jc,
+	s := &Solo{
+		jr:      jr,
+		bc:      bc,
+		pq:      pq,
+		jc:      jc,
+		tracker: workflow.NewTracker("solo"),
+		cancel:  make(chan struct{}),
 	}
+	s.tracker.AddStep(jr.GetID(), jr.GetID(), 1)
+	return s
 }
 
 func (s *Solo) Cancel() {
@@ -42,6 +48,26 @@ func (s Solo) GetCancelChan() chan struct{} {
 	return s.cancel
 }
 
+//GetID returns the workflow id
+func (s Solo) GetID() string {
+	return s.tracker.GetID()
+}
+
+//GetRecord returns the workflow record
+func (s Solo) GetRecord() workflow.Record {
+	return s.tracker.GetRecord()
+}
+
+//SetOwner sets the pub of the client that submitted the solo
+func (s *Solo) SetOwner(pub string) {
+	s.tracker.SetOwner(pub)
+}
+
+//SetStore persists the workflow record in store
+func (s *Solo) SetStore(store *workflow.Store) {
+	s.tracker.SetStore(store)
+}
+
 func (s Solo) GetJob() job.JobRequestSingle {
 	return s.jr
 }
@@ -81,6 +107,7 @@ func (s Solo) Result() job.JobRequestSingle {
 
 func (s *Solo) Dispatch() {
 	s.setStatus(job.RUNNING)
+	s.tracker.Start()
 	var result qItem.Item
 	res := make(chan qItem.Item)
 	cancelled := false
@@ -105,12 +132,10 @@ func (s *Solo) Dispatch() {
 		wg.Done()
 	}()
 	s.setStatus("Queueing execs of job - " + s.GetJob().GetID())
+	s.tracker.Queueing(s.GetJob().GetID())
 	var j *job.Job
 	var err error
-	j, err = s.getJC().Get(s.GetJob().GetID()) //check job cache first
-	if j == nil {
-		j, err = s.getBC().FindJob(s.GetJob().GetID())
-	}
+	j, err = s.getJC().Get(s.GetJob().GetID())
 	if err != nil {
 		glg.Warn("Batch: Unable to find job - " + s.GetJob().GetID())
 		s.GetJob().GetExec().SetErr("Batch: Unable to find job - " + s.GetJob().GetID())
@@ -135,6 +160,7 @@ func (s *Solo) Dispatch() {
 		}
 	}
 	close(res)
+	s.tracker.Returned(s.GetJob().GetID(), s.GetJob().GetExec())
 
 	if cancelled == false {
 		closeCancel <- struct{}{}
@@ -143,5 +169,6 @@ func (s *Solo) Dispatch() {
 		s.setStatus(job.CANCELLED)
 	}
 	wg.Wait()
+	s.tracker.Finish(s.GetStatus())
 	s.setResult(*job.NewJobRequestSingle(result.GetID(), result.GetExec()))
 }
@@ -0,0 +1,159 @@
+package workflow
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/gizo-network/gizo/job"
+	"github.com/kpango/glg"
+)
+
+var (
+	ErrInvalidTransition = errors.New("Workflow: invalid state transition")
+)
+
+// workflow states
+const (
+	CREATED   = "CREATED"     // built but not dispatched
+	RUNNING   = "RUNNING"     // execs being queued or run
+	FINISHED  = job.FINISHED  // every exec succeeded
+	PARTIAL   = job.PARTIAL   // some execs failed
+	FAILED    = job.FAILED    // every exec failed
+	CANCELLED = job.CANCELLED // cancelled by the sender
+)
+
+//states a workflow can move to from each state
+var transitions = map[string][]string{
+	CREATED: {RUNNING, CANCELLED},
+	RUNNING: {FINISHED, PARTIAL, FAILED, CANCELLED},
+}
+
+//ExecState is the hash and status of an exec of a step once it's returned
+type ExecState struct {
+	Hash   []byte      `json:"hash"`
+	Status string      `json:"status"`
+	Err    interface{} `json:"err"`
+}
+
+func (e ExecState) GetHash() []byte {
+	return e.Hash
+}
+
+func (e ExecState) GetStatus() string {
+	return e.Status
+}
+
+func (e ExecState) GetErr() interface{} {
+	return e.Err
+}
+
+//Step is a job request of a workflow
+type Step struct {
+	Name  string      `json:"name"` // job id, or node or stage name
+	JobID string      `json:"job_id"`
+	Total int         `json:"total"` // number of execs in the step
+	Execs []ExecState `json:"execs"` // execs returned so far
+}
+
+func (s Step) GetName() string {
+	return s.Name
+}
+
+func (s Step) GetJobID() string {
+	return s.JobID
+}
+
+func (s Step) GetTotal() int {
+	return s.Total
+}
+
+func (s Step) GetExecs() []ExecState {
+	return s.Execs
+}
+
+//Done returns true once every exec of the step has returned
+func (s Step) Done() bool {
+	return len(s.Execs) >= s.Total
+}
+
+//Record is the state of a workflow and its steps
+type Record struct {
+	ID        string `json:"id"`
+	Kind      string `json:"kind"`  // solo, chain, batch, chord, dag or mapreduce
+	Owner     string `json:"owner"` // pub of the client that submitted the workflow
+	State     string `json:"state"`
+	Step      string `json:"step"` // name of the step being queued
+	Steps     []Step `json:"steps"`
+	CreatedAt int64  `json:"created_at"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+func NewRecord(id, kind string) Record {
+	now := time.Now().Unix()
+	return Record{ID: id, Kind: kind, State: CREATED, CreatedAt: now, UpdatedAt: now}
+}
+
+func (r Record) GetID() string {
+	return r.ID
+}
+
+func (r Record) GetKind() string {
+	return r.Kind
+}
+
+func (r Record) GetOwner() string {
+	return r.Owner
+}
+
+func (r Record) GetState() string {
+	return r.State
+}
+
+func (r Record) GetStep() string {
+	return r.Step
+}
+
+func (r Record) GetSteps() []Step {
+	return r.Steps
+}
+
+func (r Record) GetCreatedAt() int64 {
+	return r.CreatedAt
+}
+
+func (r Record) GetUpdatedAt() int64 {
+	return r.UpdatedAt
+}
+
+//Done returns true once the workflow reached a final state
+func (r Record) Done() bool {
+	_, ok := transitions[r.GetState()]
+	return !ok
+}
+
+//Transition moves the workflow to state, returns ErrInvalidTransition if it can't be reached from the current state
+func (r *Record) Transition(state string) error {
+	for _, next := range transitions[r.GetState()] {
+		if next == state {
+			r.State = state
+			r.UpdatedAt = time.Now().Unix()
+			return nil
+		}
+	}
+	return ErrInvalidTransition
+}
+
+func (r Record) Serialize() []byte {
+	bytes, err := json.Marshal(r)
+	if err != nil {
+		glg.Fatal(err)
+	}
+	return bytes
+}
+
+func DeserializeRecord(b []byte) (Record, error) {
+	var temp Record
+	err := json.Unmarshal(b, &temp)
+	return temp, err
+}
@@ -0,0 +1,102 @@
+package workflow
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/kpango/glg"
+)
+
+var (
+	ErrWorkflowNotFound = errors.New("Workflow: workflow not found")
+)
+
+const (
+	WorkflowBucket    = "workflows"        // bolt bucket of workflow records
+	WorkflowRetention = time.Hour * 24 * 7 // time records of done workflows are kept
+	SaveInterval      = time.Second        // min time between saves of a running workflow's progress
+)
+
+//Store persists workflow records in a bolt db
+type Store struct {
+	db *bolt.DB
+}
+
+//NewStore returns a store of workflow records, records of workflows done for longer than WorkflowRetention are dropped
+func NewStore(db *bolt.DB) *Store {
+	err := db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(WorkflowBucket))
+		if err != nil {
+			return err
+		}
+		var expired [][]byte
+		err = b.ForEach(func(k, v []byte) error {
+			r, err := DeserializeRecord(v)
+			if err != nil || (r.Done() && time.Since(time.Unix(r.GetUpdatedAt(), 0)) > WorkflowRetention) {
+				expired = append(expired, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range expired {
+			if err = b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		glg.Fatal(err)
+	}
+	return &Store{db: db}
+}
+
+//Save writes a record
+func (s Store) Save(r Record) {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(WorkflowBucket)).Put([]byte(r.GetID()), r.Serialize())
+	})
+	if err != nil {
+		glg.Error("Workflow: unable to save record - " + err.Error())
+	}
+}
+
+//Get returns the record of a workflow
+func (s Store) Get(id string) (Record, error) {
+	var r Record
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket([]byte(WorkflowBucket)).Get([]byte(id))
+		if v == nil {
+			return ErrWorkflowNotFound
+		}
+		var err error
+		r, err = DeserializeRecord(v)
+		return err
+	})
+	return r, err
+}
+
+//List returns the records of owner in a state, or in every state if state is empty, newest first
+func (s Store) List(owner, state string) []Record {
+	var records []Record
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(WorkflowBucket)).ForEach(func(k, v []byte) error {
+			r, err := DeserializeRecord(v)
+			if err == nil && r.GetOwner() == owner && (state == "" || r.GetState() == state) {
+				records = append(records, r)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		glg.Error(err)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].GetCreatedAt() > records[j].GetCreatedAt()
+	})
+	return records
+}
@@ -0,0 +1,68 @@
+package workflow_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gizo-network/gizo/job"
+	"github.com/gizo-network/gizo/job/workflow"
+)
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "workflows")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	db, err := bolt.Open(path.Join(dir, "workflows.db"), 0600, nil)
+	assert.NoError(t, err)
+	defer db.Close()
+	store := workflow.NewStore(db)
+
+	exec, err := job.NewExec([]interface{}{}, 0, job.NORMAL, 0, 0, 0, 0, "", job.NewEnvVariables(), "")
+	assert.NoError(t, err)
+	exec.SetStatus(job.FINISHED)
+
+	tracker := workflow.NewTracker("chain")
+	tracker.AddStep("a", "a", 2)
+	tracker.SetOwner("owner")
+	tracker.SetStore(store)
+	record, err := store.Get(tracker.GetID())
+	assert.NoError(t, err)
+	assert.Equal(t, workflow.CREATED, record.GetState())
+	assert.Len(t, record.GetSteps(), 1)
+
+	tracker.Start()
+	tracker.Queueing("a")
+	tracker.Returned("a", exec)
+	record, err = store.Get(tracker.GetID())
+	assert.NoError(t, err)
+	assert.Empty(t, record.GetSteps()[0].GetExecs()) //! progress is saved at most once per SaveInterval
+	time.Sleep(workflow.SaveInterval * 2)
+	record, err = store.Get(tracker.GetID())
+	assert.NoError(t, err)
+	assert.Equal(t, workflow.RUNNING, record.GetState())
+	assert.Equal(t, "a", record.GetStep())
+	assert.Len(t, record.GetSteps()[0].GetExecs(), 1)
+	assert.Equal(t, job.FINISHED, record.GetSteps()[0].GetExecs()[0].GetStatus())
+	assert.False(t, record.GetSteps()[0].Done())
+	assert.Len(t, store.List("owner", workflow.RUNNING), 1)
+	assert.Len(t, store.List("owner", workflow.FINISHED), 0)
+
+	tracker.Finish(workflow.FINISHED)
+	tracker.Start() //! final states can't be left
+	record, err = store.Get(tracker.GetID())
+	assert.NoError(t, err)
+	assert.Equal(t, workflow.FINISHED, record.GetState())
+	assert.True(t, record.Done())
+	assert.Equal(t, workflow.ErrInvalidTransition, record.Transition(workflow.RUNNING))
+
+	_, err = store.Get("unknown")
+	assert.Equal(t, workflow.ErrWorkflowNotFound, err)
+	assert.Len(t, workflow.NewStore(db).List("owner", ""), 1)
+	assert.Empty(t, store.List("other", ""))
+}
@@ -0,0 +1,151 @@
+package workflow
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gizo-network/gizo/job"
+	"github.com/kpango/glg"
+	uuid "github.com/satori/go.uuid"
+)
+
+//Tracker keeps the record of a workflow up to date and saves it to a store if one is set,
+//progress of a running workflow is saved at most every SaveInterval
+type Tracker struct {
+	mu      *sync.Mutex
+	record  *Record
+	store   *Store
+	saved   time.Time // time of the last save
+	dirty   bool      // progress made since the last save
+	pending bool      // a save of unsaved progress is scheduled
+}
+
+func NewTracker(kind string) *Tracker {
+	r := NewRecord(uuid.NewV4().String(), kind)
+	return &Tracker{
+		mu:     new(sync.Mutex),
+		record: &r,
+	}
+}
+
+//GetID returns the id of the workflow
+func (t *Tracker) GetID() string {
+	return t.record.GetID()
+}
+
+//GetRecord returns a snapshot of the workflow's record
+func (t *Tracker) GetRecord() Record {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r := *t.record
+	r.Steps = append([]Step{}, r.Steps...)
+	return r
+}
+
+//SetOwner sets the pub of the client that submitted the workflow
+func (t *Tracker) SetOwner(pub string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.record.Owner = pub
+	t.save(true)
+}
+
+//SetStore sets the store the record is saved to
+func (t *Tracker) SetStore(s *Store) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.store = s
+	t.save(true)
+}
+
+//AddStep adds a step to the record
+func (t *Tracker) AddStep(name, jobID string, total int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.addStep(name, jobID, total)
+	t.save(true)
+}
+
+//AddSteps adds a step per job request, named after its job id
+func (t *Tracker) AddSteps(jobs []job.JobRequestMultiple) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, jr := range jobs {
+		t.addStep(jr.GetID(), jr.GetID(), len(jr.GetExec()))
+	}
+	t.save(true)
+}
+
+func (t *Tracker) addStep(name, jobID string, total int) {
+	t.record.Steps = append(t.record.Steps, Step{Name: name, JobID: jobID, Total: total})
+}
+
+//Start moves the workflow to RUNNING
+func (t *Tracker) Start() {
+	t.transition(RUNNING)
+}
+
+//Queueing records the step being queued
+func (t *Tracker) Queueing(step string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.record.Step = step
+	t.save(false)
+}
+
+//Returned records an exec of the first step named step that's still waiting for execs
+func (t *Tracker) Returned(step string, exec *job.Exec) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, s := range t.record.Steps {
+		if s.GetName() == step && !s.Done() {
+			t.record.Steps[i].Execs = append(s.Execs, ExecState{Hash: exec.GetHash(), Status: exec.GetStatus(), Err: exec.GetErr()})
+			break
+		}
+	}
+	t.save(false)
+}
+
+//Finish moves the workflow to a final state
+func (t *Tracker) Finish(state string) {
+	t.transition(state)
+}
+
+func (t *Tracker) transition(state string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.record.Transition(state); err != nil {
+		glg.Warn("Workflow: " + t.record.GetState() + " to " + state + " - " + err.Error())
+		return
+	}
+	t.record.Step = ""
+	t.save(true)
+}
+
+//saves the record if a store is set, unless forced progress saved within SaveInterval is deferred to a single save
+func (t *Tracker) save(force bool) {
+	if t.store == nil {
+		return
+	}
+	if wait := SaveInterval - time.Since(t.saved); !force && wait > 0 {
+		t.dirty = true
+		if !t.pending {
+			t.pending = true
+			time.AfterFunc(wait, t.flush)
+		}
+		return
+	}
+	t.store.Save(*t.record)
+	t.saved = time.Now()
+	t.dirty = false
+}
+
+//saves progress deferred by save
+func (t *Tracker) flush() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pending = false
+	if t.dirty {
+		t.save(true)
+	}
+}
@@ -0,0 +1,160 @@
+package workflow
+
+import (
+	"errors"
+
+	"github.com/gizo-network/gizo/cache"
+
+	"github.com/gizo-network/gizo/job"
+	"github.com/gizo-network/gizo/job/queue"
+	"github.com/gizo-network/gizo/job/queue/qItem"
+	"github.com/kpango/glg"
+)
+
+var (
+	ErrInvalidPolicy = errors.New("Workflow: unknown failure policy")
+	ErrFailFast      = errors.New("Workflow: cancelled after another exec of the workflow failed")
+)
+
+// failure policies
+const (
+	FailFast        = "FAIL_FAST"       // execs that haven't run are cancelled once an exec fails
+	ContinueOnError = "CONTINUE"        // remaining execs run, failed results are left out of callback args
+	SkipDependents  = "SKIP_DEPENDENTS" // steps fed by a failed exec are skipped, workflows without dependent steps continue
+)
+
+//Policy decides what a workflow does when one of its execs fails
+type Policy struct {
+	Mode      string `json:"mode"`
+	OnFailure string `json:"on_failure"` // id of a job called with the job id and error of each failed exec, none if empty
+}
+
+func NewPolicy(mode, onFailure string) (Policy, error) {
+	switch mode {
+	case FailFast, ContinueOnError, SkipDependents:
+	default:
+		return Policy{}, ErrInvalidPolicy
+	}
+	return Policy{Mode: mode, OnFailure: onFailure}, nil
+}
+
+//DefaultPolicy returns the policy workflows use unless another is set
+func DefaultPolicy() Policy {
+	return Policy{Mode: ContinueOnError}
+}
+
+func (p Policy) GetMode() string {
+	return p.Mode
+}
+
+func (p Policy) GetOnFailure() string {
+	return p.OnFailure
+}
+
+//FailFast returns true if remaining execs should be cancelled once an exec fails
+func (p Policy) FailFast() bool {
+	return p.GetMode() == FailFast
+}
+
+//SkipDependents returns true if steps fed by a failed exec shouldn't run
+func (p Policy) SkipDependents() bool {
+	return p.GetMode() == SkipDependents
+}
+
+//Failed returns true if an exec didn't finish successfully
+func Failed(exec *job.Exec) bool {
+	if exec.GetErr() != nil {
+		return true
+	}
+	switch exec.GetStatus() {
+	case job.CANCELLED, job.TIMEOUT, job.REJECTED:
+		return true
+	default:
+		return false
+	}
+}
+
+//Status returns FINISHED if every exec succeeded, FAILED if none did and PARTIAL otherwise
+func Status(results []job.JobRequestMultiple) string {
+	var succeeded, failed int
+	for _, jr := range results {
+		for _, exec := range jr.GetExec() {
+			if Failed(exec) {
+				failed++
+			} else {
+				succeeded++
+			}
+		}
+	}
+	switch {
+	case failed == 0:
+		return job.FINISHED
+	case succeeded == 0:
+		return job.FAILED
+	default:
+		return job.PARTIAL
+	}
+}
+
+//Halt cancels execs of a fail-fast workflow that haven't been dispatched, queued ones are returned when popped
+func Halt(jobs []job.JobRequestMultiple) {
+	for _, jr := range jobs {
+		for _, exec := range jr.GetExec() {
+			if exec.GetStatus() == job.STARTED || exec.GetStatus() == job.QUEUED {
+				exec.SetStatus(job.CANCELLED)
+				exec.SetErr(ErrFailFast.Error())
+			}
+		}
+	}
+}
+
+//Succeeded returns the results of the execs that succeeded
+func Succeeded(execs []*job.Exec) []interface{} {
+	var results []interface{}
+	for _, exec := range execs {
+		if !Failed(exec) {
+			results = append(results, exec.GetResult())
+		}
+	}
+	return results
+}
+
+//Compensate calls the policy's on-failure job with the job id and error of each exec that failed,
+//execs cancelled by the policy or by the sender aren't compensated
+func Compensate(p Policy, results []job.JobRequestMultiple, cancel chan struct{}, pq *queue.JobPriorityQueue, jc *cache.JobCache) job.JobRequestMultiple {
+	var compensations job.JobRequestMultiple
+	compensations.SetID(p.GetOnFailure())
+	if p.GetOnFailure() == "" {
+		return compensations
+	}
+	var failed []*job.Exec
+	var ids []string
+	for _, jr := range results {
+		for _, exec := range jr.GetExec() {
+			if Failed(exec) && exec.GetErr() != ErrFailFast.Error() && exec.GetStatus() != job.CANCELLED {
+				failed = append(failed, exec)
+				ids = append(ids, jr.GetID())
+			}
+		}
+	}
+	if len(failed) == 0 {
+		return compensations
+	}
+	var j *job.Job
+	var err error
+	j, err = jc.Get(p.GetOnFailure())
+	if err != nil {
+		glg.Warn("Workflow: Unable to find on-failure job - " + p.GetOnFailure())
+		return compensations
+	}
+	glg.Warn("Workflow: Compensating failed execs with job - " + p.GetOnFailure())
+	res := make([]chan qItem.Item, len(failed))
+	for i, exec := range failed {
+		res[i] = make(chan qItem.Item, 1)
+		pq.Push(*j, exec.Copy([]interface{}{ids[i], exec.GetErr()}), res[i], cancel)
+	}
+	for _, r := range res {
+		compensations.AppendExec((<-r).GetExec())
+	}
+	return compensations
+}
@@ -0,0 +1,43 @@
+package workflow_test
+
+import (
+	"testing"
+
+	"github.com/gizo-network/gizo/job"
+	"github.com/gizo-network/gizo/job/workflow"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatus(t *testing.T) {
+	_, err := workflow.NewPolicy("RETRY", "")
+	assert.Equal(t, workflow.ErrInvalidPolicy, err)
+	policy, err := workflow.NewPolicy(workflow.FailFast, "")
+	assert.NoError(t, err)
+	assert.True(t, policy.FailFast())
+	assert.False(t, workflow.DefaultPolicy().FailFast())
+	policy, err = workflow.NewPolicy(workflow.SkipDependents, "")
+	assert.NoError(t, err)
+	assert.True(t, policy.SkipDependents())
+	assert.False(t, policy.FailFast())
+
+	template, err := job.NewExec([]interface{}{}, 0, job.NORMAL, 0, 0, 0, 0, "", job.NewEnvVariables(), "")
+	assert.NoError(t, err)
+	finished := template.Copy([]interface{}{})
+	finished.SetStatus(job.FINISHED)
+	finished.SetResult(1)
+	errored := template.Copy([]interface{}{})
+	errored.SetStatus(job.FINISHED)
+	errored.SetErr("failed")
+	queued := template.Copy([]interface{}{})
+	queued.SetStatus(job.QUEUED)
+
+	assert.Equal(t, job.FINISHED, workflow.Status([]job.JobRequestMultiple{*job.NewJobRequestMultiple("a", finished)}))
+	assert.Equal(t, job.FAILED, workflow.Status([]job.JobRequestMultiple{*job.NewJobRequestMultiple("a", errored)}))
+	assert.Equal(t, job.PARTIAL, workflow.Status([]job.JobRequestMultiple{*job.NewJobRequestMultiple("a", finished, errored)}))
+	assert.Equal(t, []interface{}{1}, workflow.Succeeded([]*job.Exec{finished, errored}))
+
+	workflow.Halt([]job.JobRequestMultiple{*job.NewJobRequestMultiple("a", finished, queued)})
+	assert.Equal(t, job.FINISHED, finished.GetStatus())
+	assert.Equal(t, job.CANCELLED, queued.GetStatus())
+	assert.Equal(t, workflow.ErrFailFast.Error(), queued.GetErr())
+}
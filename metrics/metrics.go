@@ -0,0 +1,117 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	Namespace = "gizo"
+	Path      = "/metrics"
+)
+
+var (
+	//ExecStatus counts exec status transitions (queued, dispatched, finished, timeout, retrying...)
+	ExecStatus = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "exec_status_total",
+		Help:      "Number of execs that transitioned to a status",
+	}, []string{"status"})
+
+	//ExecDuration observes how long execs take to run, observed by the node that runs the exec
+	ExecDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "exec_duration_seconds",
+		Help:      "Time taken to run an exec",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 4, 10),
+	})
+
+	//BlocksMined counts blocks mined by the node
+	BlocksMined = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "blocks_mined_total",
+		Help:      "Number of blocks mined by the node",
+	})
+
+	//BlocksAdded counts blocks added to the blockchain, including blocks received from peers
+	BlocksAdded = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "blocks_added_total",
+		Help:      "Number of blocks added to the blockchain",
+	})
+
+	//ChainHeight is the height of the latest block in the blockchain
+	ChainHeight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "chain_height",
+		Help:      "Height of the latest block in the blockchain",
+	})
+
+	//POWDuration observes the time taken to mine a block per difficulty
+	POWDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "pow_duration_seconds",
+		Help:      "Time taken to mine a block",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 10),
+	}, []string{"difficulty"})
+
+	//QueueWait observes the time execs wait in the job queue per priority
+	QueueWait = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "queue_wait_seconds",
+		Help:      "Time an exec waited in the job queue",
+		Buckets:   prometheus.ExponentialBuckets(0.1, 4, 10),
+	}, []string{"priority"})
+
+	//MemoLookups counts lookups of prior execs for execs of deterministic jobs by cache status (hit, miss, bypass)
+	MemoLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "memo_lookups_total",
+		Help:      "Number of execs of deterministic jobs looked up in prior execs",
+	}, []string{"status"})
+
+	//JobCacheLookups counts job cache lookups by result (hit, miss, missing)
+	JobCacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "job_cache_lookups_total",
+		Help:      "Number of jobs looked up in the job cache",
+	}, []string{"result"})
+
+	//SyncLag is the number of blocks the node is behind the latest header received while syncing
+	SyncLag = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "sync_lag_blocks",
+		Help:      "Number of blocks behind the latest synced header",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(ExecStatus, ExecDuration, BlocksMined, BlocksAdded, ChainHeight, POWDuration, QueueWait, MemoLookups, JobCacheLookups, SyncLag)
+}
+
+//ObservePOW records the time taken to mine a block at a difficulty
+func ObservePOW(difficulty int64, seconds float64) {
+	POWDuration.WithLabelValues(strconv.FormatInt(difficulty, 10)).Observe(seconds)
+}
+
+//ObserveQueueWait records the time an exec of a priority waited in the job queue
+func ObserveQueueWait(priority int, seconds float64) {
+	QueueWait.WithLabelValues(strconv.Itoa(priority)).Observe(seconds)
+}
+
+//NewGaugeFunc registers a gauge whose value is read when scraped
+func NewGaugeFunc(name, help string, f func() float64) {
+	prometheus.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      name,
+		Help:      help,
+	}, f))
+}
+
+//Handler returns the http handler that exposes the metrics
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
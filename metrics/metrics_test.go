@@ -0,0 +1,69 @@
+package metrics_test
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gizo-network/gizo/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+//returns the metrics exposed on the metrics endpoint
+func scrape(t *testing.T) string {
+	server := httptest.NewServer(metrics.Handler())
+	defer server.Close()
+	res, err := server.Client().Get(server.URL + metrics.Path)
+	assert.NoError(t, err)
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	assert.NoError(t, err)
+	return string(body)
+}
+
+//returns the value of a metric in a scrape, 0 if it isn't exposed
+func value(body, metric string) float64 {
+	for _, line := range strings.Split(body, "\n") {
+		if strings.HasPrefix(line, metric+" ") {
+			v, _ := strconv.ParseFloat(strings.TrimPrefix(line, metric+" "), 64)
+			return v
+		}
+	}
+	return 0
+}
+
+var depth sync.Once
+
+func TestMetrics(t *testing.T) {
+	depth.Do(func() {
+		metrics.NewGaugeFunc("test_depth", "Depth read when scraped", func() float64 {
+			return 42
+		})
+	})
+	//! metrics are global so the changes made by the test are compared
+	before := scrape(t)
+	metrics.ExecStatus.WithLabelValues("FINISHED").Inc()
+	metrics.ExecStatus.WithLabelValues("FINISHED").Inc()
+	metrics.ExecDuration.Observe(0.5)
+	metrics.BlocksMined.Inc()
+	metrics.ChainHeight.Set(7)
+	metrics.ObservePOW(3, 0.1)
+	metrics.ObserveQueueWait(2, 1)
+	metrics.JobCacheLookups.WithLabelValues("missing").Inc()
+
+	after := scrape(t)
+	changed := func(metric string) float64 {
+		return value(after, metric) - value(before, metric)
+	}
+	assert.Equal(t, float64(2), changed(`gizo_exec_status_total{status="FINISHED"}`))
+	assert.Equal(t, float64(1), changed("gizo_exec_duration_seconds_count"))
+	assert.Equal(t, float64(1), changed("gizo_blocks_mined_total"))
+	assert.Equal(t, float64(7), value(after, "gizo_chain_height"))
+	assert.Equal(t, float64(1), changed(`gizo_pow_duration_seconds_count{difficulty="3"}`))
+	assert.Equal(t, float64(1), changed(`gizo_queue_wait_seconds_count{priority="2"}`))
+	assert.Equal(t, float64(1), changed(`gizo_job_cache_lookups_total{result="missing"}`))
+	assert.Equal(t, float64(42), value(after, "gizo_test_depth"))
+}
@@ -0,0 +1,265 @@
+package p2p
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/kpango/glg"
+	funk "github.com/thoas/go-funk"
+)
+
+var (
+	ErrInvalidAddr  = errors.New("AddressBook: invalid dispatcher address")
+	ErrAddrBookFull = errors.New("AddressBook: address book is full")
+)
+
+//AddrInfo is an address book entry for a known dispatcher
+type AddrInfo struct {
+	Addr       string   `json:"addr"`
+	LastSeen   int64    `json:"last_seen"`  // last handshake with the dispatcher at addr, 0 if it was never dialed
+	Failures   int      `json:"failures"`   // consecutive failed dials
+	Candidates []string `json:"candidates"` // other addresses heard for the dispatcher, tried once addr fails
+}
+
+func (a AddrInfo) GetAddr() string {
+	return a.Addr
+}
+
+func (a AddrInfo) GetCandidates() []string {
+	return a.Candidates
+}
+
+//adds a candidate address, dropping the oldest past MaxAddrCandidates
+func (a *AddrInfo) addCandidate(addr string) {
+	if addr == a.GetAddr() || funk.ContainsString(a.Candidates, addr) {
+		return
+	}
+	a.Candidates = append(a.Candidates, addr)
+	if len(a.Candidates) > MaxAddrCandidates {
+		a.Candidates = a.Candidates[len(a.Candidates)-MaxAddrCandidates:]
+	}
+}
+
+//removes a candidate address
+func (a *AddrInfo) removeCandidate(addr string) {
+	for i, candidate := range a.Candidates {
+		if candidate == addr {
+			a.Candidates = append(a.Candidates[:i], a.Candidates[i+1:]...)
+			return
+		}
+	}
+}
+
+func (a AddrInfo) GetLastSeen() int64 {
+	return a.LastSeen
+}
+
+func (a AddrInfo) GetFailures() int {
+	return a.Failures
+}
+
+func (a AddrInfo) Serialize() []byte {
+	bytes, err := json.Marshal(a)
+	if err != nil {
+		glg.Fatal(err)
+	}
+	return bytes
+}
+
+func DeserializeAddrInfo(b []byte) AddrInfo {
+	var temp AddrInfo
+	err := json.Unmarshal(b, &temp)
+	if err != nil {
+		glg.Fatal(err)
+	}
+	return temp
+}
+
+//FormatAddr returns a dispatcher address in the format read by ParseAddr
+func FormatAddr(pub, ip string, port int) string {
+	return fmt.Sprintf("%v://%v@%v:%v", DispatcherScheme, pub, ip, port)
+}
+
+//AddressBook persists addresses of known dispatchers in the node db
+type AddressBook struct {
+	db *bolt.DB
+}
+
+func NewAddressBook(db *bolt.DB) *AddressBook {
+	err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(PeerBucket))
+		return err
+	})
+	if err != nil {
+		glg.Fatal(err)
+	}
+	return &AddressBook{db: db}
+}
+
+//returns the pub of a valid dispatcher address
+func addrPub(addr string) (string, error) {
+	parsed, err := url.Parse(addr)
+	if err != nil || parsed.Scheme != DispatcherScheme || parsed.User == nil || parsed.Hostname() == "" || parsed.Port() == "" {
+		return "", ErrInvalidAddr
+	}
+	if _, err = hex.DecodeString(parsed.User.Username()); err != nil || parsed.User.Username() == "" {
+		return "", ErrInvalidAddr
+	}
+	return parsed.User.Username(), nil
+}
+
+//Add stores an address heard for a dispatcher, it replaces the dispatcher's address only if that was never dialed
+//and is kept as a candidate otherwise. new dispatchers are dropped once the book holds MaxAddrBook entries
+//and none of them can be evicted
+func (a AddressBook) Add(addr string) error {
+	pub, err := addrPub(addr)
+	if err != nil {
+		return err
+	}
+	return a.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(PeerBucket))
+		v := b.Get([]byte(pub))
+		if v == nil {
+			if err := a.makeRoom(b); err != nil {
+				return err
+			}
+			return b.Put([]byte(pub), AddrInfo{Addr: addr}.Serialize())
+		}
+		info := DeserializeAddrInfo(v)
+		if info.GetAddr() == addr {
+			return nil
+		}
+		if info.GetLastSeen() > 0 {
+			info.addCandidate(addr)
+		} else {
+			info.Addr = addr
+			info.Failures = 0
+			info.removeCandidate(addr)
+		}
+		return b.Put([]byte(pub), info.Serialize())
+	})
+}
+
+//evicts a dispatcher that was never dialed if the book is full
+func (a AddressBook) makeRoom(b *bolt.Bucket) error {
+	if b.Stats().KeyN < MaxAddrBook {
+		return nil
+	}
+	var evict []byte
+	b.ForEach(func(k, v []byte) error {
+		if evict == nil && DeserializeAddrInfo(v).GetLastSeen() == 0 {
+			evict = append([]byte{}, k...)
+		}
+		return nil
+	})
+	if evict == nil {
+		return ErrAddrBookFull
+	}
+	return b.Delete(evict)
+}
+
+//GetAddrs returns every known address
+func (a AddressBook) GetAddrs() []string {
+	var addrs []string
+	for _, info := range a.GetInfos() {
+		addrs = append(addrs, info.GetAddr())
+	}
+	return addrs
+}
+
+//GetSeenAddrs returns the addresses dispatchers have been dialed at
+func (a AddressBook) GetSeenAddrs() []string {
+	var addrs []string
+	for _, info := range a.GetInfos() {
+		if info.GetLastSeen() > 0 {
+			addrs = append(addrs, info.GetAddr())
+		}
+	}
+	return addrs
+}
+
+func (a AddressBook) GetInfos() []AddrInfo {
+	var infos []AddrInfo
+	err := a.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(PeerBucket)).ForEach(func(k, v []byte) error {
+			infos = append(infos, DeserializeAddrInfo(v))
+			return nil
+		})
+	})
+	if err != nil {
+		glg.Fatal(err)
+	}
+	return infos
+}
+
+//Seen records a completed handshake with the dispatcher dialed at addr, making it the dispatcher's address
+func (a AddressBook) Seen(addr string) {
+	pub, err := addrPub(addr)
+	if err != nil {
+		return
+	}
+	a.update(pub, func(info *AddrInfo) bool {
+		if info.GetAddr() != addr {
+			info.removeCandidate(addr)
+			if info.GetAddr() != "" {
+				info.addCandidate(info.GetAddr())
+			}
+			info.Addr = addr
+		}
+		info.LastSeen = time.Now().Unix()
+		info.Failures = 0
+		return true
+	})
+}
+
+//Failed records a failed dial of addr, the dispatcher's address is replaced by its next candidate
+//after MaxAddrFailures and the dispatcher is forgotten once it has none
+func (a AddressBook) Failed(addr string) {
+	pub, err := addrPub(addr)
+	if err != nil {
+		return
+	}
+	a.update(pub, func(info *AddrInfo) bool {
+		if info.GetAddr() != addr {
+			info.removeCandidate(addr)
+			return info.GetAddr() != ""
+		}
+		info.Failures++
+		if info.GetFailures() < MaxAddrFailures {
+			return true
+		}
+		if len(info.GetCandidates()) == 0 {
+			return false
+		}
+		info.Addr = info.Candidates[0]
+		info.Candidates = info.Candidates[1:]
+		info.LastSeen = 0
+		info.Failures = 0
+		return true
+	})
+}
+
+//applies f to the entry of pub, creating it if it's missing and deleting it if f returns false
+func (a AddressBook) update(pub string, f func(info *AddrInfo) bool) {
+	err := a.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(PeerBucket))
+		var info AddrInfo
+		if v := b.Get([]byte(pub)); v != nil {
+			info = DeserializeAddrInfo(v)
+		} else if err := a.makeRoom(b); err != nil {
+			return err
+		}
+		if !f(&info) {
+			return b.Delete([]byte(pub))
+		}
+		return b.Put([]byte(pub), info.Serialize())
+	})
+	if err != nil && err != ErrAddrBookFull {
+		glg.Fatal(err)
+	}
+}
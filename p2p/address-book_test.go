@@ -0,0 +1,78 @@
+package p2p_test
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gizo-network/gizo/p2p"
+)
+
+func addressBook(t *testing.T) (*p2p.AddressBook, func()) {
+	dir, err := ioutil.TempDir("", "gizo-addrbook")
+	assert.NoError(t, err)
+	db, err := bolt.Open(path.Join(dir, p2p.NodeDB), 0600, nil)
+	assert.NoError(t, err)
+	return p2p.NewAddressBook(db), func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestAddressBook(t *testing.T) {
+	book, done := addressBook(t)
+	defer done()
+	pub := hex.EncodeToString([]byte("dispatcher"))
+	addr := p2p.FormatAddr(pub, "10.0.0.1", 9999)
+	moved := p2p.FormatAddr(pub, "10.0.0.2", 9999)
+	spoofed := p2p.FormatAddr(pub, "10.6.6.6", 9999)
+
+	assert.Equal(t, p2p.ErrInvalidAddr, book.Add("http://10.0.0.1:9999"))
+	assert.NoError(t, book.Add(addr))
+	assert.Empty(t, book.GetSeenAddrs())
+
+	//! addresses that were never dialed are replaced
+	assert.NoError(t, book.Add(moved))
+	assert.Equal(t, []string{moved}, book.GetAddrs())
+
+	book.Seen(moved)
+	assert.Equal(t, []string{moved}, book.GetSeenAddrs())
+
+	//! gossip can't replace an address that was dialed, it's kept as a candidate
+	assert.NoError(t, book.Add(spoofed))
+	infos := book.GetInfos()
+	assert.Len(t, infos, 1)
+	assert.Equal(t, moved, infos[0].GetAddr())
+	assert.Equal(t, []string{spoofed}, infos[0].GetCandidates())
+
+	//! the candidate is tried once the address keeps failing
+	for i := 0; i < p2p.MaxAddrFailures; i++ {
+		book.Failed(moved)
+	}
+	infos = book.GetInfos()
+	assert.Equal(t, spoofed, infos[0].GetAddr())
+	assert.Zero(t, infos[0].GetLastSeen())
+	assert.Empty(t, book.GetSeenAddrs())
+
+	for i := 0; i < p2p.MaxAddrFailures; i++ {
+		book.Failed(spoofed)
+	}
+	assert.Empty(t, book.GetInfos())
+}
+
+func TestAddressBookFull(t *testing.T) {
+	book, done := addressBook(t)
+	defer done()
+	for i := 0; i < p2p.MaxAddrBook; i++ {
+		addr := p2p.FormatAddr(hex.EncodeToString([]byte{byte(i >> 8), byte(i)}), "10.0.0.1", 9999)
+		assert.NoError(t, book.Add(addr))
+		book.Seen(addr)
+	}
+	assert.Equal(t, p2p.ErrAddrBookFull, book.Add(p2p.FormatAddr("ffffff", "10.0.0.1", 9999)))
+	assert.Len(t, book.GetInfos(), p2p.MaxAddrBook)
+}
@@ -0,0 +1,251 @@
+package p2p
+
+import (
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+
+	"github.com/boltdb/bolt"
+	"github.com/gizo-network/gizo/job"
+	"github.com/gizo-network/gizo/job/queue"
+	"github.com/gorilla/mux"
+	"github.com/kpango/glg"
+	uuid "github.com/satori/go.uuid"
+	melody "gopkg.in/olahol/melody.v1"
+)
+
+const (
+	AdminTokenHeader = "x-gizo-admin-token"
+)
+
+type (
+	//AdminWorker is the admin view of a connected worker
+	AdminWorker struct {
+		Pub       string    `json:"pub"`
+		Exec      *job.Exec `json:"exec"`
+		JobID     string    `json:"job_id"`
+		Shut      bool      `json:"shut"`
+		Unhealthy bool      `json:"unhealthy"`
+		Flags     int       `json:"flags"`
+		LastSeen  int64     `json:"last_seen"`
+	}
+
+	//AdminNeighbour is the admin view of a neighbouring dispatcher
+	AdminNeighbour struct {
+		Pub        string   `json:"pub"`
+		Neighbours []string `json:"neighbours"`
+	}
+
+	//AdminJobs is the admin view of jobs waiting to be written to the blockchain
+	AdminJobs struct {
+		Jobs   []job.Job `json:"jobs"`
+		WriteQ int       `json:"write_queue"`
+	}
+
+	//AdminChain is the admin view of the blockchain
+	AdminChain struct {
+		Height uint64 `json:"height"`
+		Latest string `json:"latest"`
+	}
+)
+
+//loads the configured admin token or the one in the node db, generating and logging one on first run
+func loadAdminToken(db *bolt.DB, configured string) string {
+	if configured != "" {
+		return configured
+	}
+	var token []byte
+	err := db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(NodeBucket))
+		token = b.Get([]byte("admin"))
+		if token == nil {
+			token = []byte(uuid.NewV4().String())
+			//! logged once, set admin_token to use a token of your own
+			glg.Warn("Dispatcher: generated admin token - " + string(token))
+			return b.Put([]byte("admin"), token)
+		}
+		return nil
+	})
+	if err != nil {
+		glg.Fatal(err)
+	}
+	return string(token)
+}
+
+func (d Dispatcher) GetAdminToken() string {
+	return d.adminToken
+}
+
+//rejects admin requests without a valid token
+func (d Dispatcher) adminAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if d.GetAdminToken() == "" || subtle.ConstantTimeCompare([]byte(r.Header.Get(AdminTokenHeader)), []byte(d.GetAdminToken())) != 1 {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	bytes, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(bytes)
+}
+
+//registers the admin endpoints on the dispatcher's router, the admin token is only sent in the clear
+//if the dispatcher isn't served over tls and admin_insecure is set
+func (d *Dispatcher) registerAdmin() {
+	if !d.GetTransport().GetEnabled() && !d.GetConfig().Dispatcher.AdminInsecure {
+		glg.Warn("Dispatcher: admin api disabled - enable tls or set admin_insecure to serve it over http")
+		return
+	}
+	admin := d.router.PathPrefix("/admin").Subrouter()
+	admin.Use(d.adminAuth)
+	admin.HandleFunc("/queue", d.adminQueue).Methods("GET")
+	admin.HandleFunc("/queue/{id}", d.adminCancel).Methods("DELETE")
+	admin.HandleFunc("/workers", d.adminWorkers).Methods("GET")
+	admin.HandleFunc("/workers/{pub}", d.adminEvict).Methods("DELETE")
+	admin.HandleFunc("/neighbours", d.adminNeighbours).Methods("GET")
+	admin.HandleFunc("/jobs", d.adminJobs).Methods("GET")
+	admin.HandleFunc("/chain", d.adminChain).Methods("GET")
+	admin.HandleFunc("/sync", d.adminSync).Methods("GET")
+	admin.HandleFunc("/results/{id}", d.adminResults).Methods("GET")
+	admin.HandleFunc("/submitters", d.adminSubmitters).Methods("GET")
+	admin.HandleFunc("/cache", d.adminCache).Methods("GET")
+	glg.Info("Dispatcher: admin api enabled")
+}
+
+//! execs are copied under the dispatcher's lock, results update them while the response is written
+func (d *Dispatcher) adminQueue(w http.ResponseWriter, r *http.Request) {
+	entries := []queue.Entry{}
+	d.mu.Lock()
+	for _, entry := range d.GetJobPQ().Entries() {
+		exec := *entry.GetItem().GetExec()
+		entry.Item.SetExec(&exec)
+		entries = append(entries, entry)
+	}
+	d.mu.Unlock()
+	writeJSON(w, entries)
+}
+
+func (d *Dispatcher) adminCancel(w http.ResponseWriter, r *http.Request) {
+	if err := d.GetJobPQ().Cancel(mux.Vars(r)["id"]); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	glg.Warn("Dispatcher: admin cancelled exec - " + mux.Vars(r)["id"])
+	writeJSON(w, map[string]string{"status": "success"})
+}
+
+func (d *Dispatcher) adminWorkers(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	workers := []AdminWorker{}
+	for _, info := range d.GetWorkers() {
+		worker := AdminWorker{
+			Pub:       info.GetPub(),
+			Shut:      info.GetShut(),
+			Unhealthy: info.GetUnhealthy(),
+			Flags:     info.GetFlags(),
+			LastSeen:  info.GetLastSeen(),
+		}
+		if info.GetJob() != nil {
+			exec := *info.GetJob().GetExec()
+			worker.Exec = &exec
+			worker.JobID = info.GetJob().GetID()
+		}
+		workers = append(workers, worker)
+	}
+	d.mu.Unlock()
+	writeJSON(w, workers)
+}
+
+func (d *Dispatcher) adminEvict(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var session *melody.Session
+	for s, info := range d.GetWorkers() {
+		if info.GetPub() == mux.Vars(r)["pub"] {
+			session = s
+			break
+		}
+	}
+	if session == nil {
+		http.Error(w, ErrWorkerNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	if d.GetWorker(session).GetJob() != nil {
+		d.requeue(session)
+		d.GetWorker(session).SetJob(nil)
+	}
+	d.GetWorker(session).SetShut(true)
+	d.GetWorkerPQ().Remove(session)
+	if err := d.discovery.DisconnectWorker(); err != nil {
+		glg.Warn("Discovery: " + err.Error())
+	}
+	session.Close()
+	glg.Warn("Dispatcher: admin evicted worker - " + mux.Vars(r)["pub"])
+	writeJSON(w, map[string]string{"status": "success"})
+}
+
+func (d *Dispatcher) adminNeighbours(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	neighbours := []AdminNeighbour{}
+	for _, info := range d.GetNeighbours() {
+		neighbours = append(neighbours, AdminNeighbour{
+			Pub:        hex.EncodeToString(info.GetPub()),
+			Neighbours: info.GetNeighbours(),
+		})
+	}
+	d.mu.Unlock()
+	writeJSON(w, neighbours)
+}
+
+func (d *Dispatcher) adminJobs(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	jobs := AdminJobs{
+		Jobs:   d.GetJobs(),
+		WriteQ: d.GetWriteQ().Size(),
+	}
+	d.mu.Unlock()
+	if jobs.Jobs == nil {
+		jobs.Jobs = []job.Job{}
+	}
+	writeJSON(w, jobs)
+}
+
+func (d *Dispatcher) adminSync(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, d.GetSyncer().GetProgress())
+}
+
+func (d *Dispatcher) adminResults(w http.ResponseWriter, r *http.Request) {
+	results := d.GetJobPQ().Results(mux.Vars(r)["id"])
+	if results == nil {
+		results = []queue.Record{}
+	}
+	writeJSON(w, results)
+}
+
+func (d *Dispatcher) adminSubmitters(w http.ResponseWriter, r *http.Request) {
+	usage := d.GetJobPQ().Usage()
+	if usage == nil {
+		usage = []queue.Usage{}
+	}
+	writeJSON(w, usage)
+}
+
+func (d *Dispatcher) adminCache(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, d.GetJC().Stats())
+}
+
+func (d *Dispatcher) adminChain(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, AdminChain{
+		Height: d.GetBC().GetLatestHeight(),
+		Latest: hex.EncodeToString(d.GetBC().GetLatestBlock().GetHeader().GetHash()),
+	})
+}
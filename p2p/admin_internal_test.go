@@ -0,0 +1,87 @@
+package p2p
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gizo-network/gizo/config"
+	"github.com/gizo-network/gizo/crypt"
+	"github.com/gizo-network/gizo/job"
+	"github.com/gizo-network/gizo/job/queue"
+	"github.com/gizo-network/gizo/job/queue/qItem"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+//returns a dispatcher serving the admin api over tls or with admin_insecure set
+func adminDispatcher(tls, insecure bool) *Dispatcher {
+	cfg := &config.Config{}
+	cfg.Dispatcher.AdminInsecure = insecure
+	d := &Dispatcher{
+		router:     mux.NewRouter(),
+		mu:         new(sync.Mutex),
+		jobPQ:      queue.NewJobPriorityQueue(),
+		transport:  &Transport{enabled: tls},
+		adminToken: "token",
+		cfg:        cfg,
+	}
+	d.registerAdmin()
+	return d
+}
+
+func adminRequest(t *testing.T, d *Dispatcher, method, uri, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, uri, nil)
+	if token != "" {
+		req.Header.Set(AdminTokenHeader, token)
+	}
+	res := httptest.NewRecorder()
+	d.router.ServeHTTP(res, req)
+	return res
+}
+
+func TestAdminGating(t *testing.T) {
+	//! the admin api isn't served over plain http unless admin_insecure is set
+	assert.Equal(t, http.StatusNotFound, adminRequest(t, adminDispatcher(false, false), "GET", "/admin/queue", "token").Code)
+	assert.Equal(t, http.StatusOK, adminRequest(t, adminDispatcher(false, true), "GET", "/admin/queue", "token").Code)
+	assert.Equal(t, http.StatusOK, adminRequest(t, adminDispatcher(true, false), "GET", "/admin/queue", "token").Code)
+}
+
+func TestAdminAuth(t *testing.T) {
+	d := adminDispatcher(true, false)
+	assert.Equal(t, http.StatusUnauthorized, adminRequest(t, d, "GET", "/admin/queue", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, adminRequest(t, d, "GET", "/admin/queue", "wrong").Code)
+	assert.Equal(t, http.StatusUnauthorized, adminRequest(t, d, "DELETE", "/admin/queue/id", "wrong").Code)
+	assert.Equal(t, http.StatusOK, adminRequest(t, d, "GET", "/admin/queue", "token").Code)
+	//! an empty token never authenticates
+	d.adminToken = ""
+	assert.Equal(t, http.StatusUnauthorized, adminRequest(t, d, "GET", "/admin/queue", "").Code)
+}
+
+func TestAdminQueue(t *testing.T) {
+	d := adminDispatcher(true, false)
+	priv, _ := crypt.GenKeys()
+	j := job.NewJob("func Test(){return 1}", "Test", false, hex.EncodeToString(priv))
+	exec, err := job.NewExec([]interface{}{}, 0, job.NORMAL, 0, 0, 0, 0, "", job.NewEnvVariables(), "")
+	assert.NoError(t, err)
+	assert.NoError(t, d.GetJobPQ().Push(*j, exec, make(chan qItem.Item, 1), nil))
+
+	res := adminRequest(t, d, "GET", "/admin/queue", "token")
+	assert.Equal(t, http.StatusOK, res.Code)
+	var entries []queue.Entry
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &entries))
+	assert.Len(t, entries, 1)
+	assert.Equal(t, j.GetID(), entries[0].GetItem().GetID())
+	assert.Equal(t, job.QUEUED, entries[0].GetItem().GetExec().GetStatus())
+
+	assert.Equal(t, http.StatusNotFound, adminRequest(t, d, "DELETE", "/admin/queue/unknown", "token").Code)
+	assert.Equal(t, http.StatusOK, adminRequest(t, d, "DELETE", "/admin/queue/"+entries[0].GetID(), "token").Code)
+	//! cancelled execs stay queued until they're popped and returned to their sender
+	assert.Equal(t, job.CANCELLED, exec.GetStatus())
+	res = adminRequest(t, d, "GET", "/admin/queue", "token")
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &entries))
+	assert.Equal(t, job.CANCELLED, entries[0].GetItem().GetExec().GetStatus())
+}
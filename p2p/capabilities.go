@@ -0,0 +1,61 @@
+package p2p
+
+import (
+	"errors"
+	"strconv"
+
+	funk "github.com/thoas/go-funk"
+)
+
+var (
+	ErrUnsupportedVersion = errors.New("P2P: unsupported protocol version")
+)
+
+// capabilities exchanged during hello
+const (
+	CapHeartbeat    = "heartbeat"  // answers PING with PONG
+	CapPeerExchange = "pex"        // exchanges dispatcher addresses with PEERREQ and PEERS
+	CapForward      = "forward"    // advertises capacity and runs execs forwarded by neighbours
+	CapHeaderSync   = "headersync" // syncs the chain with HEADERSREQ, HEADERS and batched BLOCKREQ and BLOCKRES
+)
+
+//Capabilities are the features supported by this node
+var Capabilities = []string{CapHeartbeat, CapPeerExchange, CapForward, CapHeaderSync}
+
+//messageVersions holds the protocol version that introduced a message, other messages are understood by every supported version
+var messageVersions = map[string]int{
+	HEADERSREQ: 3,
+	HEADERS:    3,
+}
+
+//Speaks returns true if the message is understood at the protocol version
+func Speaks(version int, message string) bool {
+	return version >= messageVersions[message]
+}
+
+//NegotiateVersion returns the protocol version used with a peer
+func NegotiateVersion(theirs int) (int, error) {
+	if theirs < MinProtocolVersion {
+		return 0, ErrUnsupportedVersion
+	}
+	if theirs < ProtocolVersion {
+		return theirs, nil
+	}
+	return ProtocolVersion, nil
+}
+
+//NegotiateCapabilities returns the capabilities supported by both this node and a peer
+func NegotiateCapabilities(theirs []string) []string {
+	var caps []string
+	for _, c := range theirs {
+		if funk.ContainsString(Capabilities, c) && !funk.ContainsString(caps, c) {
+			caps = append(caps, c)
+		}
+	}
+	return caps
+}
+
+//VersionReason returns the reason sent to peers with an unsupported version
+func VersionReason(theirs int) string {
+	return "protocol version " + strconv.Itoa(theirs) + " not supported, minimum is " + strconv.Itoa(MinProtocolVersion)
+}
@@ -0,0 +1,22 @@
+package p2p_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gizo-network/gizo/p2p"
+)
+
+func TestSpeaks(t *testing.T) {
+	assert.True(t, p2p.Speaks(2, p2p.BLOCK))
+	assert.False(t, p2p.Speaks(2, p2p.HEADERSREQ))
+	assert.True(t, p2p.Speaks(2, p2p.BLOCKREQ)) //! single block with peers without header sync
+	assert.True(t, p2p.Speaks(p2p.ProtocolVersion, p2p.HEADERSREQ))
+
+	info := p2p.NewDispatcherInfo(nil)
+	info.SetProtocol(2, nil)
+	assert.False(t, info.Speaks(p2p.HEADERS))
+	info.SetProtocol(p2p.ProtocolVersion, nil)
+	assert.True(t, info.Speaks(p2p.HEADERS))
+}
@@ -0,0 +1,39 @@
+package p2p
+
+import (
+	"encoding/json"
+
+	"github.com/kpango/glg"
+)
+
+//Capacity is advertised to neighbours so they can forward queued execs to dispatchers with idle workers
+type Capacity struct {
+	Idle   int `json:"idle"`   // workers waiting for a job
+	Queued int `json:"queued"` // execs waiting for a worker
+}
+
+func NewCapacity(idle, queued int) Capacity {
+	return Capacity{Idle: idle, Queued: queued}
+}
+
+func (c Capacity) GetIdle() int {
+	return c.Idle
+}
+
+func (c Capacity) GetQueued() int {
+	return c.Queued
+}
+
+func (c Capacity) Serialize() []byte {
+	bytes, err := json.Marshal(c)
+	if err != nil {
+		glg.Fatal(err)
+	}
+	return bytes
+}
+
+func DeserializeCapacity(b []byte) (Capacity, error) {
+	var temp Capacity
+	err := json.Unmarshal(b, &temp)
+	return temp, err
+}
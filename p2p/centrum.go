@@ -1,31 +1,39 @@
 package p2p
 
 import (
+	"encoding/hex"
 	"errors"
+	"time"
 
 	"github.com/dghubble/sling"
+	"github.com/gizo-network/gizo/crypt"
+	"github.com/gizo-network/gizo/registry"
 	"github.com/kpango/glg"
 )
 
 var (
-	s          = sling.New().Base(CentrumURL).Add("User-Agent", "Gizo Node")
 	ErrNoToken = errors.New("Centrum: No token in struct")
 )
 
 type (
 	DispatcherBody struct {
-		Pub  string `url:"pub"`
-		Ip   string `url:"ip"`
-		Port int    `url:"port"`
+		Pub   string `url:"pub"`
+		Ip    string `url:"ip"`
+		Port  int    `url:"port"`
+		Nonce int64  `url:"nonce"`
+		R     string `url:"r"` // signature of the registration by the node key
+		S     string `url:"s"`
 	}
 
+	//Centrum discovers dispatchers through a centrum registry
 	Centrum struct {
 		token string
+		s     *sling.Sling
 	}
 )
 
-func NewCentrum() *Centrum {
-	return new(Centrum)
+func NewCentrum(url string) *Centrum {
+	return &Centrum{s: sling.New().Base(url).Add("User-Agent", "Gizo Node")}
 }
 
 func (c Centrum) GetToken() string {
@@ -36,25 +44,31 @@ func (c *Centrum) SetToken(token string) {
 	c.token = token
 }
 
-func (c Centrum) GetDispatchers() map[string]interface{} {
+func (c Centrum) GetDispatchers() ([]string, error) {
 	var dispatchers []string
-	temp := make(map[string]interface{})
-	_, err := s.New().Get("/v1/dispatchers").Receive(&dispatchers, &temp)
+	res := make(map[string]interface{})
+	_, err := c.s.New().Get("/v1/dispatchers").Receive(&dispatchers, &res)
 	if err != nil {
-		glg.Fatal(err)
+		return nil, err
 	}
-	if len(dispatchers) != 0 {
-		temp["dispatchers"] = dispatchers
+	if len(dispatchers) == 0 {
+		return nil, ErrNoDispatchers
 	}
-	return temp
+	return dispatchers, nil
 }
 
-func (c *Centrum) NewDisptcher(pub, ip string, port int) error {
-	data := DispatcherBody{Pub: pub, Ip: ip, Port: port}
+//Register announces the dispatcher to centrum, the registration is signed with priv so only the holder of pub's key can claim it
+func (c *Centrum) Register(priv []byte, pub, ip string, port int) error {
+	nonce := time.Now().UnixNano()
+	sig, err := crypt.Sign(priv, registry.RegisterMessage(pub, ip, port, nonce))
+	if err != nil {
+		return err
+	}
+	data := DispatcherBody{Pub: pub, Ip: ip, Port: port, Nonce: nonce, R: hex.EncodeToString(sig[0]), S: hex.EncodeToString(sig[1])}
 	res := make(map[string]interface{})
-	_, err := s.Post("/v1/dispatcher").BodyForm(data).Receive(&res, &res)
+	_, err = c.s.New().Post("/v1/dispatcher").BodyForm(data).Receive(&res, &res)
 	if err != nil {
-		glg.Fatal(err)
+		return err
 	}
 	token, ok := res["token"]
 	if !ok {
@@ -64,52 +78,36 @@ func (c *Centrum) NewDisptcher(pub, ip string, port int) error {
 	return nil
 }
 
-func (c Centrum) ConnectWorker() (map[string]interface{}, error) {
+//sends an authenticated update about the dispatcher to centrum
+func (c Centrum) patch(endpoint string) error {
 	if c.GetToken() == "" {
-		return nil, ErrNoToken
+		return ErrNoToken
 	}
 	res := make(map[string]interface{})
-	_, err := s.Patch("/v1/dispatcher/connect").Set("x-gizo-token", c.GetToken()).Receive(&res, &res)
+	_, err := c.s.New().Patch(endpoint).Set("x-gizo-token", c.GetToken()).Receive(&res, &res)
 	if err != nil {
-		glg.Fatal(err)
+		return err
 	}
-	return res, nil
+	if status, ok := res["status"].(string); ok && status != "success" {
+		return errors.New("Centrum: " + status)
+	}
+	return nil
 }
 
-func (c Centrum) DisconnectWorker() (map[string]interface{}, error) {
-	if c.GetToken() == "" {
-		return nil, ErrNoToken
-	}
-	res := make(map[string]interface{})
-	_, err := s.Patch("/v1/dispatcher/disconnect").Set("x-gizo-token", c.GetToken()).Receive(&res, &res)
-	if err != nil {
-		glg.Fatal(err)
-	}
-	return res, nil
+func (c Centrum) ConnectWorker() error {
+	return c.patch("/v1/dispatcher/connect")
 }
 
-func (c Centrum) Wake() (map[string]interface{}, error) {
-	if c.GetToken() == "" {
-		return nil, ErrNoToken
-	}
-	res := make(map[string]interface{})
-	_, err := s.Patch("/v1/dispatcher/wake").Set("x-gizo-token", c.GetToken()).Receive(&res, &res)
-	if err != nil {
-		glg.Fatal(err)
-	}
+func (c Centrum) DisconnectWorker() error {
+	return c.patch("/v1/dispatcher/disconnect")
+}
+
+func (c Centrum) Wake() error {
 	glg.Warn("Centrum: waking node")
-	return res, nil
+	return c.patch("/v1/dispatcher/wake")
 }
 
-func (c Centrum) Sleep() (map[string]interface{}, error) {
-	if c.GetToken() == "" {
-		return nil, ErrNoToken
-	}
-	res := make(map[string]interface{})
-	_, err := s.Patch("/v1/dispatcher/sleep").Set("x-gizo-token", c.GetToken()).Receive(&res, &res)
-	if err != nil {
-		glg.Fatal(err)
-	}
+func (c Centrum) Sleep() error {
 	glg.Warn("Centrum: sleeping node")
-	return res, nil
+	return c.patch("/v1/dispatcher/sleep")
 }
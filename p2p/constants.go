@@ -1,17 +1,88 @@
 package p2p
 
-import "errors"
+import (
+	"errors"
+	"time"
+)
 
 const (
 	NodeDB           = "nodeinfo.db"
 	NodeBucket       = "node"
-	DispatcherScheme = "gizo" //FIXME: use better one
-	MaxWorkers       = 128
+	PeerBucket       = "peers" // address book of known dispatchers
+	DispatcherScheme = "gizo"  //FIXME: use better one
 	DefaultPort      = 9999
-	CentrumURL       = "https://f3482d64.ngrok.io"
 	GizoVersion      = 1
 )
 
+// protocol versions
+const (
+	ProtocolVersion    = 3 // version of the peer message envelope, handshake and chain sync
+	MinProtocolVersion = 2 // oldest version peers are accepted with, header sync is only used with peers advertising it
+)
+
+// heartbeats
+const (
+	HeartbeatInterval = time.Second * 10 // interval between pings sent to workers
+	HeartbeatTimeout  = time.Second * 30 // time without a message after which a worker is considered hung
+	DeadlineGrace     = time.Second * 30 // time allowed on top of an exec's ttl before it's considered stuck
+)
+
+// handshake and replay protection
+const (
+	HelloDomain   = "gizo-hello:" // signed by the peer answering a hello so its response can't be relayed as an auth
+	AuthDomain    = "gizo-auth:"  // signed by the peer that sent the first hello
+	ChallengeSize = 32
+	NonceSize     = 16
+	MaxMessageAge = time.Minute * 5 // signed messages older or further in the future are rejected
+)
+
+// discovery
+const (
+	MaxRegisterBackoff = time.Minute * 5 // max time between attempts to register with discovery
+)
+
+// nat traversal
+const (
+	NATPMPLifetime = time.Hour // lifetime requested for nat-pmp port mappings
+)
+
+// peer exchange
+const (
+	GossipInterval    = time.Second * 30 // interval between peer exchanges with neighbours
+	MaxAddrFailures   = 3                // failed dials after which an address is replaced by a candidate or forgotten
+	MaxAddrCandidates = 4                // other addresses kept per dispatcher
+	MaxAddrBook       = 1000             // dispatchers kept in the address book
+	MaxGossipAddrs    = 100              // max addresses accepted in a single peer exchange
+)
+
+// job forwarding
+const (
+	ForwardInterval = time.Second * 5 // interval between capacity advertisements to neighbours
+	ForwardTimeout  = time.Minute * 5 // time on top of an exec's ttl after which a forwarded exec is requeued locally
+	MaxForwardBatch = 16              // max execs forwarded to a neighbour at once
+)
+
+// rate limiting
+const (
+	MaxBuckets = 10000 // buckets kept by a rate limiter before refilled ones are dropped
+	MaxResend  = 32    // sent messages kept by a worker in case the dispatcher rate limits them
+)
+
+// drain
+const (
+	DrainPoll      = time.Second      // interval between checks for running execs while draining
+	HandoffTimeout = time.Second * 10 // time waited for a neighbour to acknowledge handed off execs
+)
+
+// chain sync
+const (
+	MaxHeaders      = 500              // max headers sent in a single HEADERS message
+	SyncBatchSize   = 16               // max blocks requested in a single BLOCKREQ
+	MaxSyncInflight = 4                // batches requested from a peer at once
+	SyncWindow      = 1024             // max blocks downloaded ahead of the latest applied block
+	SyncTimeout     = time.Second * 30 // time after which an unanswered batch is requested from another peer
+)
+
 // node states
 const (
 	// when a node is not connected to the network
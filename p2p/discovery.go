@@ -0,0 +1,35 @@
+package p2p
+
+import (
+	"errors"
+
+	"github.com/gizo-network/gizo/config"
+)
+
+var (
+	ErrUnknownDiscovery = errors.New("Discovery: unknown discovery mode")
+)
+
+//Discovery finds dispatchers on the network and announces a dispatcher to it
+type Discovery interface {
+	GetDispatchers() ([]string, error)
+	Register(priv []byte, pub, ip string, port int) error // priv signs the registration
+	ConnectWorker() error
+	DisconnectWorker() error
+	Wake() error
+	Sleep() error
+	GetToken() string
+	SetToken(token string)
+}
+
+//NewDiscovery returns the discovery configured for the node
+func NewDiscovery(cfg *config.Config) (Discovery, error) {
+	switch cfg.Discovery {
+	case config.DiscoveryCentrum:
+		return NewCentrum(cfg.CentrumURL), nil
+	case config.DiscoveryStatic:
+		return NewStatic(cfg.Bootstrap), nil
+	default:
+		return nil, ErrUnknownDiscovery
+	}
+}
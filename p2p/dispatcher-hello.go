@@ -7,18 +7,43 @@ import (
 )
 
 type DispatcherHello struct {
-	Pub        []byte
-	Neighbours []string
+	Version      int      // protocol version of the sender
+	Capabilities []string // features supported by the sender
+	Pub          []byte
+	Addr         string // address the dispatcher can be dialed on
+	Neighbours   []string
+	Challenge    []byte   // nonce the peer must sign
+	Response     [][]byte // signature over the peer's challenge
 }
 
-func NewDispatcherHello(pub []byte, n []string) DispatcherHello {
-	return DispatcherHello{Pub: pub, Neighbours: n}
+func NewDispatcherHello(pub []byte, addr string, n []string, challenge []byte, response [][]byte) DispatcherHello {
+	return DispatcherHello{Version: ProtocolVersion, Capabilities: Capabilities, Pub: pub, Addr: addr, Neighbours: n, Challenge: challenge, Response: response}
+}
+
+func (d DispatcherHello) GetVersion() int {
+	return d.Version
+}
+
+func (d DispatcherHello) GetCapabilities() []string {
+	return d.Capabilities
 }
 
 func (d DispatcherHello) GetPub() []byte {
 	return d.Pub
 }
 
+func (d DispatcherHello) GetAddr() string {
+	return d.Addr
+}
+
+func (d DispatcherHello) GetChallenge() []byte {
+	return d.Challenge
+}
+
+func (d DispatcherHello) GetResponse() [][]byte {
+	return d.Response
+}
+
 func (d DispatcherHello) GetNeighbours() []string {
 	return d.Neighbours
 }
@@ -39,11 +64,8 @@ func (d DispatcherHello) Serialize() []byte {
 	return bytes
 }
 
-func DeserializeDispatcherHello(b []byte) DispatcherHello {
+func DeserializeDispatcherHello(b []byte) (DispatcherHello, error) {
 	var temp DispatcherHello
 	err := json.Unmarshal(b, &temp)
-	if err != nil {
-		glg.Fatal(err)
-	}
-	return temp
+	return temp, err
 }
@@ -1,9 +1,14 @@
 package p2p
 
+import funk "github.com/thoas/go-funk"
+
 type DispatcherInfo struct {
 	pub        []byte
 	neighbours []string
 	shut       bool
+	version    int      // negotiated protocol version
+	caps       []string // negotiated capabilities
+	capacity   Capacity // latest capacity advertised by the dispatcher
 }
 
 func NewDispatcherInfo(pub []byte) *DispatcherInfo {
@@ -18,6 +23,38 @@ func (w *DispatcherInfo) SetPub(pub []byte) {
 	w.pub = pub
 }
 
+func (w DispatcherInfo) GetVersion() int {
+	return w.version
+}
+
+func (w DispatcherInfo) GetCapabilities() []string {
+	return w.caps
+}
+
+//SetProtocol sets the negotiated protocol version and capabilities
+func (w *DispatcherInfo) SetProtocol(version int, caps []string) {
+	w.version = version
+	w.caps = caps
+}
+
+//Supports returns true if the capability was negotiated with the dispatcher
+func (w DispatcherInfo) Supports(c string) bool {
+	return funk.ContainsString(w.GetCapabilities(), c)
+}
+
+//Speaks returns true if the message is understood at the protocol version negotiated with the dispatcher
+func (w DispatcherInfo) Speaks(message string) bool {
+	return Speaks(w.GetVersion(), message)
+}
+
+func (w DispatcherInfo) GetCapacity() Capacity {
+	return w.capacity
+}
+
+func (w *DispatcherInfo) SetCapacity(c Capacity) {
+	w.capacity = c
+}
+
 func (w DispatcherInfo) GetShut() bool {
 	return w.shut
 }
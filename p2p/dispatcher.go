@@ -6,7 +6,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
 	"net/http"
 	"os"
 	"os/signal"
@@ -17,7 +16,7 @@ import (
 	"time"
 
 	"github.com/Lobarr/lane"
-	upnp "github.com/NebulousLabs/go-upnp"
+	"github.com/gizo-network/gizo/config"
 
 	"github.com/gizo-network/gizo/core/difficulty"
 	"github.com/gizo-network/gizo/core/merkletree"
@@ -26,6 +25,9 @@ import (
 
 	"github.com/gizo-network/gizo/helpers"
 	"github.com/gizo-network/gizo/job/queue"
+	"github.com/gizo-network/gizo/job/queue/qItem"
+	"github.com/gizo-network/gizo/job/workflow"
+	"github.com/gizo-network/gizo/metrics"
 	funk "github.com/thoas/go-funk"
 	melody "gopkg.in/olahol/melody.v1"
 
@@ -44,34 +46,54 @@ import (
 )
 
 var (
-	ErrJobsFull = errors.New("Jobs array full")
+	ErrJobsFull       = errors.New("Jobs array full")
+	ErrNoMajority     = errors.New("Dispatcher: replicas did not reach a majority result")
+	ErrWorkerNotFound = errors.New("Dispatcher: worker not found")
+	ErrRateLimited    = errors.New("Dispatcher: rate limited, retry after the time in the Retry-After header")
 )
 
 type Dispatcher struct {
-	IP        string
-	Port      uint   //port
-	Pub       []byte //public key of the node
-	priv      []byte //private key of the node
-	uptime    int64  //time since node has been up
-	jobPQ     *queue.JobPriorityQueue
-	workers   map[*melody.Session]*WorkerInfo //worker nodes in dispatcher's area
-	neighbors map[interface{}]*DispatcherInfo
-	workerPQ  *WorkerPriorityQueue
-	bench     benchmark.Engine //benchmark of node
-	wWS       *melody.Melody   //workers ws server
-	dWS       *melody.Melody   //dispatchers ws server
-	rpc       *rpc.Server
-	router    *mux.Router
-	jc        *cache.JobCache  //job cache
-	bc        *core.BlockChain //blockchain
-	db        *bolt.DB         //holds topology table
-	mu        *sync.Mutex
-	jobs      []job.Job // holds done jobs and new jobs submitted to the network before being placed in the bc
-	interrupt chan os.Signal
-	writeQ    *lane.Queue // queue of job (execs) to be written to the db
-	centrum   *Centrum
-	discover  *upnp.IGD
-	new       bool // if true, sends a new dispatcher to centrum else sends a wake with token
+	IP         string
+	Port       uint   //port
+	Pub        []byte //public key of the node
+	priv       []byte //private key of the node
+	uptime     int64  //time since node has been up
+	jobPQ      *queue.JobPriorityQueue
+	workflows  *workflow.Store                 // records of workflows submitted through the dispatcher
+	workers    map[*melody.Session]*WorkerInfo //worker nodes in dispatcher's area
+	replicas   map[*job.Exec]*ReplicaSet       // execs replicated across workers for cross-verification
+	parked     map[*job.Exec]qItem.Item        // replicated execs waiting for workers they haven't been dispatched to
+	neighbors  map[interface{}]*DispatcherInfo
+	workerPQ   *WorkerPriorityQueue
+	bench      benchmark.Engine //benchmark of node
+	wWS        *melody.Melody   //workers ws server
+	dWS        *melody.Melody   //dispatchers ws server
+	rpc        *rpc.Server
+	router     *mux.Router
+	jc         *cache.JobCache  //job cache
+	bc         *core.BlockChain //blockchain
+	db         *bolt.DB         //holds topology table
+	mu         *sync.Mutex
+	jobs       []job.Job // holds done jobs and new jobs submitted to the network before being placed in the bc
+	interrupt  chan os.Signal
+	writeQ     *lane.Queue // queue of job (execs) to be written to the db
+	discovery  Discovery
+	addrBook   *AddressBook // known dispatcher addresses
+	syncer     *Syncer
+	forwarded  map[string]*forwarded        // execs forwarded to neighbours keyed by forward id
+	foreign    map[chan<- qItem.Item]string // results channels of execs forwarded by neighbours mapped to the origin's pub
+	draining   chan struct{}                // closed once the dispatcher starts draining
+	handoffs   chan handoffAck              // acknowledgements of execs handed off while draining
+	transport  *Transport
+	pending    map[*melody.Session]*PendingAuth // peers that have yet to answer the handshake challenge
+	replay     *ReplayGuard
+	connLimit  *RateLimiter // websocket connections per remote ip and pub key
+	msgLimit   *RateLimiter // peer messages per remote ip and pub key
+	rpcLimit   *RateLimiter // rpc calls per remote ip and pub key
+	nat        NAT
+	new        bool   // if true, registers a new dispatcher with discovery else sends a wake with token
+	adminToken string // token required by the admin api
+	cfg        *config.Config
 }
 
 func (d Dispatcher) GetJobs() []job.Job {
@@ -98,6 +120,7 @@ func (d Dispatcher) WriteJobs(jobs []job.Job) {
 	if err != nil {
 		glg.Fatal(err)
 	}
+	metrics.BlocksMined.Inc()
 	d.BroadcastNeighbours(BlockMessage(block.Serialize(), d.GetPrivByte()))
 }
 
@@ -172,10 +195,23 @@ func (d Dispatcher) GetJobPQ() *queue.JobPriorityQueue {
 	return d.jobPQ
 }
 
+//GetWorkflows returns the store of workflow records
+func (d Dispatcher) GetWorkflows() *workflow.Store {
+	return d.workflows
+}
+
 func (d Dispatcher) GetWorkers() map[*melody.Session]*WorkerInfo {
 	return d.workers
 }
 
+func (d Dispatcher) GetReplicas() map[*job.Exec]*ReplicaSet {
+	return d.replicas
+}
+
+func (d Dispatcher) GetParked() map[*job.Exec]qItem.Item {
+	return d.parked
+}
+
 func (d Dispatcher) GetWorker(s *melody.Session) *WorkerInfo {
 	return d.GetWorkers()[s]
 }
@@ -212,6 +248,31 @@ func (d Dispatcher) GetPort() int {
 	return int(d.Port)
 }
 
+//GetPublicPort returns the port announced to the network
+func (d Dispatcher) GetPublicPort() int {
+	if d.GetConfig().Dispatcher.PublicPort != 0 {
+		return d.GetConfig().Dispatcher.PublicPort
+	}
+	return d.GetPort()
+}
+
+func (d Dispatcher) GetPending() map[*melody.Session]*PendingAuth {
+	return d.pending
+}
+
+//verifies the signature of a message from pub and rejects replays
+func (d Dispatcher) verify(m PeerMessage, pub string) bool {
+	return m.VerifySignature(pub) && d.replay.Check(m)
+}
+
+func (d Dispatcher) GetTransport() *Transport {
+	return d.transport
+}
+
+func (d Dispatcher) GetNAT() NAT {
+	return d.nat
+}
+
 func (d Dispatcher) GetUptme() int64 {
 	return d.uptime
 }
@@ -228,6 +289,27 @@ func (d Dispatcher) GetBenchmarks() []benchmark.Benchmark {
 	return d.bench.GetData()
 }
 
+func (d Dispatcher) GetAddrBook() *AddressBook {
+	return d.addrBook
+}
+
+//GetAddr returns the address neighbours can dial the dispatcher on
+func (d Dispatcher) GetAddr() string {
+	return FormatAddr(d.GetPubString(), d.GetIP(), d.GetPublicPort())
+}
+
+func (d Dispatcher) GetSyncer() *Syncer {
+	return d.syncer
+}
+
+func (d Dispatcher) GetDiscovery() Discovery {
+	return d.discovery
+}
+
+func (d Dispatcher) GetConfig() *config.Config {
+	return d.cfg
+}
+
 func (d Dispatcher) GetJC() *cache.JobCache {
 	return d.jc
 }
@@ -264,28 +346,14 @@ func (d Dispatcher) BroadcastWorkers(m []byte) {
 
 func (d Dispatcher) BroadcastNeighbours(m []byte) {
 	for neighbour, _ := range d.GetNeighbours() {
-		switch n := neighbour.(type) {
-		case *melody.Session:
-			n.Write(m)
-			break
-		case *websocket.Conn:
-			n.WriteMessage(websocket.BinaryMessage, m)
-			break
-		}
+		writePeer(neighbour, m)
 	}
 }
 
 func (d Dispatcher) MulticastNeighbours(m []byte, neigbhours []string) {
 	for neighbour, info := range d.GetNeighbours() {
 		if funk.ContainsString(neigbhours, hex.EncodeToString(info.GetPub())) {
-			switch n := neighbour.(type) {
-			case *melody.Session:
-				n.Write(m)
-				break
-			case *websocket.Conn:
-				n.WriteMessage(websocket.BinaryMessage, m)
-				break
-			}
+			writePeer(neighbour, m)
 		}
 	}
 }
@@ -297,116 +365,451 @@ func (d Dispatcher) WorkerExists(s *melody.Session) bool {
 
 func (d *Dispatcher) deployJobs() {
 	for {
-		if d.GetWorkerPQ().getPQ().Empty() == false {
-			if d.GetJobPQ().GetPQ().Empty() == false {
-				d.mu.Lock()
+		if d.GetWorkerPQ().getPQ().Empty() == false && !d.GetDraining() {
+			d.mu.Lock()
+			if len(d.GetParked()) != 0 {
+				d.unpark()
+			}
+			//! queues are checked again under the lock, handlers may have emptied them
+			if d.GetWorkerPQ().getPQ().Empty() || d.GetJobPQ().Empty() {
+				d.mu.Unlock()
+				continue
+			}
+			j := d.GetJobPQ().Pop()
+			if j.GetExec() == nil {
+				d.mu.Unlock()
+				continue
+			}
+			if j.GetExec().GetStatus() == job.CANCELLED {
+				d.GetJobPQ().Complete(j.GetExec(), *j.GetExec())
+				j.ResultsChan() <- j
+			} else if j.GetExec().GetReplicas() > 1 {
+				d.deployReplicas(j)
+			} else {
 				w := d.GetWorkerPQ().Pop()
 				if !d.GetWorker(w).GetShut() {
-					j := d.GetJobPQ().Pop()
-					if j.GetExec().GetStatus() != job.CANCELLED {
-						j.GetExec().SetBy(d.GetWorker(w).GetPub())
-						d.GetWorker(w).Assign(&j)
-						glg.Info("P2P: dispatched job")
-						w.Write(JobMessage(j.Serialize(), d.GetPrivByte()))
-					} else {
-						j.ResultsChan() <- j
-					}
+					j.GetExec().SetBy(d.GetWorker(w).GetPub())
+					j.GetExec().SetStatus(job.DISPATHCHED)
+					d.GetJobPQ().Update(j.GetExec())
+					d.GetWorker(w).Assign(&j)
+					glg.Info("P2P: dispatched job")
+					w.Write(JobMessage(j.Serialize(), d.GetPrivByte()))
 				} else {
 					delete(d.GetWorkers(), w)
+					d.GetJobPQ().PushItem(j, j.GetExec().GetPriority())
 				}
-				d.mu.Unlock()
+			}
+			d.mu.Unlock()
+		}
+	}
+}
+
+//dispatches a replicated exec to distinct workers, it's parked until a worker it hasn't been dispatched to is idle
+//if there aren't enough workers available
+func (d *Dispatcher) deployReplicas(j qItem.Item) {
+	rs, ok := d.GetReplicas()[j.GetExec()]
+	if !ok {
+		rs = NewReplicaSet(j)
+		d.GetReplicas()[j.GetExec()] = rs
+	}
+	var skipped []*melody.Session
+	for rs.GetPending() > 0 && d.GetWorkerPQ().getPQ().Empty() == false {
+		w := d.GetWorkerPQ().Pop()
+		if d.GetWorker(w).GetShut() {
+			delete(d.GetWorkers(), w)
+			continue
+		}
+		if rs.Assigned(d.GetWorker(w).GetPub()) {
+			skipped = append(skipped, w)
+			continue
+		}
+		item := j
+		item.GetExec().SetBy(d.GetWorker(w).GetPub())
+		item.GetExec().SetStatus(job.DISPATHCHED)
+		d.GetJobPQ().Update(item.GetExec())
+		d.GetWorker(w).Assign(&item)
+		rs.Assign(d.GetWorker(w).GetPub())
+		glg.Info("P2P: dispatched replica")
+		w.Write(JobMessage(item.Serialize(), d.GetPrivByte()))
+	}
+	for _, w := range skipped {
+		d.GetWorkerPQ().Push(w, 0)
+	}
+	if rs.GetPending() > 0 {
+		glg.Info("P2P: parked replica - waiting for " + strconv.Itoa(rs.GetPending()) + " distinct workers")
+		d.GetParked()[j.GetExec()] = j
+	}
+}
+
+//dispatches parked execs that have an idle worker they haven't been dispatched to
+func (d *Dispatcher) unpark() {
+	for exec, item := range d.GetParked() {
+		rs := d.GetReplicas()[exec]
+		for s, info := range d.GetWorkers() {
+			if !info.GetShut() && !rs.Assigned(info.GetPub()) && d.GetWorkerPQ().Exist(s) {
+				delete(d.GetParked(), exec)
+				d.deployReplicas(item)
+				break
 			}
 		}
 	}
 }
 
-func (d Dispatcher) wPeerTalk() {
+//requeues the job assigned to a worker
+func (d *Dispatcher) requeue(s *melody.Session) {
+	if rs, ok := d.GetReplicas()[d.GetWorker(s).GetJob().GetExec()]; ok {
+		rs.IncrPending()
+		if rs.GetPending() > 1 {
+			return // replica set already queued or parked
+		}
+	}
+	d.GetJobPQ().PushItem(*d.GetWorker(s).GetJob(), job.HIGH)
+}
+
+//collects the result of a replicated exec and writes the majority result once every replica is done
+func (d *Dispatcher) collectReplica(s *melody.Session, rs *ReplicaSet, exec job.Exec) {
+	rs.AddResult(d.GetWorker(s).GetPub(), exec)
+	d.GetWorker(s).SetJob(nil)
+	if !rs.Done() {
+		return
+	}
+	delete(d.GetReplicas(), rs.GetItem().GetExec())
+	item := rs.GetItem()
+	chosen, agreed, disagreed := rs.Majority()
+	for key, info := range d.GetWorkers() {
+		if funk.ContainsString(disagreed, info.GetPub()) {
+			glg.Warn("Dispatcher: flagging worker - " + info.GetPub())
+			d.GetWorker(key).Flag()
+		}
+	}
+	if chosen == nil {
+		glg.Warn("Dispatcher: replicas did not agree on a result")
+		item.GetExec().SetErr(ErrNoMajority.Error())
+		d.GetJobPQ().Complete(item.GetExec(), *item.GetExec())
+		item.ResultsChan() <- item
+		return
+	}
+	glg.Info("Dispatcher: " + strconv.Itoa(len(agreed)) + " replicas agreed on result")
+	d.GetJobPQ().Complete(item.GetExec(), *chosen)
+	item.SetExec(chosen)
+	item.ResultsChan() <- item
+	j := item.GetJob()
+	j.AddExec(*chosen)
+	d.AddJob(j)
+}
+
+//checks workers every heartbeat interval
+func (d *Dispatcher) watchWorkers() {
+	ticker := time.NewTicker(HeartbeatInterval)
+	for {
+		select {
+		case <-ticker.C:
+			d.checkWorkers()
+		}
+	}
+}
+
+//pings workers and requeues the jobs of workers that miss a heartbeat or their job's deadline
+func (d *Dispatcher) checkWorkers() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for s, info := range d.GetWorkers() {
+		if info.GetShut() {
+			continue
+		}
+		if (info.Supports(CapHeartbeat) && info.Hung()) || info.Overdue() {
+			if !info.GetUnhealthy() {
+				glg.Warn("Dispatcher: worker unhealthy - " + info.GetPub())
+			}
+			if info.GetJob() != nil {
+				d.requeue(s)
+				info.SetJob(nil)
+			}
+			info.SetUnhealthy(true)
+			d.GetWorkerPQ().Remove(s)
+		}
+		if info.Supports(CapHeartbeat) {
+			s.Write(PingMessage(d.GetPrivByte()))
+		}
+	}
+}
+
+//takes a token for a message from the session's pub key, or its ip before the handshake, the peer is told when to resend
+//the message if it's over the limit
+func (d *Dispatcher) allowMessage(s *melody.Session, m PeerMessage) bool {
+	if unlimitedMessages[m.GetMessage()] {
+		return true
+	}
+	keys := limitKeys(s.Request)
+	d.mu.Lock()
+	//! authenticated peers are only limited by pub key so peers behind the same ip don't share a bucket
+	if d.WorkerExists(s) {
+		keys = []string{"pub:" + d.GetWorker(s).GetPub()}
+	} else if info := d.GetNeighbour(s); info != nil {
+		keys = []string{"pub:" + hex.EncodeToString(info.GetPub())}
+	}
+	d.mu.Unlock()
+	ok, wait := d.msgLimit.Allow(keys...)
+	if !ok {
+		s.Write(RateLimitMessage(wait, m.GetNonce()))
+	}
+	return ok
+}
+
+func (d *Dispatcher) wPeerTalk() {
 	d.wWS.HandleDisconnect(func(s *melody.Session) {
 		d.mu.Lock()
+		delete(d.GetPending(), s)
+		if !d.WorkerExists(s) {
+			d.mu.Unlock()
+			return
+		}
 		glg.Info("Dispatcher: worker disconnected")
 		if d.GetWorker(s).GetJob() != nil {
-			d.GetJobPQ().PushItem(*d.GetWorker(s).GetJob(), job.HIGH)
+			d.requeue(s)
 		}
 		d.GetWorker(s).SetShut(true)
 		d.mu.Unlock()
 	})
 	d.wWS.HandleMessageBinary(func(s *melody.Session, message []byte) {
-		m := DeserializePeerMessage(message)
+		m, err := DeserializePeerMessage(message)
+		if err != nil {
+			s.Write(InvalidMessage())
+			return
+		}
+		if !d.allowMessage(s, m) {
+			return
+		}
+		if !m.Compatible() {
+			s.Write(ErrorMessage(ErrCodeVersion, VersionReason(m.GetVersion())))
+			s.Close()
+			return
+		}
+		if m.GetMessage() == ERROR {
+			logPeerError("Dispatcher: worker error", m)
+			return
+		}
+		d.mu.Lock()
+		authenticated := d.WorkerExists(s)
+		speaks := true
+		if authenticated {
+			d.GetWorker(s).Seen()
+			speaks = d.GetWorker(s).Speaks(m.GetMessage())
+		}
+		d.mu.Unlock()
+		if !authenticated && m.GetMessage() != HELLO && m.GetMessage() != AUTH {
+			s.Write(InvalidMessage())
+			return
+		}
+		if !speaks {
+			//! newer than the version negotiated with the worker
+			s.Write(UnknownMessage(m.GetMessage()))
+			return
+		}
 		switch m.GetMessage() {
 		case HELLO:
 			d.mu.Lock()
-			if len(d.GetWorkers()) < MaxWorkers {
+			hello, err := DeserializeHandshake(m.GetPayload())
+			if err != nil || authenticated {
+				s.Write(InvalidMessage())
+			} else if pub := PeerPub(s.Request); pub != "" && pub != hex.EncodeToString(hello.GetPub()) {
+				glg.Warn("Dispatcher: worker hello does not match its certificate")
+				s.Write(ErrorMessage(ErrCodeCertificate, "hello does not match certificate"))
+				s.Close()
+			} else if version, err := NegotiateVersion(hello.GetVersion()); err != nil {
+				s.Write(ErrorMessage(ErrCodeVersion, VersionReason(hello.GetVersion())))
+				s.Close()
+			} else if len(d.GetWorkers()) < d.GetConfig().Dispatcher.MaxWorkers {
+				challenge := NewChallenge()
+				d.GetPending()[s] = NewPendingAuth(hello.GetPub(), challenge, version, NegotiateCapabilities(hello.GetCapabilities()), nil)
+				s.Write(HelloMessage(NewHandshake(d.GetPubByte(), challenge, SignChallenge(d.GetPrivByte(), HelloDomain, hello.GetChallenge(), d.GetPubByte(), hello.GetPub())).Serialize()))
+			} else {
+				s.Write(ConnFullMessage())
+			}
+			d.mu.Unlock()
+			break
+		case AUTH:
+			d.mu.Lock()
+			pending, ok := d.GetPending()[s]
+			delete(d.GetPending(), s)
+			auth, err := DeserializeHandshake(m.GetPayload())
+			if ok && err == nil && pending.Verify(auth, d.GetPubByte()) {
 				glg.Info("Dispatcher: worker connected")
-				d.SetWorker(s, NewWorkerInfo(hex.EncodeToString(m.GetPayload())))
-				s.Write(HelloMessage(d.GetPubByte()))
-				d.centrum.ConnectWorker()
+				info := NewWorkerInfo(hex.EncodeToString(pending.GetPub()))
+				info.SetProtocol(pending.GetVersion(), pending.GetCapabilities())
+				d.SetWorker(s, info)
+				if err := d.discovery.ConnectWorker(); err != nil {
+					glg.Warn("Discovery: " + err.Error())
+				}
 				d.GetWorkerPQ().Push(s, 0)
 			} else {
-				s.Write(ConnFullMessage())
+				glg.Warn("Dispatcher: worker failed handshake")
+				s.Write(ErrorMessage(ErrCodeHandshake, "invalid challenge response"))
+				s.Close()
 			}
 			d.mu.Unlock()
 			break
 		case RESULT:
 			d.mu.Lock()
-			if m.VerifySignature(d.GetWorker(s).GetPub()) {
+			if d.GetWorker(s).GetJob() == nil {
+				//! job was requeued after the worker missed its deadline
+				glg.Warn("Dispatcher: dropping stale result from worker - " + d.GetWorker(s).GetPub())
+			} else if d.verify(m, d.GetWorker(s).GetPub()) {
 				glg.Info("P2P: received result")
 				exec := job.DeserializeExec(m.GetPayload())
-				d.GetWorker(s).GetJob().SetExec(&exec)
-				d.GetWorker(s).GetJob().ResultsChan() <- *d.GetWorker(s).GetJob()
-				j := d.GetWorker(s).GetJob().GetJob()
-				d.GetWorker(s).SetJob(nil)
-				j.AddExec(exec)
-				d.AddJob(j)
+				//! replication is decided by the queued exec, not the copy returned by the worker
+				if rs, ok := d.GetReplicas()[d.GetWorker(s).GetJob().GetExec()]; ok {
+					d.collectReplica(s, rs, exec)
+				} else {
+					d.GetJobPQ().Complete(d.GetWorker(s).GetJob().GetExec(), exec)
+					d.GetWorker(s).GetJob().SetExec(&exec)
+					d.GetWorker(s).GetJob().ResultsChan() <- *d.GetWorker(s).GetJob()
+					j := d.GetWorker(s).GetJob().GetJob()
+					foreign := d.isForeign(*d.GetWorker(s).GetJob())
+					d.GetWorker(s).SetJob(nil)
+					if !foreign {
+						j.AddExec(exec)
+						d.AddJob(j)
+					}
+				}
 			} else {
-				d.GetJobPQ().PushItem(*d.GetWorker(s).GetJob(), job.HIGH)
+				d.requeue(s)
 			}
+			d.GetWorker(s).SetUnhealthy(false)
 			if !d.GetWorker(s).GetShut() {
 				d.GetWorkerPQ().Push(s, 0)
 			}
 			d.mu.Unlock()
 			break
+		case PONG:
+			d.mu.Lock()
+			if d.verify(m, d.GetWorker(s).GetPub()) && d.GetWorker(s).GetUnhealthy() && d.GetWorker(s).GetJob() == nil && !d.GetWorker(s).GetShut() {
+				glg.Info("Dispatcher: worker recovered - " + d.GetWorker(s).GetPub())
+				d.GetWorker(s).SetUnhealthy(false)
+				d.GetWorkerPQ().Push(s, 0)
+			}
+			d.mu.Unlock()
+			break
 		case SHUT:
 			d.mu.Lock()
 			d.GetWorker(s).SetShut(true)
 			s.Write(ShutAckMessage(d.GetPrivByte()))
-			d.centrum.DisconnectWorker()
+			if err := d.discovery.DisconnectWorker(); err != nil {
+				glg.Warn("Discovery: " + err.Error())
+			}
 			d.mu.Unlock()
 			break
 		default:
-			s.Write(InvalidMessage())
+			s.Write(UnknownMessage(m.GetMessage()))
 			break
 		}
 	})
 }
 
-func (d Dispatcher) dPeerTalk() {
+func (d *Dispatcher) dPeerTalk() {
 	d.dWS.HandleDisconnect(func(s *melody.Session) {
 		d.mu.Lock()
+		delete(d.GetPending(), s)
 		info := d.GetNeighbour(s)
 		if info != nil {
 			glg.Info("Dispatcher: neighbour disconnected")
 			d.BroadcastNeighbours(NeighbourDisconnectMessage(info.GetPub(), d.GetPrivByte()))
 			delete(d.GetNeighbours(), s)
+			d.GetSyncer().RemovePeer(s)
+			d.requeueForwarded(hex.EncodeToString(info.GetPub()))
 		}
 		d.mu.Unlock()
 	})
 	d.dWS.HandleMessageBinary(func(s *melody.Session, message []byte) {
-		m := DeserializePeerMessage(message)
+		m, err := DeserializePeerMessage(message)
+		if err != nil {
+			s.Write(InvalidMessage())
+			return
+		}
+		if !d.allowMessage(s, m) {
+			return
+		}
+		if !m.Compatible() {
+			s.Write(ErrorMessage(ErrCodeVersion, VersionReason(m.GetVersion())))
+			s.Close()
+			return
+		}
+		if m.GetMessage() == ERROR {
+			logPeerError("Dispatcher: neighbour error", m)
+			return
+		}
+		d.mu.Lock()
+		authenticated := d.GetNeighbour(s) != nil
+		speaks := !authenticated || d.GetNeighbour(s).Speaks(m.GetMessage())
+		d.mu.Unlock()
+		if !authenticated && m.GetMessage() != HELLO && m.GetMessage() != AUTH {
+			s.Write(InvalidMessage())
+			return
+		}
+		if !speaks {
+			//! newer than the version negotiated with the neighbour
+			s.Write(UnknownMessage(m.GetMessage()))
+			return
+		}
 		switch m.GetMessage() {
 		case HELLO:
 			d.mu.Lock()
-			info := DeserializeDispatcherHello(m.GetPayload())
-			d.NewNeighbour(s, &DispatcherInfo{pub: info.GetPub(), neighbours: info.GetNeighbours()})
-			s.Write(HelloMessage(NewDispatcherHello(d.GetPubByte(), d.GetNeighboursPubs()).Serialize()))
+			info, err := DeserializeDispatcherHello(m.GetPayload())
+			if err != nil || authenticated {
+				s.Write(InvalidMessage())
+			} else if pub := PeerPub(s.Request); pub != "" && pub != hex.EncodeToString(info.GetPub()) {
+				glg.Warn("Dispatcher: neighbour hello does not match its certificate")
+				s.Write(ErrorMessage(ErrCodeCertificate, "hello does not match certificate"))
+				s.Close()
+			} else if version, err := NegotiateVersion(info.GetVersion()); err != nil {
+				s.Write(ErrorMessage(ErrCodeVersion, VersionReason(info.GetVersion())))
+				s.Close()
+			} else {
+				challenge := NewChallenge()
+				d.GetPending()[s] = NewPendingAuth(info.GetPub(), challenge, version, NegotiateCapabilities(info.GetCapabilities()), &info)
+				s.Write(HelloMessage(NewDispatcherHello(d.GetPubByte(), d.GetAddr(), d.GetNeighboursPubs(), challenge, SignChallenge(d.GetPrivByte(), HelloDomain, info.GetChallenge(), d.GetPubByte(), info.GetPub())).Serialize()))
+			}
+			d.mu.Unlock()
+			break
+		case AUTH:
+			d.mu.Lock()
+			pending, ok := d.GetPending()[s]
+			delete(d.GetPending(), s)
+			auth, err := DeserializeHandshake(m.GetPayload())
+			if !ok || err != nil || !pending.Verify(auth, d.GetPubByte()) {
+				glg.Warn("Dispatcher: neighbour failed handshake")
+				s.Write(ErrorMessage(ErrCodeHandshake, "invalid challenge response"))
+				s.Close()
+				d.mu.Unlock()
+				break
+			}
+			info := pending.GetHello()
+			neighbour := &DispatcherInfo{pub: info.GetPub(), neighbours: info.GetNeighbours()}
+			neighbour.SetProtocol(pending.GetVersion(), pending.GetCapabilities())
+			d.NewNeighbour(s, neighbour)
 			d.mu.Unlock()
+			if neighbour.Supports(CapHeaderSync) {
+				d.GetSyncer().AddPeer(s)
+			}
+			if info.GetAddr() != "" {
+				d.GetAddrBook().Add(info.GetAddr())
+			}
 			break
 		case BLOCK:
 			d.mu.Lock()
-			if m.VerifySignature(hex.EncodeToString(d.GetNeighbour(s).GetPub())) {
+			if d.verify(m, hex.EncodeToString(d.GetNeighbour(s).GetPub())) {
 				b, err := core.DeserializeBlock(m.GetPayload())
 				if err != nil {
 					glg.Fatal(err)
 				}
+				if !d.GetBC().HasBlock(b.GetHeader().GetPrevBlockHash()) {
+					//! behind the sender, catch up through the syncer
+					if d.GetNeighbour(s).Supports(CapHeaderSync) {
+						d.GetSyncer().RequestHeaders(s)
+					}
+					d.mu.Unlock()
+					break
+				}
 				err = b.Export()
 				if err != nil {
 					glg.Fatal(err)
@@ -423,24 +826,49 @@ func (d Dispatcher) dPeerTalk() {
 			}
 			d.mu.Unlock()
 			break
-		case BLOCKREQ:
+		case CAPACITY, FORWARD, FORWARDRES, HANDOFF, HANDOFFACK:
 			d.mu.Lock()
-			if m.VerifySignature(hex.EncodeToString(d.GetNeighbour(s).GetPub())) {
-				blockinfo, _ := d.GetBC().GetBlockInfo(m.GetPayload())
-				s.Write(BlockResMessage(blockinfo.GetBlock().Serialize(), d.GetPrivByte()))
+			if d.verify(m, hex.EncodeToString(d.GetNeighbour(s).GetPub())) {
+				d.handleForward(d.GetNeighbour(s), m)
 			}
 			d.mu.Unlock()
 			break
+		case HEADERSREQ, HEADERS, BLOCKREQ, BLOCKRES:
+			d.mu.Lock()
+			valid := d.verify(m, hex.EncodeToString(d.GetNeighbour(s).GetPub()))
+			headerSync := d.GetNeighbour(s).Supports(CapHeaderSync)
+			d.mu.Unlock()
+			if valid && headerSync {
+				d.GetSyncer().Handle(s, m)
+			} else if valid {
+				d.GetSyncer().HandleLegacy(s, m)
+			}
+			break
+		case PEERREQ:
+			d.mu.Lock()
+			if d.verify(m, hex.EncodeToString(d.GetNeighbour(s).GetPub())) {
+				s.Write(PeersMessage(d.peersPayload(), d.GetPrivByte()))
+			}
+			d.mu.Unlock()
+			break
+		case PEERS:
+			d.mu.Lock()
+			valid := d.verify(m, hex.EncodeToString(d.GetNeighbour(s).GetPub()))
+			d.mu.Unlock()
+			if valid {
+				d.addPeers(m.GetPayload())
+			}
+			break
 		case NEIGHBOURCONNECT:
 			d.mu.Lock()
-			if m.VerifySignature(hex.EncodeToString(d.GetNeighbour(s).GetPub())) {
+			if d.verify(m, hex.EncodeToString(d.GetNeighbour(s).GetPub())) {
 				d.GetNeighbour(s).AddNeighbour(hex.EncodeToString(m.GetPayload()))
 			}
 			d.mu.Unlock()
 			break
 		case NEIGHBOURDISCONNECT:
 			d.mu.Lock()
-			if m.VerifySignature(hex.EncodeToString(d.GetNeighbour(s).GetPub())) {
+			if d.verify(m, hex.EncodeToString(d.GetNeighbour(s).GetPub())) {
 				neighbours := d.GetNeighbour(s).GetNeighbours()
 				for i, neighbour := range neighbours {
 					if neighbour == hex.EncodeToString(m.GetPayload()) {
@@ -452,33 +880,71 @@ func (d Dispatcher) dPeerTalk() {
 			d.mu.Unlock()
 			break
 		default:
-			s.Write(InvalidMessage())
+			s.Write(UnknownMessage(m.GetMessage()))
 			break
 		}
 	})
 }
 
-func (d Dispatcher) HandleNodeConnect(conn *websocket.Conn) {
-	conn.WriteMessage(websocket.BinaryMessage, HelloMessage(NewDispatcherHello(d.GetPubByte(), d.GetNeighboursPubs()).Serialize()))
+//HandleNodeConnect runs the handshake with and handles messages from a dispatcher dialed at addr
+func (d *Dispatcher) HandleNodeConnect(conn *websocket.Conn, addr string) {
+	challenge := NewChallenge()
+	writePeer(conn, HelloMessage(NewDispatcherHello(d.GetPubByte(), d.GetAddr(), d.GetNeighboursPubs(), challenge, nil).Serialize()))
 	for {
 		_, message, err := conn.ReadMessage()
 		if err != nil {
 			//TODO: handle syncer disconnect - use next best version
 			d.mu.Lock()
 			glg.Info("Dispatcher: neighbour disconnected")
-			info := d.GetNeighbour(conn)
-			d.BroadcastNeighbours(NeighbourDisconnectMessage(info.GetPub(), d.GetPrivByte()))
-			delete(d.GetNeighbours(), conn)
+			if info := d.GetNeighbour(conn); info != nil {
+				d.BroadcastNeighbours(NeighbourDisconnectMessage(info.GetPub(), d.GetPrivByte()))
+				delete(d.GetNeighbours(), conn)
+				d.GetSyncer().RemovePeer(conn)
+				d.requeueForwarded(hex.EncodeToString(info.GetPub()))
+			}
 			d.mu.Unlock()
+			releasePeer(conn)
+			return
+		}
+		m, err := DeserializePeerMessage(message)
+		if err != nil {
+			writePeer(conn, InvalidMessage())
+			continue
+		}
+		if !m.Compatible() {
+			writePeer(conn, ErrorMessage(ErrCodeVersion, VersionReason(m.GetVersion())))
+			conn.Close()
+			continue
+		}
+		d.mu.Lock()
+		speaks := d.GetNeighbour(conn) == nil || d.GetNeighbour(conn).Speaks(m.GetMessage())
+		d.mu.Unlock()
+		if !speaks {
+			//! newer than the version negotiated with the neighbour
+			writePeer(conn, UnknownMessage(m.GetMessage()))
+			continue
 		}
-		m := DeserializePeerMessage(message)
 		switch m.GetMessage() {
+		case ERROR:
+			logPeerError("Dispatcher: neighbour error", m)
+			break
 		case HELLO:
 			d.mu.Lock()
-			peerInfo := DeserializeDispatcherHello(m.GetPayload())
-			if bytes.Compare(d.GetNeighbour(conn).GetPub(), peerInfo.GetPub()) == 0 {
+			peerInfo, err := DeserializeDispatcherHello(m.GetPayload())
+			version, verr := NegotiateVersion(peerInfo.GetVersion())
+			if err == nil && verr == nil && d.GetNeighbour(conn) != nil && bytes.Compare(d.GetNeighbour(conn).GetPub(), peerInfo.GetPub()) == 0 && VerifyChallenge(peerInfo.GetPub(), HelloDomain, challenge, d.GetPubByte(), peerInfo.GetResponse()) {
+				writePeer(conn, AuthMessage(NewHandshake(d.GetPubByte(), nil, SignChallenge(d.GetPrivByte(), AuthDomain, peerInfo.GetChallenge(), d.GetPubByte(), peerInfo.GetPub())).Serialize()))
+				d.GetNeighbour(conn).SetProtocol(version, NegotiateCapabilities(peerInfo.GetCapabilities()))
 				d.GetNeighbour(conn).SetNeighbours(peerInfo.GetNeighbours())
+				if d.GetNeighbour(conn).Supports(CapHeaderSync) {
+					d.GetSyncer().AddPeer(conn)
+				}
+				d.GetAddrBook().Seen(addr)
+				if peerInfo.GetAddr() != "" {
+					d.GetAddrBook().Add(peerInfo.GetAddr())
+				}
 			} else {
+				d.GetAddrBook().Failed(addr)
 				delete(d.GetNeighbours(), conn)
 				conn.Close()
 			}
@@ -486,11 +952,19 @@ func (d Dispatcher) HandleNodeConnect(conn *websocket.Conn) {
 			break
 		case BLOCK:
 			d.mu.Lock()
-			if m.VerifySignature(hex.EncodeToString(d.GetNeighbour(conn).GetPub())) {
+			if d.verify(m, hex.EncodeToString(d.GetNeighbour(conn).GetPub())) {
 				b, err := core.DeserializeBlock(m.GetPayload())
 				if err != nil {
 					glg.Fatal(err)
 				}
+				if !d.GetBC().HasBlock(b.GetHeader().GetPrevBlockHash()) {
+					//! behind the sender, catch up through the syncer
+					if d.GetNeighbour(conn).Supports(CapHeaderSync) {
+						d.GetSyncer().RequestHeaders(conn)
+					}
+					d.mu.Unlock()
+					break
+				}
 				err = b.Export()
 				if err != nil {
 					glg.Fatal(err)
@@ -507,59 +981,78 @@ func (d Dispatcher) HandleNodeConnect(conn *websocket.Conn) {
 			}
 			d.mu.Unlock()
 			break
-		case BLOCKRES:
-			if m.VerifySignature(hex.EncodeToString(d.GetNeighbour(conn).GetPub())) {
-				b, err := core.DeserializeBlock(m.GetPayload())
-				if err != nil {
-					glg.Fatal(err)
-				}
-				err = b.Export()
-				if err != nil {
-					glg.Fatal(err)
-				}
-				d.GetBC().AddBlock(b)
+		case CAPACITY, FORWARD, FORWARDRES, HANDOFF, HANDOFFACK:
+			d.mu.Lock()
+			if d.verify(m, hex.EncodeToString(d.GetNeighbour(conn).GetPub())) {
+				d.handleForward(d.GetNeighbour(conn), m)
+			}
+			d.mu.Unlock()
+			break
+		case HEADERSREQ, HEADERS, BLOCKREQ, BLOCKRES:
+			d.mu.Lock()
+			valid := d.verify(m, hex.EncodeToString(d.GetNeighbour(conn).GetPub()))
+			headerSync := d.GetNeighbour(conn).Supports(CapHeaderSync)
+			d.mu.Unlock()
+			if valid && headerSync {
+				d.GetSyncer().Handle(conn, m)
+			} else if valid {
+				d.GetSyncer().HandleLegacy(conn, m)
+			}
+			break
+		case PEERREQ:
+			d.mu.Lock()
+			if d.verify(m, hex.EncodeToString(d.GetNeighbour(conn).GetPub())) {
+				writePeer(conn, PeersMessage(d.peersPayload(), d.GetPrivByte()))
+			}
+			d.mu.Unlock()
+			break
+		case PEERS:
+			d.mu.Lock()
+			valid := d.verify(m, hex.EncodeToString(d.GetNeighbour(conn).GetPub()))
+			d.mu.Unlock()
+			if valid {
+				d.addPeers(m.GetPayload())
 			}
 			break
 		case NEIGHBOURCONNECT:
 			d.mu.Lock()
-			if m.VerifySignature(hex.EncodeToString(d.GetNeighbour(conn).GetPub())) {
+			if d.verify(m, hex.EncodeToString(d.GetNeighbour(conn).GetPub())) {
 				d.GetNeighbour(conn).AddNeighbour(hex.EncodeToString(m.GetPayload()))
 			}
 			d.mu.Unlock()
 			break
 		case NEIGHBOURDISCONNECT:
 			d.mu.Lock()
-			if m.VerifySignature(hex.EncodeToString(d.GetNeighbour(conn).GetPub())) {
+			if d.verify(m, hex.EncodeToString(d.GetNeighbour(conn).GetPub())) {
 				neighbours := d.GetNeighbour(conn).GetNeighbours()
 				for i, neighbour := range neighbours {
 					if neighbour == hex.EncodeToString(m.GetPayload()) {
 						d.GetNeighbour(conn).SetNeighbours(append(neighbours[:i], neighbours[i+1:]...))
+						break
 					}
 				}
 			}
 			d.mu.Unlock()
 			break
 		default:
-			conn.WriteMessage(websocket.BinaryMessage, InvalidMessage())
+			writePeer(conn, UnknownMessage(m.GetMessage()))
 			break
 		}
 	}
 }
 
-func (d Dispatcher) WatchInterrupt() {
+func (d *Dispatcher) WatchInterrupt() {
 	select {
 	case i := <-d.interrupt:
 		glg.Warn("Dispatcher: interrupt detected")
 		switch i {
 		case syscall.SIGINT, syscall.SIGTERM:
-			res, err := d.centrum.Sleep()
-			if err != nil {
-				glg.Fatal(err)
-			} else if res["status"].(string) != "success" {
-				glg.Fatal("Centrum: " + res["status"].(string))
+			d.Drain()
+			if err := d.nat.Clear(d.GetPort()); err != nil {
+				glg.Warn("NAT: unable to clear port - " + err.Error())
 			}
-			d.BroadcastWorkers(ShutMessage(d.GetPrivByte()))
 			time.Sleep(time.Second * 3) // give neighbors and workers 3 seconds to disconnect
+			d.db.Close()
 			os.Exit(0)
 		case syscall.SIGQUIT:
 			os.Exit(1)
@@ -567,35 +1060,47 @@ func (d Dispatcher) WatchInterrupt() {
 	}
 }
 
-func (d Dispatcher) Start() {
+func (d *Dispatcher) Start() {
 	if !d.GetBC().Verify() {
 		glg.Fatal("Dispatcher: blockchain not verified")
 	}
 	go d.deployJobs()
+	go d.watchWorkers()
 	go d.watchWriteQ()
 	go d.WatchInterrupt()
+	go d.GetSyncer().watch()
+	go d.forwardJobs()
 	d.GetDispatchersAndSync()
-	d.wWS.Upgrader.ReadBufferSize = 100000
-	d.wWS.Upgrader.WriteBufferSize = 100000
-	d.wWS.Config.MessageBufferSize = 100000
-	d.wWS.Config.MaxMessageSize = 100000
+	go d.gossip()
+	d.wWS.Upgrader.ReadBufferSize = d.GetConfig().Dispatcher.ReadBufferSize
+	d.wWS.Upgrader.WriteBufferSize = d.GetConfig().Dispatcher.WriteBufferSize
+	d.wWS.Config.MessageBufferSize = d.GetConfig().Dispatcher.MessageBufferSize
+	d.wWS.Config.MaxMessageSize = d.GetConfig().Dispatcher.MaxMessageSize
 	d.wWS.Upgrader.EnableCompression = true
-	d.dWS.Upgrader.ReadBufferSize = 100000
-	d.dWS.Upgrader.WriteBufferSize = 100000
-	d.dWS.Config.MessageBufferSize = 100000
-	d.dWS.Config.MaxMessageSize = 100000
+	d.dWS.Upgrader.ReadBufferSize = d.GetConfig().Dispatcher.ReadBufferSize
+	d.dWS.Upgrader.WriteBufferSize = d.GetConfig().Dispatcher.WriteBufferSize
+	d.dWS.Config.MessageBufferSize = d.GetConfig().Dispatcher.MessageBufferSize
+	d.dWS.Config.MaxMessageSize = d.GetConfig().Dispatcher.MaxMessageSize
 	d.dWS.Upgrader.EnableCompression = true
-	d.router.HandleFunc("/d", func(w http.ResponseWriter, r *http.Request) {
+	d.router.Handle("/d", rateLimit(d.connLimit, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !d.GetTransport().Authorized(r) {
+			http.Error(w, ErrNoPeerCert.Error(), http.StatusUnauthorized)
+			return
+		}
 		d.dWS.HandleRequest(w, r)
-	})
-	d.router.HandleFunc("/w", func(w http.ResponseWriter, r *http.Request) {
+	})))
+	d.router.Handle("/w", rateLimit(d.connLimit, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !d.GetTransport().Authorized(r) {
+			http.Error(w, ErrNoPeerCert.Error(), http.StatusUnauthorized)
+			return
+		}
 		d.wWS.HandleRequest(w, r)
-	})
+	})))
 	d.wPeerTalk()
 	d.dPeerTalk()
 	d.rpc.RegisterCodec(json2.NewCodec(), "application/json")
 	d.rpc.RegisterCodec(json2.NewCodec(), "application/json;charset=UTF-8")
-	d.router.Handle("/rpc", d.rpc).Methods("POST")
+	d.router.Handle("/rpc", rateLimit(d.rpcLimit, d.rpc)).Methods("POST")
 	status := make(map[string]string)
 	status["status"] = "running"
 	status["pub"] = d.GetPubString()
@@ -607,52 +1112,41 @@ func (d Dispatcher) Start() {
 		w.Write(statusBytes)
 	})
 	d.router.HandleFunc("/version", func(w http.ResponseWriter, r *http.Request) {
-		w.Write(NewVersion(GizoVersion, int(d.GetBC().GetLatestHeight()), d.GetBC().GetBlockHashesHex()).Serialize())
+		w.Write(NewVersion(GizoVersion, int(d.GetBC().GetLatestHeight()), hex.EncodeToString(d.GetBC().GetLatestBlock().GetHeader().GetHash())).Serialize())
 	})
+	d.registerAdmin()
+	d.registerWorkflows()
+	d.registerMetrics()
 
-	err = d.discover.Forward(uint16(d.GetPort()), "gizo dispatcher node")
-	if err != nil {
-		log.Fatal(err)
+	if err = d.nat.Forward(d.GetPort()); err != nil {
+		glg.Warn("NAT: unable to forward port - " + err.Error())
 	}
 	if d.new {
 		go d.Register()
 	} else {
-		res, err := d.centrum.Wake()
-		if err != nil {
-			glg.Fatal(err)
-		} else if res["status"].(string) != "success" {
-			glg.Fatal("Centrum: " + res["status"].(string))
+		if err := d.discovery.Wake(); err != nil {
+			glg.Warn("Discovery: unable to wake node, registering again - " + err.Error())
+			go d.Register()
 		}
 	}
 
-	//! TLS config
-	// certManager := autocert.Manager{
-	// 	Prompt: autocert.AcceptTOS,
-	// 	Cache:  autocert.DirCache("ssl"),
-	// }
-
-	// server := &http.Server{
-	// 	Handler: d.router,
-	// 	Addr:    ":8443",
-	// 	TLSConfig: &tls.Config{
-	// 		GetCertificate: certManager.GetCertificate,
-	// 	},
-	// }
-
-	// err = d.discover.Forward(8443, "gizo dispatcher node")
-	// if err != nil {
-	// 	log.Fatal(err)
-	// }
-
-	// go http.ListenAndServe(":"+strconv.FormatInt(int64(d.GetPort()), 10), d.router)
-	// fmt.Println(server.ListenAndServeTLS("", ""))
-	fmt.Println(http.ListenAndServe(":"+strconv.FormatInt(int64(d.GetPort()), 10), d.router))
+	server := &http.Server{
+		Handler: d.router,
+		Addr:    ":" + strconv.FormatInt(int64(d.GetPort()), 10),
+	}
+	if d.GetTransport().GetEnabled() {
+		glg.Info("Dispatcher: serving over tls")
+		server.TLSConfig = d.GetTransport().ServerConfig()
+		fmt.Println(server.ListenAndServeTLS("", ""))
+	} else {
+		fmt.Println(server.ListenAndServe())
+	}
 }
 
 func (d Dispatcher) SaveToken() {
 	err := d.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(NodeBucket))
-		if err := b.Put([]byte("token"), []byte(d.centrum.GetToken())); err != nil {
+		if err := b.Put([]byte("token"), []byte(d.discovery.GetToken())); err != nil {
 			glg.Fatal(err)
 		}
 		return nil
@@ -662,73 +1156,46 @@ func (d Dispatcher) SaveToken() {
 	}
 }
 
+//Register announces the dispatcher through discovery, retrying with backoff while it's unreachable
+//since neighbours are still dialed from the address book
 func (d Dispatcher) Register() {
-	time.Sleep(time.Second * 1)
-	if err := d.centrum.NewDisptcher(d.GetPubString(), d.GetIP(), int(d.GetPort())); err != nil {
-		glg.Warn("Centrum: unable to get on network")
-		glg.Fatal("Centrum: " + err.Error())
+	backoff := time.Second
+	for {
+		time.Sleep(backoff)
+		err := d.discovery.Register(d.GetPrivByte(), d.GetPubString(), d.GetIP(), d.GetPublicPort())
+		if err == nil {
+			break
+		}
+		backoff *= 2
+		if backoff > MaxRegisterBackoff {
+			backoff = MaxRegisterBackoff
+		}
+		glg.Warn("Discovery: unable to get on network, retrying in " + backoff.String() + " - " + err.Error())
 	}
 	d.SaveToken()
 }
 
+//GetDispatchersAndSync dials known dispatchers, the chain is synced from each once its handshake completes
 func (d *Dispatcher) GetDispatchersAndSync() {
 	time.Sleep(time.Second * 2)
-	res := d.centrum.GetDispatchers()
-	syncVersion := new(Version)
-	syncPeer := new(websocket.Conn)
-	dispatchers, ok := res["dispatchers"]
-	if !ok {
-		glg.Warn(ErrNoDispatchers)
-		return
+	dispatchers, err := d.discovery.GetDispatchers()
+	if err != nil {
+		glg.Warn(err)
+		glg.Warn("Dispatcher: falling back to address book")
+		dispatchers = d.GetAddrBook().GetAddrs()
 	}
-	for _, dispatcher := range dispatchers.([]string) {
+	for _, dispatcher := range dispatchers {
 		addr, err := ParseAddr(dispatcher)
 		if err == nil && addr["pub"].(string) != d.GetPubString() {
-			var v Version
-			wsURL := fmt.Sprintf("ws://%v:%v/d", addr["ip"], addr["port"])
-			versionURL := fmt.Sprintf("http://%v:%v/version", addr["ip"], addr["port"])
-			dailer := websocket.Dialer{
-				Proxy:           http.ProxyFromEnvironment,
-				ReadBufferSize:  10000,
-				WriteBufferSize: 10000,
-			}
-			conn, _, err := dailer.Dial(wsURL, nil)
-			if err != nil {
-				glg.Fatal(err)
-			}
-			conn.EnableWriteCompression(true)
-			pubBytes, err := hex.DecodeString(addr["pub"].(string))
-			if err != nil {
-				glg.Fatal(err)
-			}
-			d.NewNeighbour(conn, NewDispatcherInfo(pubBytes))
-			go d.HandleNodeConnect(conn)
-			_, err = s.New().Get(versionURL).ReceiveSuccess(&v)
-			if err != nil {
-				glg.Fatal(err)
-			}
-			if syncVersion.GetHeight() < v.GetHeight() {
-				syncVersion = &v
-				syncPeer = conn
-			}
-		}
-	}
-	if syncVersion.GetHeight() != 0 {
-		glg.Warn("Dispatcher: node sync in progress")
-		blocks := d.GetBC().GetBlockHashesHex()
-		for _, hash := range syncVersion.GetBlocks() {
-			if !funk.ContainsString(blocks, hash) {
-				hashBytes, err := hex.DecodeString(hash)
-				if err != nil {
-					glg.Fatal(err)
-				}
-				syncPeer.WriteMessage(websocket.BinaryMessage, BlockReqMessage(hashBytes, d.GetPrivByte()))
+			d.GetAddrBook().Add(dispatcher)
+			if _, err := d.Dial(dispatcher); err != nil {
+				glg.Warn("Dispatcher: unable to dial peer - " + err.Error())
 			}
 		}
 	}
 }
 
-func NewDispatcher(port int) *Dispatcher {
+func NewDispatcher(cfg *config.Config) *Dispatcher {
 	glg.Info("Creating Dispatcher Node")
 	core.InitializeDataPath()
 	interrupt := make(chan os.Signal, 1)
@@ -736,24 +1203,15 @@ func NewDispatcher(port int) *Dispatcher {
 	var bench benchmark.Engine
 	var priv, pub []byte
 	var token string
-	discover, err := upnp.Discover()
-	if err != nil {
-		glg.Fatal(err)
-	}
+	nat := DiscoverNAT(cfg.Dispatcher.NAT)
+	ip := ResolveIP(nat, cfg.Dispatcher.NAT, cfg.Dispatcher.IP)
 
-	ip, err := discover.ExternalIP()
+	discovery, err := NewDiscovery(cfg)
 	if err != nil {
-		log.Fatal(err)
+		glg.Fatal(err)
 	}
 
-	centrum := NewCentrum()
-
-	var dbFile string
-	if os.Getenv("ENV") == "dev" {
-		dbFile = path.Join(core.IndexPathDev, NodeDB)
-	} else {
-		dbFile = path.Join(core.IndexPathProd, NodeDB)
-	}
+	dbFile := path.Join(core.IndexPath(), NodeDB)
 
 	if helpers.FileExists(dbFile) {
 		glg.Warn("Dispatcher: using existing keypair and benchmark")
@@ -772,33 +1230,54 @@ func NewDispatcher(port int) *Dispatcher {
 		if err != nil {
 			glg.Fatal(err)
 		}
-		centrum.SetToken(token)
+		discovery.SetToken(token)
+		transport, err := NewTransport(cfg.TLS, priv, pub)
+		if err != nil {
+			glg.Fatal(err)
+		}
 		bc := core.CreateBlockChain(hex.EncodeToString(pub))
 		jc := cache.NewJobCache(bc)
 		return &Dispatcher{
-			IP:        ip,
-			Pub:       pub,
-			priv:      priv,
-			Port:      uint(port),
-			uptime:    time.Now().Unix(),
-			bench:     bench,
-			jobPQ:     queue.NewJobPriorityQueue(),
-			workers:   make(map[*melody.Session]*WorkerInfo),
-			workerPQ:  NewWorkerPriorityQueue(),
-			neighbors: make(map[interface{}]*DispatcherInfo),
-			jc:        jc,
-			bc:        bc,
-			db:        db,
-			router:    mux.NewRouter(),
-			wWS:       melody.New(),
-			dWS:       melody.New(),
-			rpc:       rpc.NewServer(),
-			mu:        new(sync.Mutex),
-			interrupt: interrupt,
-			writeQ:    lane.NewQueue(),
-			centrum:   centrum,
-			discover:  discover,
-			new:       false,
+			IP:         ip,
+			Pub:        pub,
+			priv:       priv,
+			Port:       uint(cfg.Dispatcher.Port),
+			uptime:     time.Now().Unix(),
+			bench:      bench,
+			jobPQ:      queue.NewPersistentJobPriorityQueue(db, bc, jc),
+			workflows:  workflow.NewStore(db),
+			workers:    make(map[*melody.Session]*WorkerInfo),
+			replicas:   make(map[*job.Exec]*ReplicaSet),
+			parked:     make(map[*job.Exec]qItem.Item),
+			workerPQ:   NewWorkerPriorityQueue(),
+			neighbors:  make(map[interface{}]*DispatcherInfo),
+			jc:         jc,
+			bc:         bc,
+			db:         db,
+			router:     mux.NewRouter(),
+			wWS:        melody.New(),
+			dWS:        melody.New(),
+			rpc:        rpc.NewServer(),
+			mu:         new(sync.Mutex),
+			interrupt:  interrupt,
+			writeQ:     lane.NewQueue(),
+			discovery:  discovery,
+			nat:        nat,
+			new:        false,
+			addrBook:   NewAddressBook(db),
+			syncer:     NewSyncer(bc, priv),
+			forwarded:  make(map[string]*forwarded),
+			foreign:    make(map[chan<- qItem.Item]string),
+			draining:   make(chan struct{}),
+			handoffs:   make(chan handoffAck, 1),
+			transport:  transport,
+			pending:    make(map[*melody.Session]*PendingAuth),
+			replay:     NewReplayGuard(),
+			connLimit:  NewRateLimiter(cfg.Dispatcher.RateLimit.Connections.Rate, cfg.Dispatcher.RateLimit.Connections.Burst),
+			msgLimit:   NewRateLimiter(cfg.Dispatcher.RateLimit.Messages.Rate, cfg.Dispatcher.RateLimit.Messages.Burst),
+			rpcLimit:   NewRateLimiter(cfg.Dispatcher.RateLimit.RPC.Rate, cfg.Dispatcher.RateLimit.RPC.Burst),
+			adminToken: loadAdminToken(db, cfg.Dispatcher.AdminToken),
+			cfg:        cfg,
 		}
 	}
 
@@ -829,34 +1308,55 @@ func NewDispatcher(port int) *Dispatcher {
 		return nil
 	})
 
+	if err != nil {
+		glg.Fatal(err)
+	}
+	transport, err := NewTransport(cfg.TLS, priv, pub)
 	if err != nil {
 		glg.Fatal(err)
 	}
 	bc := core.CreateBlockChain(hex.EncodeToString(pub))
 	jc := cache.NewJobCache(bc)
 	return &Dispatcher{
-		IP:        ip,
-		Pub:       pub,
-		priv:      priv,
-		Port:      uint(port),
-		uptime:    time.Now().Unix(),
-		bench:     bench,
-		jobPQ:     queue.NewJobPriorityQueue(),
-		workers:   make(map[*melody.Session]*WorkerInfo),
-		workerPQ:  NewWorkerPriorityQueue(),
-		neighbors: make(map[interface{}]*DispatcherInfo),
-		jc:        jc,
-		bc:        bc,
-		db:        db,
-		router:    mux.NewRouter(),
-		wWS:       melody.New(),
-		dWS:       melody.New(),
-		rpc:       rpc.NewServer(),
-		mu:        new(sync.Mutex),
-		interrupt: interrupt,
-		writeQ:    lane.NewQueue(),
-		centrum:   centrum,
-		discover:  discover,
-		new:       true,
+		IP:         ip,
+		Pub:        pub,
+		priv:       priv,
+		Port:       uint(cfg.Dispatcher.Port),
+		uptime:     time.Now().Unix(),
+		bench:      bench,
+		jobPQ:      queue.NewPersistentJobPriorityQueue(db, bc, jc),
+		workflows:  workflow.NewStore(db),
+		workers:    make(map[*melody.Session]*WorkerInfo),
+		replicas:   make(map[*job.Exec]*ReplicaSet),
+		parked:     make(map[*job.Exec]qItem.Item),
+		workerPQ:   NewWorkerPriorityQueue(),
+		neighbors:  make(map[interface{}]*DispatcherInfo),
+		jc:         jc,
+		bc:         bc,
+		db:         db,
+		router:     mux.NewRouter(),
+		wWS:        melody.New(),
+		dWS:        melody.New(),
+		rpc:        rpc.NewServer(),
+		mu:         new(sync.Mutex),
+		interrupt:  interrupt,
+		writeQ:     lane.NewQueue(),
+		discovery:  discovery,
+		nat:        nat,
+		new:        true,
+		addrBook:   NewAddressBook(db),
+		syncer:     NewSyncer(bc, priv),
+		forwarded:  make(map[string]*forwarded),
+		foreign:    make(map[chan<- qItem.Item]string),
+		draining:   make(chan struct{}),
+		handoffs:   make(chan handoffAck, 1),
+		transport:  transport,
+		pending:    make(map[*melody.Session]*PendingAuth),
+		replay:     NewReplayGuard(),
+		connLimit:  NewRateLimiter(cfg.Dispatcher.RateLimit.Connections.Rate, cfg.Dispatcher.RateLimit.Connections.Burst),
+		msgLimit:   NewRateLimiter(cfg.Dispatcher.RateLimit.Messages.Rate, cfg.Dispatcher.RateLimit.Messages.Burst),
+		rpcLimit:   NewRateLimiter(cfg.Dispatcher.RateLimit.RPC.Rate, cfg.Dispatcher.RateLimit.RPC.Burst),
+		adminToken: loadAdminToken(db, cfg.Dispatcher.AdminToken),
+		cfg:        cfg,
 	}
 }
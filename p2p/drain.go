@@ -0,0 +1,218 @@
+package p2p
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/gizo-network/gizo/job"
+	"github.com/gizo-network/gizo/job/queue/qItem"
+	"github.com/kpango/glg"
+	uuid "github.com/satori/go.uuid"
+)
+
+//ids of the handed off execs a neighbour queued
+type handoffAck struct {
+	peer string
+	ids  []string
+}
+
+//GetDraining returns true once the dispatcher started draining
+func (d Dispatcher) GetDraining() bool {
+	select {
+	case <-d.draining:
+		return true
+	default:
+		return false
+	}
+}
+
+//Drain stops the dispatcher from taking new execs, waits for running execs, writes pending jobs into a final block
+//and hands queued execs off to a neighbour, they stay in the queue journal for the next start unless the neighbour acknowledges them
+func (d *Dispatcher) Drain() {
+	glg.Warn("Dispatcher: draining")
+	d.mu.Lock()
+	if d.GetDraining() {
+		d.mu.Unlock()
+		return
+	}
+	close(d.draining)
+	d.mu.Unlock()
+	if err := d.discovery.Sleep(); err != nil {
+		glg.Warn("Discovery: " + err.Error())
+	}
+
+	deadline := time.Now().Add(d.GetConfig().Dispatcher.DrainTimeout)
+	for time.Now().Before(deadline) {
+		d.mu.Lock()
+		running := d.running()
+		d.mu.Unlock()
+		if running == 0 {
+			break
+		}
+		glg.Info("Dispatcher: waiting for " + strconv.Itoa(running) + " running execs")
+		time.Sleep(DrainPoll)
+	}
+
+	d.mu.Lock()
+	for s, info := range d.GetWorkers() {
+		if info.GetJob() != nil {
+			d.requeue(s)
+			info.SetJob(nil)
+		}
+	}
+	for id, f := range d.GetForwarded() {
+		delete(d.GetForwarded(), id)
+		d.GetJobPQ().PushItem(f.item, job.HIGH)
+	}
+	for exec, item := range d.GetParked() {
+		delete(d.GetParked(), exec)
+		d.GetJobPQ().PushItem(item, item.GetExec().GetPriority())
+	}
+	var items []qItem.Item
+	for d.GetJobPQ().Empty() == false {
+		item := d.GetJobPQ().Pop()
+		//! execs forwarded by neighbours are requeued by their origin once disconnected
+		if item.GetExec().GetStatus() != job.CANCELLED && !d.isForeign(item) {
+			items = append(items, item)
+		}
+	}
+	var peer string
+	var handed map[string]qItem.Item
+	if len(items) != 0 {
+		peer, handed = d.handoff(items)
+	}
+	d.BroadcastWorkers(ShutMessage(d.GetPrivByte()))
+	d.mu.Unlock()
+
+	if len(handed) != 0 {
+		d.awaitHandoff(peer, handed)
+	}
+	d.flushJobs()
+}
+
+//returns the number of execs running on workers or forwarded to neighbours
+func (d Dispatcher) running() int {
+	running := len(d.GetForwarded())
+	for _, info := range d.GetWorkers() {
+		if info.GetJob() != nil {
+			running++
+		}
+	}
+	return running
+}
+
+//sends queued execs to the neighbour with the most idle workers, returns the neighbour's pub and the execs keyed by handoff id
+//or nothing if no neighbour can take them
+func (d *Dispatcher) handoff(items []qItem.Item) (string, map[string]qItem.Item) {
+	var target *DispatcherInfo
+	for _, info := range d.GetNeighbours() {
+		if info.Supports(CapForward) && (target == nil || info.GetCapacity().GetIdle() > target.GetCapacity().GetIdle()) {
+			target = info
+		}
+	}
+	if target == nil {
+		return "", nil
+	}
+	handed := make(map[string]qItem.Item)
+	var forwards []Forward
+	for _, item := range items {
+		id := uuid.NewV4().String()
+		handed[id] = item
+		forwards = append(forwards, Forward{ID: id, Item: item})
+	}
+	payload, err := json.Marshal(forwards)
+	if err != nil {
+		glg.Fatal(err)
+	}
+	pub := hex.EncodeToString(target.GetPub())
+	d.MulticastNeighbours(HandoffMessage(payload, d.GetPrivByte()), []string{pub})
+	glg.Info("Dispatcher: handed " + strconv.Itoa(len(items)) + " queued execs off to neighbour - " + pub)
+	return pub, handed
+}
+
+//removes handed off execs from the queue journal once the neighbour acknowledges them, unacknowledged execs are kept for the next start
+func (d *Dispatcher) awaitHandoff(peer string, handed map[string]qItem.Item) {
+	timeout := time.After(HandoffTimeout)
+	for {
+		select {
+		case ack := <-d.handoffs:
+			if ack.peer != peer {
+				continue
+			}
+			d.mu.Lock()
+			for _, id := range ack.ids {
+				if item, ok := handed[id]; ok {
+					d.GetJobPQ().Forget(item.GetExec())
+					delete(handed, id)
+				}
+			}
+			d.mu.Unlock()
+			if len(handed) != 0 {
+				glg.Warn("Dispatcher: neighbour didn't take " + strconv.Itoa(len(handed)) + " handed off execs, keeping them in the queue journal")
+			}
+			return
+		case <-timeout:
+			glg.Warn("Dispatcher: handoff wasn't acknowledged, keeping " + strconv.Itoa(len(handed)) + " execs in the queue journal")
+			return
+		}
+	}
+}
+
+//queues execs handed off by a draining neighbour as the dispatcher's own
+func (d *Dispatcher) takeHandoff(pub string, payload []byte) {
+	if d.GetDraining() {
+		glg.Warn("Dispatcher: ignoring handoff while draining - " + pub)
+		return
+	}
+	var forwards []Forward
+	if err := json.Unmarshal(payload, &forwards); err != nil {
+		d.MulticastNeighbours(InvalidMessage(), []string{pub})
+		return
+	}
+	var ids []string
+	for _, f := range forwards {
+		if f.Item.GetExec() == nil || len(f.Item.GetJob().GetSignature()) != 2 || !f.Item.GetJob().Verify() {
+			glg.Warn("Dispatcher: dropping invalid handed off job from neighbour - " + pub)
+			continue
+		}
+		d.adopt(f.Item)
+		ids = append(ids, f.ID)
+	}
+	//! the neighbour only forgets the execs acknowledged here
+	ack, err := json.Marshal(ids)
+	if err != nil {
+		glg.Fatal(err)
+	}
+	d.MulticastNeighbours(HandoffAckMessage(ack, d.GetPrivByte()), []string{pub})
+	glg.Info("Dispatcher: took " + strconv.Itoa(len(ids)) + " queued execs from neighbour - " + pub)
+}
+
+//queues an exec whose submitter isn't connected to the dispatcher, its result is only written to the blockchain
+func (d *Dispatcher) adopt(item qItem.Item) {
+	results := make(chan qItem.Item, 1)
+	go func() {
+		<-results
+	}()
+	adopted := qItem.NewItem(item.GetJob(), item.GetExec(), results, nil)
+	d.GetJobPQ().PushItem(adopted, adopted.GetExec().GetPriority())
+}
+
+//writes jobs waiting in the write queue and the pending jobs into blocks
+func (d *Dispatcher) flushJobs() {
+	for d.GetWriteQ().Empty() == false {
+		jobs := d.GetWriteQ().Dequeue()
+		if jobs != nil {
+			d.WriteJobs(jobs.([]job.Job))
+		}
+	}
+	d.mu.Lock()
+	jobs := d.GetJobs()
+	d.EmptyJobs()
+	d.mu.Unlock()
+	if len(jobs) != 0 {
+		glg.Info("Dispatcher: writing " + strconv.Itoa(len(jobs)) + " pending jobs into a final block")
+		d.WriteJobs(jobs)
+	}
+}
@@ -0,0 +1,78 @@
+package p2p
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/Lobarr/lane"
+	"github.com/boltdb/bolt"
+	"github.com/gizo-network/gizo/config"
+	"github.com/gizo-network/gizo/crypt"
+	"github.com/gizo-network/gizo/job"
+	"github.com/gizo-network/gizo/job/queue"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDrain(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gizo-drain")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	db, err := bolt.Open(path.Join(dir, NodeDB), 0600, nil)
+	assert.NoError(t, err)
+	defer db.Close()
+	d, n, cleanup := forwardDispatcher(t)
+	defer cleanup()
+	d.jobPQ = queue.NewPersistentJobPriorityQueue(db, nil, nil)
+	d.cfg = config.Default()
+	d.discovery = NewStatic(nil)
+	d.writeQ = lane.NewQueue()
+
+	priv, _ := crypt.GenKeys()
+	taken := testItem(t, priv)
+	d.GetJobPQ().PushItem(taken, job.NORMAL)
+	kept := testItem(t, priv)
+	d.GetJobPQ().PushItem(kept, job.NORMAL)
+	cancelled := testItem(t, priv)
+	cancelled.GetExec().SetStatus(job.CANCELLED)
+	d.GetJobPQ().PushItem(cancelled, job.NORMAL)
+
+	//! an acknowledgement from another neighbour is ignored
+	d.handoffs <- handoffAck{peer: "other", ids: []string{}}
+	done := make(chan struct{})
+	go func() {
+		d.Drain()
+		close(done)
+	}()
+
+	m := n.read(t)
+	assert.Equal(t, HANDOFF, m.GetMessage())
+	var forwards []Forward
+	assert.NoError(t, json.Unmarshal(m.GetPayload(), &forwards))
+	assert.Len(t, forwards, 2) //! cancelled execs aren't handed off
+	var ack []string
+	for _, f := range forwards {
+		if f.Item.GetJob().GetID() == taken.GetJob().GetID() {
+			ack = append(ack, f.ID)
+		}
+	}
+	assert.Len(t, ack, 1)
+	for len(d.handoffs) != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	payload, err := json.Marshal(ack)
+	assert.NoError(t, err)
+	d.handleForward(n.info, n.message(t, HandoffAckMessage(payload, n.priv)))
+	<-done
+
+	assert.True(t, d.GetDraining())
+	//! only the acknowledged exec is forgotten, the others stay in the journal for the next start
+	assert.Empty(t, d.GetJobPQ().Results(taken.GetJob().GetID()))
+	records := d.GetJobPQ().Results(kept.GetJob().GetID())
+	assert.Len(t, records, 1)
+	assert.False(t, records[0].Done())
+	assert.Equal(t, kept.GetExec().GetHash(), records[0].GetItem().GetExec().GetHash())
+}
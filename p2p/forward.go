@@ -0,0 +1,239 @@
+package p2p
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/gizo-network/gizo/job"
+	"github.com/gizo-network/gizo/job/queue/qItem"
+	"github.com/kpango/glg"
+	uuid "github.com/satori/go.uuid"
+)
+
+//Forward is an exec sent to a neighbour to run on its workers
+type Forward struct {
+	ID   string     `json:"id"`
+	Item qItem.Item `json:"item"` // job keeps the submitter's signature
+}
+
+//ForwardResult is the result of a forwarded exec sent back to the origin dispatcher
+type ForwardResult struct {
+	ID    string   `json:"id"`
+	JobID string   `json:"job_id"` // must be the job of the forwarded exec
+	Exec  job.Exec `json:"exec"`
+}
+
+//an exec forwarded to a neighbour, requeued locally if the neighbour disconnects or doesn't answer in time.
+//
+//forwarded execs run at least once, a requeued exec may still be running on the neighbour, its result is
+//dropped when it arrives as only the result of the requeued exec is kept
+type forwarded struct {
+	item     qItem.Item
+	peer     string // pub of the neighbour running the exec
+	deadline int64
+}
+
+func (d Dispatcher) GetForwarded() map[string]*forwarded {
+	return d.forwarded
+}
+
+func (d Dispatcher) GetForeign() map[chan<- qItem.Item]string {
+	return d.foreign
+}
+
+//returns true if the item was forwarded by a neighbour, its result is written to the origin's blockchain
+func (d Dispatcher) isForeign(item qItem.Item) bool {
+	_, ok := d.GetForeign()[item.ResultsChan()]
+	return ok
+}
+
+//returns the spare capacity of the dispatcher
+func (d Dispatcher) capacity() Capacity {
+	if d.GetDraining() {
+		return NewCapacity(0, d.GetJobPQ().Len())
+	}
+	return NewCapacity(d.GetWorkerPQ().getPQ().Size(), d.GetJobPQ().Len())
+}
+
+//periodically advertises capacity to neighbours and forwards queued execs when no local worker is idle
+func (d *Dispatcher) forwardJobs() {
+	ticker := time.NewTicker(ForwardInterval)
+	for range ticker.C {
+		d.mu.Lock()
+		var peers []string
+		for _, info := range d.GetNeighbours() {
+			if info.Supports(CapForward) {
+				peers = append(peers, hex.EncodeToString(info.GetPub()))
+			}
+		}
+		d.MulticastNeighbours(CapacityMessage(d.capacity().Serialize(), d.GetPrivByte()), peers)
+		if d.GetWorkerPQ().getPQ().Empty() && !d.GetDraining() {
+			d.forward()
+		}
+		d.requeueForwarded("")
+		d.mu.Unlock()
+	}
+}
+
+//sends queued execs to the neighbours with the most idle workers
+func (d *Dispatcher) forward() {
+	var targets []*DispatcherInfo
+	for _, info := range d.GetNeighbours() {
+		if info.Supports(CapForward) && info.GetCapacity().GetIdle() > 0 {
+			targets = append(targets, info)
+		}
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i].GetCapacity().GetIdle() > targets[j].GetCapacity().GetIdle()
+	})
+	var skipped []qItem.Item
+	for _, target := range targets {
+		sent := 0
+		for sent < target.GetCapacity().GetIdle() && sent < MaxForwardBatch && d.GetJobPQ().Empty() == false {
+			item := d.GetJobPQ().Pop()
+			if item.GetExec().GetStatus() == job.CANCELLED {
+				d.GetJobPQ().Complete(item.GetExec(), *item.GetExec())
+				item.ResultsChan() <- item
+				continue
+			}
+			if d.isForeign(item) || item.GetExec().GetReplicas() > 1 {
+				//! execs are forwarded once and replica sets stay with their origin
+				skipped = append(skipped, item)
+				continue
+			}
+			f := Forward{ID: uuid.NewV4().String(), Item: item}
+			payload, err := json.Marshal(f)
+			if err != nil {
+				glg.Fatal(err)
+			}
+			ttl := item.GetExec().GetTTL()
+			if ttl == 0 {
+				ttl = job.DefaultMaxTTL
+			}
+			pub := hex.EncodeToString(target.GetPub())
+			d.GetForwarded()[f.ID] = &forwarded{item: item, peer: pub, deadline: time.Now().Add(ttl + ForwardTimeout).Unix()}
+			item.GetExec().SetStatus(job.DISPATHCHED)
+			d.GetJobPQ().Update(item.GetExec())
+			d.MulticastNeighbours(ForwardMessage(payload, d.GetPrivByte()), []string{pub})
+			glg.Info("Dispatcher: forwarded job to neighbour - " + pub)
+			sent++
+		}
+		//! assumes the neighbour's workers are busy until it advertises again
+		target.SetCapacity(NewCapacity(target.GetCapacity().GetIdle()-sent, target.GetCapacity().GetQueued()+sent))
+	}
+	for _, item := range skipped {
+		d.GetJobPQ().PushItem(item, item.GetExec().GetPriority())
+	}
+}
+
+//requeues execs forwarded to a neighbour, every overdue exec if pub is empty.
+//the neighbour isn't told, results of requeued execs it sends later are dropped as unknown
+func (d *Dispatcher) requeueForwarded(pub string) {
+	for id, f := range d.GetForwarded() {
+		if (pub != "" && f.peer == pub) || (pub == "" && time.Now().Unix() > f.deadline) {
+			glg.Warn("Dispatcher: requeuing job forwarded to neighbour - " + f.peer)
+			delete(d.GetForwarded(), id)
+			d.GetJobPQ().PushItem(f.item, job.HIGH)
+		}
+	}
+}
+
+//handles capacity, forwarded and handed off execs and results from a neighbour
+func (d *Dispatcher) handleForward(info *DispatcherInfo, m PeerMessage) {
+	pub := hex.EncodeToString(info.GetPub())
+	switch m.GetMessage() {
+	case CAPACITY:
+		c, err := DeserializeCapacity(m.GetPayload())
+		if err == nil {
+			info.SetCapacity(c)
+		}
+		break
+	case FORWARD:
+		var f Forward
+		if err := json.Unmarshal(m.GetPayload(), &f); err != nil || f.Item.GetExec() == nil || len(f.Item.GetJob().GetSignature()) != 2 || !f.Item.GetJob().Verify() {
+			glg.Warn("Dispatcher: rejecting invalid forwarded job from neighbour - " + pub)
+			d.MulticastNeighbours(InvalidMessage(), []string{pub})
+			break
+		}
+		results := make(chan qItem.Item, 1)
+		item := qItem.NewItem(f.Item.GetJob(), f.Item.GetExec(), results, nil)
+		d.GetForeign()[results] = pub
+		d.GetJobPQ().PushItem(item, item.GetExec().GetPriority())
+		d.GetJobPQ().Forget(item.GetExec()) //! the origin requeues the exec if this dispatcher goes down
+		glg.Info("Dispatcher: received forwarded job from neighbour - " + pub)
+		go d.returnForwarded(f.ID, results)
+		break
+	case HANDOFF:
+		d.takeHandoff(pub, m.GetPayload())
+		break
+	case HANDOFFACK:
+		var ids []string
+		if err := json.Unmarshal(m.GetPayload(), &ids); err != nil {
+			d.MulticastNeighbours(InvalidMessage(), []string{pub})
+			break
+		}
+		select {
+		case d.handoffs <- handoffAck{peer: pub, ids: ids}:
+		default:
+			glg.Warn("Dispatcher: dropping unexpected handoff acknowledgement from neighbour - " + pub)
+		}
+		break
+	case FORWARDRES:
+		var res ForwardResult
+		if err := json.Unmarshal(m.GetPayload(), &res); err != nil {
+			d.MulticastNeighbours(InvalidMessage(), []string{pub})
+			break
+		}
+		f, ok := d.GetForwarded()[res.ID]
+		if !ok || f.peer != pub {
+			glg.Warn("Dispatcher: dropping unknown forwarded result from neighbour - " + pub)
+			break
+		}
+		if res.JobID != f.item.GetJob().GetID() || !sameExec(f.item.GetExec(), res.Exec) {
+			glg.Warn("Dispatcher: dropping forwarded result of another exec from neighbour - " + pub)
+			d.MulticastNeighbours(InvalidMessage(), []string{pub})
+			break
+		}
+		delete(d.GetForwarded(), res.ID)
+		glg.Info("Dispatcher: received forwarded result from neighbour - " + pub)
+		item := f.item
+		d.GetJobPQ().Complete(item.GetExec(), res.Exec)
+		item.SetExec(&res.Exec)
+		item.ResultsChan() <- item
+		j := item.GetJob()
+		j.AddExec(res.Exec)
+		d.AddJob(j)
+		break
+	}
+}
+
+//sends the result of a forwarded exec back to its origin
+func (d *Dispatcher) returnForwarded(id string, results chan qItem.Item) {
+	item := <-results
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	origin := d.GetForeign()[results]
+	delete(d.GetForeign(), results)
+	payload, err := json.Marshal(ForwardResult{ID: id, JobID: item.GetJob().GetID(), Exec: *item.GetExec()})
+	if err != nil {
+		glg.Fatal(err)
+	}
+	d.MulticastNeighbours(ForwardResMessage(payload, d.GetPrivByte()), []string{origin})
+}
+
+//returns true if res is the result of exec, neighbours only set the outcome of an exec they're forwarded
+func sameExec(exec *job.Exec, res job.Exec) bool {
+	args, err := json.Marshal(exec.GetArgs())
+	if err != nil {
+		return false
+	}
+	resArgs, err := json.Marshal(res.GetArgs())
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(args, resArgs) && bytes.Equal(exec.Envs, res.Envs) && exec.GetPub() == res.GetPub() &&
+		exec.GetSubmitter() == res.GetSubmitter() && exec.GetIdempotencyKey() == res.GetIdempotencyKey()
+}
@@ -0,0 +1,206 @@
+package p2p
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gizo-network/gizo/crypt"
+	"github.com/gizo-network/gizo/job"
+	"github.com/gizo-network/gizo/job/queue"
+	"github.com/gizo-network/gizo/job/queue/qItem"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/olahol/melody.v1"
+)
+
+//a neighbouring dispatcher, messages sent to it are read from conn
+type neighbour struct {
+	info *DispatcherInfo
+	conn *websocket.Conn
+	priv []byte
+}
+
+//reads the next message sent to the neighbour
+func (n neighbour) read(t *testing.T) PeerMessage {
+	n.conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+	_, message, err := n.conn.ReadMessage()
+	assert.NoError(t, err)
+	m, err := DeserializePeerMessage(message)
+	assert.NoError(t, err)
+	return m
+}
+
+//returns a message signed by the neighbour as it's received by the dispatcher
+func (n neighbour) message(t *testing.T, b []byte) PeerMessage {
+	m, err := DeserializePeerMessage(b)
+	assert.NoError(t, err)
+	return m
+}
+
+//returns a dispatcher without workers and a neighbour that supports forwarding
+func forwardDispatcher(t *testing.T) (*Dispatcher, neighbour, func()) {
+	priv, pub := crypt.GenKeys()
+	d := &Dispatcher{
+		priv:      priv,
+		Pub:       pub,
+		mu:        new(sync.Mutex),
+		jobPQ:     queue.NewJobPriorityQueue(),
+		workers:   make(map[*melody.Session]*WorkerInfo),
+		replicas:  make(map[*job.Exec]*ReplicaSet),
+		parked:    make(map[*job.Exec]qItem.Item),
+		neighbors: make(map[interface{}]*DispatcherInfo),
+		workerPQ:  NewWorkerPriorityQueue(),
+		forwarded: make(map[string]*forwarded),
+		foreign:   make(map[chan<- qItem.Item]string),
+		draining:  make(chan struct{}),
+		handoffs:  make(chan handoffAck, 1),
+	}
+	s, conn, cleanup := connect(t)
+	nPriv, nPub := crypt.GenKeys()
+	info := NewDispatcherInfo(nPub)
+	info.SetProtocol(ProtocolVersion, []string{CapForward})
+	d.neighbors[s] = info
+	return d, neighbour{info: info, conn: conn, priv: nPriv}, cleanup
+}
+
+func TestCapacity(t *testing.T) {
+	d, n, cleanup := forwardDispatcher(t)
+	defer cleanup()
+	d.handleForward(n.info, n.message(t, CapacityMessage(NewCapacity(2, 3).Serialize(), n.priv)))
+	assert.Equal(t, NewCapacity(2, 3), n.info.GetCapacity())
+
+	priv, _ := crypt.GenKeys()
+	d.GetJobPQ().PushItem(testItem(t, priv), job.NORMAL)
+	assert.Equal(t, NewCapacity(0, 1), d.capacity())
+	close(d.draining)
+	assert.Equal(t, 0, d.capacity().GetIdle()) //! draining dispatchers don't take forwarded execs
+}
+
+func TestForward(t *testing.T) {
+	d, n, cleanup := forwardDispatcher(t)
+	defer cleanup()
+	n.info.SetCapacity(NewCapacity(2, 0))
+	priv, _ := crypt.GenKeys()
+	results := make(chan qItem.Item, 1)
+	item := testItem(t, priv)
+	item = qItem.NewItem(item.GetJob(), item.GetExec(), results, nil)
+	item.GetExec().SetArgs([]interface{}{1})
+	d.GetJobPQ().PushItem(item, job.NORMAL)
+
+	d.forward()
+	m := n.read(t)
+	assert.Equal(t, FORWARD, m.GetMessage())
+	var f Forward
+	assert.NoError(t, json.Unmarshal(m.GetPayload(), &f))
+	assert.True(t, f.Item.GetJob().Verify())
+	assert.Contains(t, d.GetForwarded(), f.ID)
+	assert.Equal(t, job.DISPATHCHED, item.GetExec().GetStatus())
+	assert.Equal(t, NewCapacity(1, 1), n.info.GetCapacity()) //! assumed busy until the neighbour advertises again
+	assert.True(t, d.GetJobPQ().Empty())
+
+	result := func(id, jobID string, args []interface{}) PeerMessage {
+		exec := *f.Item.GetExec()
+		exec.SetArgs(args)
+		exec.SetStatus(job.FINISHED)
+		exec.SetResult(2)
+		payload, err := json.Marshal(ForwardResult{ID: id, JobID: jobID, Exec: exec})
+		assert.NoError(t, err)
+		return n.message(t, ForwardResMessage(payload, n.priv))
+	}
+	//! results of another job or exec are rejected
+	d.handleForward(n.info, result(f.ID, "other", []interface{}{1}))
+	assert.Equal(t, ERROR, n.read(t).GetMessage())
+	d.handleForward(n.info, result(f.ID, f.Item.GetJob().GetID(), []interface{}{2}))
+	assert.Equal(t, ERROR, n.read(t).GetMessage())
+	d.handleForward(n.info, result("unknown", f.Item.GetJob().GetID(), []interface{}{1}))
+	assert.Contains(t, d.GetForwarded(), f.ID)
+	assert.Empty(t, results)
+
+	d.handleForward(n.info, result(f.ID, f.Item.GetJob().GetID(), []interface{}{1}))
+	assert.NotContains(t, d.GetForwarded(), f.ID)
+	assert.EqualValues(t, 2, (<-results).GetExec().GetResult())
+	assert.Len(t, d.GetJobs(), 1)
+}
+
+func TestForwardReceived(t *testing.T) {
+	d, n, cleanup := forwardDispatcher(t)
+	defer cleanup()
+	priv, _ := crypt.GenKeys()
+	payload, err := json.Marshal(Forward{ID: "forward", Item: testItem(t, priv)})
+	assert.NoError(t, err)
+	d.handleForward(n.info, n.message(t, ForwardMessage(payload, n.priv)))
+	assert.Equal(t, 1, d.GetJobPQ().Len())
+
+	//! the result is sent back to the origin
+	item := d.GetJobPQ().Pop()
+	assert.True(t, d.isForeign(item))
+	item.GetExec().SetStatus(job.FINISHED)
+	item.GetExec().SetResult(1)
+	item.ResultsChan() <- item
+	m := n.read(t)
+	assert.Equal(t, FORWARDRES, m.GetMessage())
+	var res ForwardResult
+	assert.NoError(t, json.Unmarshal(m.GetPayload(), &res))
+	assert.Equal(t, "forward", res.ID)
+	assert.Equal(t, item.GetJob().GetID(), res.JobID)
+	assert.EqualValues(t, 1, res.Exec.GetResult())
+
+	//! jobs that fail verification aren't queued
+	tampered := testItem(t, priv)
+	tampered.Job.Name = "Tampered"
+	payload, err = json.Marshal(Forward{ID: "tampered", Item: tampered})
+	assert.NoError(t, err)
+	d.handleForward(n.info, n.message(t, ForwardMessage(payload, n.priv)))
+	assert.Equal(t, ERROR, n.read(t).GetMessage())
+	assert.True(t, d.GetJobPQ().Empty())
+}
+
+func TestRequeueForwarded(t *testing.T) {
+	d, n, cleanup := forwardDispatcher(t)
+	defer cleanup()
+	priv, _ := crypt.GenKeys()
+	queued := testItem(t, priv)
+	d.GetJobPQ().PushItem(queued, job.NORMAL)
+	overdue := testItem(t, priv)
+	d.GetForwarded()["overdue"] = &forwarded{item: overdue, peer: hex.EncodeToString(n.info.GetPub()), deadline: time.Now().Add(-time.Second).Unix()}
+	running := testItem(t, priv)
+	d.GetForwarded()["running"] = &forwarded{item: running, peer: hex.EncodeToString(n.info.GetPub()), deadline: time.Now().Add(time.Hour).Unix()}
+
+	d.requeueForwarded("")
+	assert.NotContains(t, d.GetForwarded(), "overdue")
+	assert.Contains(t, d.GetForwarded(), "running")
+	assert.Equal(t, overdue.GetExec(), d.GetJobPQ().Pop().GetExec()) //! requeued ahead of queued execs
+
+	//! every exec forwarded to a neighbour is requeued once it disconnects
+	d.requeueForwarded(hex.EncodeToString(n.info.GetPub()))
+	assert.Empty(t, d.GetForwarded())
+	assert.Equal(t, running.GetExec(), d.GetJobPQ().Pop().GetExec())
+	assert.Equal(t, queued.GetExec(), d.GetJobPQ().Pop().GetExec())
+}
+
+func TestTakeHandoff(t *testing.T) {
+	d, n, cleanup := forwardDispatcher(t)
+	defer cleanup()
+	priv, _ := crypt.GenKeys()
+	tampered := testItem(t, priv)
+	tampered.Job.Name = "Tampered"
+	payload, err := json.Marshal([]Forward{{ID: "valid", Item: testItem(t, priv)}, {ID: "tampered", Item: tampered}})
+	assert.NoError(t, err)
+	d.handleForward(n.info, n.message(t, HandoffMessage(payload, n.priv)))
+
+	//! only the execs that were queued are acknowledged
+	m := n.read(t)
+	assert.Equal(t, HANDOFFACK, m.GetMessage())
+	var ids []string
+	assert.NoError(t, json.Unmarshal(m.GetPayload(), &ids))
+	assert.Equal(t, []string{"valid"}, ids)
+	assert.Equal(t, 1, d.GetJobPQ().Len())
+
+	//! draining dispatchers don't take handoffs
+	close(d.draining)
+	d.handleForward(n.info, n.message(t, HandoffMessage(payload, n.priv)))
+	assert.Equal(t, 1, d.GetJobPQ().Len())
+}
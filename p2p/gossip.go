@@ -0,0 +1,124 @@
+package p2p
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/kpango/glg"
+	funk "github.com/thoas/go-funk"
+)
+
+//Dial connects to the dispatcher at addr and adds it as a neighbour
+func (d *Dispatcher) Dial(addr string) (*websocket.Conn, error) {
+	pub, err := addrPub(addr)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := ParseAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+	pubBytes, err := hex.DecodeString(pub)
+	if err != nil {
+		return nil, err
+	}
+	dailer := websocket.Dialer{
+		Proxy:           http.ProxyFromEnvironment,
+		ReadBufferSize:  d.GetConfig().Worker.ReadBufferSize,
+		WriteBufferSize: d.GetConfig().Worker.WriteBufferSize,
+		TLSClientConfig: d.GetTransport().ClientConfig(pub, parsed["ip"].(string)),
+	}
+	conn, _, err := dailer.Dial(fmt.Sprintf("%v://%v:%v/d", d.GetTransport().WSScheme(), parsed["ip"], parsed["port"]), nil)
+	if err != nil {
+		d.GetAddrBook().Failed(addr)
+		return nil, err
+	}
+	conn.EnableWriteCompression(true)
+	d.mu.Lock()
+	d.NewNeighbour(conn, NewDispatcherInfo(pubBytes))
+	d.mu.Unlock()
+	go d.HandleNodeConnect(conn, addr)
+	return conn, nil
+}
+
+//periodically exchanges addresses with neighbours and dials new ones to keep the target peer count
+func (d *Dispatcher) gossip() {
+	ticker := time.NewTicker(GossipInterval)
+	for range ticker.C {
+		d.mu.Lock()
+		var pex []string
+		for _, info := range d.GetNeighbours() {
+			if info.Supports(CapPeerExchange) {
+				pex = append(pex, hex.EncodeToString(info.GetPub()))
+			}
+		}
+		d.MulticastNeighbours(PeerReqMessage(d.GetPrivByte()), pex)
+		connected := d.GetNeighboursPubs()
+		d.mu.Unlock()
+		d.fillPeers(connected)
+	}
+}
+
+//dials the most recently seen known dispatchers until the target peer count is reached
+func (d *Dispatcher) fillPeers(connected []string) {
+	missing := d.GetConfig().Dispatcher.TargetPeers - len(connected)
+	if missing <= 0 {
+		return
+	}
+	infos := d.GetAddrBook().GetInfos()
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].GetLastSeen() > infos[j].GetLastSeen()
+	})
+	for _, info := range infos {
+		if missing <= 0 {
+			return
+		}
+		pub, err := addrPub(info.GetAddr())
+		if err != nil || pub == d.GetPubString() || funk.ContainsString(connected, pub) {
+			continue
+		}
+		if _, err = d.Dial(info.GetAddr()); err != nil {
+			glg.Warn("Dispatcher: unable to dial peer - " + err.Error())
+			continue
+		}
+		glg.Info("Dispatcher: connected to peer from address book")
+		connected = append(connected, pub)
+		missing--
+	}
+}
+
+//returns the addresses shared with neighbours, only addresses dispatchers have been dialed at are shared
+func (d Dispatcher) peersPayload() []byte {
+	addrs := append([]string{d.GetAddr()}, d.GetAddrBook().GetSeenAddrs()...)
+	if len(addrs) > MaxGossipAddrs {
+		addrs = addrs[:MaxGossipAddrs]
+	}
+	bytes, err := json.Marshal(addrs)
+	if err != nil {
+		glg.Fatal(err)
+	}
+	return bytes
+}
+
+//adds addresses shared by a neighbour to the address book
+func (d Dispatcher) addPeers(payload []byte) {
+	var addrs []string
+	if err := json.Unmarshal(payload, &addrs); err != nil {
+		glg.Warn("Dispatcher: invalid peers message")
+		return
+	}
+	if len(addrs) > MaxGossipAddrs {
+		addrs = addrs[:MaxGossipAddrs]
+	}
+	for _, addr := range addrs {
+		if pub, err := addrPub(addr); err != nil || pub == d.GetPubString() {
+			continue
+		}
+		d.GetAddrBook().Add(addr)
+	}
+}
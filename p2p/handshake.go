@@ -0,0 +1,133 @@
+package p2p
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+
+	"github.com/gizo-network/gizo/crypt"
+	"github.com/kpango/glg"
+)
+
+//Handshake proves possession of a node key by signing the peer's challenge
+type Handshake struct {
+	Version      int      `json:"version"`      // protocol version of the sender
+	Capabilities []string `json:"capabilities"` // features supported by the sender
+	Pub          []byte   `json:"pub"`
+	Challenge    []byte   `json:"challenge"` // nonce the peer must sign
+	Response     [][]byte `json:"response"`  // signature over the peer's challenge
+}
+
+func NewHandshake(pub, challenge []byte, response [][]byte) Handshake {
+	return Handshake{Version: ProtocolVersion, Capabilities: Capabilities, Pub: pub, Challenge: challenge, Response: response}
+}
+
+func (h Handshake) GetVersion() int {
+	return h.Version
+}
+
+func (h Handshake) GetCapabilities() []string {
+	return h.Capabilities
+}
+
+func (h Handshake) GetPub() []byte {
+	return h.Pub
+}
+
+func (h Handshake) GetChallenge() []byte {
+	return h.Challenge
+}
+
+func (h Handshake) GetResponse() [][]byte {
+	return h.Response
+}
+
+func (h Handshake) Serialize() []byte {
+	bytes, err := json.Marshal(h)
+	if err != nil {
+		glg.Fatal(err)
+	}
+	return bytes
+}
+
+func DeserializeHandshake(b []byte) (Handshake, error) {
+	var temp Handshake
+	err := json.Unmarshal(b, &temp)
+	return temp, err
+}
+
+//NewChallenge returns a random nonce for a peer to sign
+func NewChallenge() []byte {
+	challenge := make([]byte, ChallengeSize)
+	if _, err := rand.Read(challenge); err != nil {
+		glg.Fatal(err)
+	}
+	return challenge
+}
+
+//returns the data signed in response to a challenge, it binds the response to the role it's sent in (domain),
+//the node signing it and the peer it's meant for so it can't be relayed to another peer or replayed in the other role
+func transcript(domain string, challenge, signer, peer []byte) []byte {
+	parts := [][]byte{[]byte(domain)}
+	for _, part := range [][]byte{challenge, signer, peer} {
+		hash := sha256.Sum256(part)
+		parts = append(parts, hash[:])
+	}
+	return bytes.Join(parts, []byte{})
+}
+
+//SignChallenge returns the response of signer to a challenge sent by peer, domain is HelloDomain or AuthDomain
+func SignChallenge(priv []byte, domain string, challenge, signer, peer []byte) [][]byte {
+	sig, err := crypt.Sign(priv, transcript(domain, challenge, signer, peer))
+	if err != nil {
+		glg.Fatal(err)
+	}
+	return sig
+}
+
+//VerifyChallenge returns true if response is the signature by signer of the challenge sent by peer in domain
+func VerifyChallenge(signer []byte, domain string, challenge, peer []byte, response [][]byte) bool {
+	if len(challenge) == 0 {
+		return false
+	}
+	return crypt.Verify(signer, transcript(domain, challenge, signer, peer), response)
+}
+
+//PendingAuth is a peer that sent a hello and has yet to answer the challenge
+type PendingAuth struct {
+	pub       []byte
+	challenge []byte
+	version   int              // negotiated protocol version
+	caps      []string         // negotiated capabilities
+	hello     *DispatcherHello // set for dispatchers
+}
+
+func NewPendingAuth(pub, challenge []byte, version int, caps []string, hello *DispatcherHello) *PendingAuth {
+	return &PendingAuth{pub: pub, challenge: challenge, version: version, caps: caps, hello: hello}
+}
+
+func (p PendingAuth) GetVersion() int {
+	return p.version
+}
+
+func (p PendingAuth) GetCapabilities() []string {
+	return p.caps
+}
+
+func (p PendingAuth) GetPub() []byte {
+	return p.pub
+}
+
+func (p PendingAuth) GetChallenge() []byte {
+	return p.challenge
+}
+
+func (p PendingAuth) GetHello() *DispatcherHello {
+	return p.hello
+}
+
+//Verify returns true if the auth answers the challenge sent to the peer by the node with pub
+func (p PendingAuth) Verify(h Handshake, pub []byte) bool {
+	return VerifyChallenge(p.GetPub(), AuthDomain, p.GetChallenge(), pub, h.GetResponse())
+}
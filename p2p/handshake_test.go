@@ -0,0 +1,46 @@
+package p2p_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gizo-network/gizo/crypt"
+	"github.com/gizo-network/gizo/p2p"
+)
+
+func TestHandshake(t *testing.T) {
+	dPriv, dPub := crypt.GenKeys()
+	wPriv, wPub := crypt.GenKeys()
+
+	//! worker sends a hello with its challenge, the dispatcher answers it and sends its own
+	wChallenge := p2p.NewChallenge()
+	dChallenge := p2p.NewChallenge()
+	hello := p2p.SignChallenge(dPriv, p2p.HelloDomain, wChallenge, dPub, wPub)
+	assert.True(t, p2p.VerifyChallenge(dPub, p2p.HelloDomain, wChallenge, wPub, hello))
+
+	pending := p2p.NewPendingAuth(wPub, dChallenge, p2p.ProtocolVersion, nil, nil)
+	auth := p2p.NewHandshake(wPub, nil, p2p.SignChallenge(wPriv, p2p.AuthDomain, dChallenge, wPub, dPub))
+	assert.True(t, pending.Verify(auth, dPub))
+
+	//! responses are bound to their role and the peer they're meant for
+	assert.False(t, p2p.VerifyChallenge(dPub, p2p.AuthDomain, wChallenge, wPub, hello))
+	assert.False(t, pending.Verify(auth, wPub))
+	assert.False(t, p2p.NewPendingAuth(wPub, p2p.NewChallenge(), p2p.ProtocolVersion, nil, nil).Verify(auth, dPub))
+}
+
+func TestHandshakeRelay(t *testing.T) {
+	_, dPub := crypt.GenKeys()
+	vPriv, vPub := crypt.GenKeys()
+	_, mPub := crypt.GenKeys()
+
+	//! m claims to be v on a hello to d and relays d's challenge to v as its own hello challenge
+	dChallenge := p2p.NewChallenge()
+	pending := p2p.NewPendingAuth(vPub, dChallenge, p2p.ProtocolVersion, nil, nil)
+	relayed := p2p.SignChallenge(vPriv, p2p.HelloDomain, dChallenge, vPub, mPub)
+	assert.False(t, pending.Verify(p2p.NewHandshake(vPub, nil, relayed), dPub))
+
+	//! m also fails if it claims to be d when dialing v
+	relayed = p2p.SignChallenge(vPriv, p2p.HelloDomain, dChallenge, vPub, dPub)
+	assert.False(t, pending.Verify(p2p.NewHandshake(vPub, nil, relayed), dPub))
+}
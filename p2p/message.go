@@ -6,27 +6,41 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"crypto/x509"
+	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
 	"math/big"
+	"time"
 
 	"github.com/kpango/glg"
 )
 
 type PeerMessage struct {
+	Version   int      `json:"version"` // protocol version of the sender
 	Message   string   `json:"message"`
 	Payload   []byte   `json:"payload"`
+	Timestamp int64    `json:"timestamp"` // time the message was signed
+	Nonce     []byte   `json:"nonce"`     // unique per signed message to prevent replays
 	Signature [][]byte `json:"signature"`
 }
 
 func NewPeerMessage(message string, payload []byte, priv []byte) PeerMessage {
-	pm := PeerMessage{Message: message, Payload: payload}
+	pm := PeerMessage{Version: ProtocolVersion, Message: message, Payload: payload}
 	if priv != nil {
 		pm.sign(priv)
 	}
 	return pm
 }
 
+func (m PeerMessage) GetVersion() int {
+	return m.Version
+}
+
+//Compatible returns true if the sender's protocol version is supported
+func (m PeerMessage) Compatible() bool {
+	return m.GetVersion() >= MinProtocolVersion
+}
+
 func (m PeerMessage) GetMessage() string {
 	return m.Message
 }
@@ -35,6 +49,14 @@ func (m PeerMessage) GetPayload() []byte {
 	return m.Payload
 }
 
+func (m PeerMessage) GetTimestamp() int64 {
+	return m.Timestamp
+}
+
+func (m PeerMessage) GetNonce() []byte {
+	return m.Nonce
+}
+
 func (m PeerMessage) GetSignature() [][]byte {
 	return m.Signature
 }
@@ -51,14 +73,31 @@ func (m *PeerMessage) setSignature(sig [][]byte) {
 	m.Signature = sig
 }
 
-func (m *PeerMessage) sign(priv []byte) {
-	hash := sha256.Sum256(bytes.Join(
+//returns the hash of the signed fields of the message
+func (m PeerMessage) hash() [32]byte {
+	timestamp := make([]byte, 8)
+	binary.BigEndian.PutUint64(timestamp, uint64(m.GetTimestamp()))
+	version := make([]byte, 8)
+	binary.BigEndian.PutUint64(version, uint64(m.GetVersion()))
+	return sha256.Sum256(bytes.Join(
 		[][]byte{
+			version,
 			[]byte(m.GetMessage()),
 			m.GetPayload(),
+			timestamp,
+			m.GetNonce(),
 		},
 		[]byte{},
 	))
+}
+
+func (m *PeerMessage) sign(priv []byte) {
+	m.Timestamp = time.Now().Unix()
+	m.Nonce = make([]byte, NonceSize)
+	if _, err := rand.Read(m.Nonce); err != nil {
+		glg.Fatal("Unable to sign peer message")
+	}
+	hash := m.hash()
 	privateKey, _ := x509.ParseECPrivateKey(priv)
 	r, s, err := ecdsa.Sign(rand.Reader, privateKey, hash[:])
 	if err != nil {
@@ -74,19 +113,16 @@ func (m *PeerMessage) VerifySignature(pub string) bool {
 	if err != nil {
 		glg.Fatal(err)
 	}
+	if len(m.GetSignature()) != 2 {
+		return false
+	}
 	var r big.Int
 	var s big.Int
 	r.SetBytes(m.GetSignature()[0])
 	s.SetBytes(m.GetSignature()[1])
 
 	publicKey, _ := x509.ParsePKIXPublicKey(pubBytes)
-	hash := sha256.Sum256(bytes.Join(
-		[][]byte{
-			[]byte(m.GetMessage()),
-			m.GetPayload(),
-		},
-		[]byte{},
-	))
+	hash := m.hash()
 	switch pubConv := publicKey.(type) {
 	case *ecdsa.PublicKey:
 		return ecdsa.Verify(pubConv, hash[:], &r, &s)
@@ -103,11 +139,8 @@ func (m PeerMessage) Serialize() []byte {
 	return bytes
 }
 
-func DeserializePeerMessage(b []byte) PeerMessage {
+func DeserializePeerMessage(b []byte) (PeerMessage, error) {
 	var temp PeerMessage
 	err := json.Unmarshal(b, &temp)
-	if err != nil {
-		glg.Fatal(err)
-	}
-	return temp
+	return temp, err
 }
@@ -0,0 +1,49 @@
+package p2p
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gizo-network/gizo/metrics"
+	"github.com/kpango/glg"
+)
+
+//registers the dispatcher's gauges and exposes them on the router
+func (d *Dispatcher) registerMetrics() {
+	metrics.NewGaugeFunc("job_queue_depth", "Number of execs waiting in the job queue", func() float64 {
+		return float64(d.GetJobPQ().Len())
+	})
+	metrics.NewGaugeFunc("write_queue_depth", "Number of job batches waiting to be written to the blockchain", func() float64 {
+		return float64(d.GetWriteQ().Size())
+	})
+	metrics.NewGaugeFunc("pending_jobs", "Number of jobs waiting to be placed in a block", func() float64 {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return float64(len(d.GetJobs()))
+	})
+	metrics.NewGaugeFunc("workers", "Number of connected workers", func() float64 {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return float64(len(d.GetWorkers()))
+	})
+	metrics.NewGaugeFunc("neighbours", "Number of connected neighbour dispatchers", func() float64 {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return float64(len(d.GetNeighbours()))
+	})
+	metrics.NewGaugeFunc("job_cache_jobs", "Number of jobs held in the job cache", func() float64 {
+		return float64(d.GetJC().Stats().Len)
+	})
+	metrics.ChainHeight.Set(float64(d.GetBC().GetLatestHeight()))
+	d.router.Handle(metrics.Path, metrics.Handler())
+}
+
+//serves the worker's metrics on its port
+func (w *Worker) serveMetrics() {
+	router := http.NewServeMux()
+	router.Handle(metrics.Path, metrics.Handler())
+	glg.Info("Worker: serving metrics on port " + strconv.Itoa(w.GetPort()))
+	if err := http.ListenAndServe(":"+strconv.Itoa(w.GetPort()), router); err != nil {
+		glg.Error(err)
+	}
+}
@@ -0,0 +1,225 @@
+package p2p
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	upnp "github.com/NebulousLabs/go-upnp"
+	"github.com/gizo-network/gizo/config"
+	"github.com/jackpal/gateway"
+	natpmp "github.com/jackpal/go-nat-pmp"
+	"github.com/kpango/glg"
+)
+
+var (
+	ErrNoNAT         = errors.New("NAT: no nat traversal configured")
+	ErrUnknownNAT    = errors.New("NAT: unknown nat mode")
+	ErrNoExternalIP  = errors.New("NAT: unable to determine external ip")
+	ErrPortNotMapped = errors.New("NAT: port is not mapped")
+	ErrPrivateIP     = errors.New("NAT: outbound interface ip is private, set the dispatcher's public ip")
+)
+
+//ranges of addresses that aren't reachable from the internet
+var privateNets = []string{
+	"10.0.0.0/8",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"100.64.0.0/10", // carrier-grade nat
+	"fc00::/7",
+}
+
+//NAT exposes the dispatcher's port through the router it sits behind
+type NAT interface {
+	GetMode() string
+	ExternalIP() (string, error)
+	Forward(port int) error
+	Clear(port int) error
+}
+
+//NewNAT discovers the nat traversal of the given mode
+func NewNAT(mode string) (NAT, error) {
+	switch mode {
+	case config.NATNone:
+		return None{}, nil
+	case config.NATUPnP:
+		igd, err := upnp.Discover()
+		if err != nil {
+			return nil, err
+		}
+		return &UPnP{igd: igd}, nil
+	case config.NATPMP:
+		gw, err := gateway.DiscoverGateway()
+		if err != nil {
+			return nil, err
+		}
+		n := &NATPMP{client: natpmp.NewClient(gw), mu: new(sync.Mutex), renew: make(map[int]chan struct{})}
+		if _, err = n.ExternalIP(); err != nil {
+			return nil, err
+		}
+		return n, nil
+	default:
+		return nil, ErrUnknownNAT
+	}
+}
+
+//DiscoverNAT returns the configured nat traversal, falling back to none when it's unavailable
+func DiscoverNAT(mode string) NAT {
+	nat, err := NewNAT(mode)
+	if err != nil {
+		glg.Warn("NAT: " + mode + " unavailable, port will not be forwarded - " + err.Error())
+		return None{}
+	}
+	glg.Info("NAT: using " + nat.GetMode())
+	return nat
+}
+
+//ResolveIP returns the ip the dispatcher is reachable on, preferring the configured ip then the nat's external ip then the ip of the outbound interface.
+//a private outbound ip is refused if mode is none since nothing forwards to it, it's only warned about if mode's traversal was unavailable
+func ResolveIP(nat NAT, mode, configured string) string {
+	if configured != "" {
+		return configured
+	}
+	ip, err := nat.ExternalIP()
+	if err == nil {
+		return ip
+	}
+	glg.Warn("NAT: " + err.Error() + ", using outbound interface ip")
+	ip, err = outboundIP()
+	if err != nil {
+		glg.Fatal(ErrNoExternalIP)
+	}
+	if privateIP(ip) {
+		if mode == config.NATNone {
+			glg.Fatal(ErrPrivateIP)
+		}
+		glg.Warn("NAT: " + ip + " is private, the dispatcher is likely unreachable - set its public ip")
+	}
+	return ip
+}
+
+//returns true if ip isn't reachable from the internet
+func privateIP(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	if parsed.IsLoopback() || parsed.IsLinkLocalUnicast() {
+		return true
+	}
+	for _, cidr := range privateNets {
+		_, network, err := net.ParseCIDR(cidr)
+		if err == nil && network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+//returns the local ip used for outbound traffic, no packets are sent
+func outboundIP() (string, error) {
+	conn, err := net.Dial("udp", "8.8.8.8:80")
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP.String(), nil
+}
+
+//None is used when the dispatcher is directly reachable or its ports are forwarded manually
+type None struct{}
+
+func (n None) GetMode() string {
+	return config.NATNone
+}
+
+func (n None) ExternalIP() (string, error) {
+	return "", ErrNoNAT
+}
+
+func (n None) Forward(port int) error {
+	return nil
+}
+
+func (n None) Clear(port int) error {
+	return nil
+}
+
+//UPnP forwards ports through an internet gateway device
+type UPnP struct {
+	igd *upnp.IGD
+}
+
+func (u UPnP) GetMode() string {
+	return config.NATUPnP
+}
+
+func (u UPnP) ExternalIP() (string, error) {
+	return u.igd.ExternalIP()
+}
+
+func (u UPnP) Forward(port int) error {
+	return u.igd.Forward(uint16(port), "gizo dispatcher node")
+}
+
+func (u UPnP) Clear(port int) error {
+	return u.igd.Clear(uint16(port))
+}
+
+//NATPMP forwards ports through a nat-pmp gateway, renewing mappings before they expire
+type NATPMP struct {
+	client *natpmp.Client
+	mu     *sync.Mutex
+	renew  map[int]chan struct{} // stops renewal of a mapped port
+}
+
+func (n NATPMP) GetMode() string {
+	return config.NATPMP
+}
+
+func (n NATPMP) ExternalIP() (string, error) {
+	res, err := n.client.GetExternalAddress()
+	if err != nil {
+		return "", err
+	}
+	return net.IP(res.ExternalIPAddress[:]).String(), nil
+}
+
+func (n *NATPMP) Forward(port int) error {
+	if _, err := n.client.AddPortMapping("tcp", port, port, int(NATPMPLifetime.Seconds())); err != nil {
+		return err
+	}
+	stop := make(chan struct{})
+	n.mu.Lock()
+	n.renew[port] = stop
+	n.mu.Unlock()
+	go func() {
+		ticker := time.NewTicker(NATPMPLifetime / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := n.client.AddPortMapping("tcp", port, port, int(NATPMPLifetime.Seconds())); err != nil {
+					glg.Warn("NAT: unable to renew nat-pmp mapping - " + err.Error())
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (n *NATPMP) Clear(port int) error {
+	n.mu.Lock()
+	stop, ok := n.renew[port]
+	delete(n.renew, port)
+	n.mu.Unlock()
+	if !ok {
+		return ErrPortNotMapped
+	}
+	close(stop)
+	_, err := n.client.AddPortMapping("tcp", port, 0, 0)
+	return err
+}
@@ -0,0 +1,16 @@
+package p2p
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrivateIP(t *testing.T) {
+	for _, ip := range []string{"10.1.2.3", "172.20.0.1", "192.168.1.10", "100.64.0.1", "127.0.0.1", "169.254.0.1", "fd00::1"} {
+		assert.True(t, privateIP(ip), ip)
+	}
+	for _, ip := range []string{"8.8.8.8", "172.32.0.1", "2001:4860:4860::8888", "not an ip"} {
+		assert.False(t, privateIP(ip), ip)
+	}
+}
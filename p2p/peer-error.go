@@ -0,0 +1,77 @@
+package p2p
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/kpango/glg"
+)
+
+// reason codes sent in ERROR messages
+const (
+	ErrCodeInvalidMessage   = 1 // message couldn't be parsed or wasn't expected
+	ErrCodeUnknownMessage   = 2 // message type isn't supported
+	ErrCodeInvalidSignature = 3
+	ErrCodeConnFull         = 4 // max workers reached
+	ErrCodeVersion          = 5 // protocol version isn't supported
+	ErrCodeHandshake        = 6 // challenge wasn't answered correctly
+	ErrCodeCertificate      = 7 // hello doesn't match the peer's certificate
+	ErrCodeRateLimit        = 8 // peer sent messages faster than allowed, retry_after is set
+)
+
+//PeerError is the payload of an ERROR message
+type PeerError struct {
+	Code       int           `json:"code"`
+	Reason     string        `json:"reason"`
+	RetryAfter time.Duration `json:"retry_after,omitempty"` // time the peer should wait before sending again
+	Nonce      []byte        `json:"nonce,omitempty"`       // nonce of the rate limited message so the peer can resend it
+}
+
+func NewPeerError(code int, reason string) PeerError {
+	return PeerError{Code: code, Reason: reason}
+}
+
+func (e PeerError) GetCode() int {
+	return e.Code
+}
+
+func (e PeerError) GetReason() string {
+	return e.Reason
+}
+
+func (e PeerError) GetRetryAfter() time.Duration {
+	return e.RetryAfter
+}
+
+func (e PeerError) GetNonce() []byte {
+	return e.Nonce
+}
+
+func (e PeerError) Error() string {
+	return "P2P: peer error " + strconv.Itoa(e.GetCode()) + " - " + e.GetReason()
+}
+
+func (e PeerError) Serialize() []byte {
+	bytes, err := json.Marshal(e)
+	if err != nil {
+		glg.Fatal(err)
+	}
+	return bytes
+}
+
+func DeserializePeerError(b []byte) (PeerError, error) {
+	var temp PeerError
+	err := json.Unmarshal(b, &temp)
+	return temp, err
+}
+
+//logs the error carried by an ERROR message
+func logPeerError(prefix string, m PeerMessage) {
+	peerErr, err := DeserializePeerError(m.GetPayload())
+	if err != nil {
+		glg.Warn(prefix + " - unreadable error")
+		return
+	}
+	glg.Warn(prefix + " - " + peerErr.Error())
+}
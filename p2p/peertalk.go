@@ -1,31 +1,66 @@
 package p2p
 
+import "time"
+
 const (
 	HELLO               = "HELLO"
 	INVALIDMESSAGE      = "INVALIDMESSAGE" // invalid message
 	CONNFULL            = "CONNFULL"       // max workers reached
 	JOB                 = "JOB"
-	INVALIDSIGNATURE    = "JOB"
+	INVALIDSIGNATURE    = "INVALIDSIGNATURE"
+	ERROR               = "ERROR" // error with a reason code
 	RESULT              = "RESULT"
 	SHUT                = "SHUT"
 	SHUTACK             = "SHUTACK"
 	BLOCK               = "BLOCK"
-	BLOCKREQ            = "BLOCKREQ"
-	BLOCKRES            = "BLOCKRES"
+	BLOCKREQ            = "BLOCKREQ"   // request for a batch of blocks by hash
+	BLOCKRES            = "BLOCKRES"   // batch of requested blocks
+	HEADERSREQ          = "HEADERSREQ" // request for the headers following a block locator
+	HEADERS             = "HEADERS"    // headers following a block locator
+	CAPACITY            = "CAPACITY"   // idle workers and queued execs of a dispatcher
+	FORWARD             = "FORWARD"    // exec forwarded to a neighbour with idle workers
+	FORWARDRES          = "FORWARDRES" // result of a forwarded exec
+	HANDOFF             = "HANDOFF"    // queued execs handed off by a draining dispatcher
+	HANDOFFACK          = "HANDOFFACK" // ids of the handed off execs queued by the neighbour
 	NEIGHBOURCONNECT    = "NEIGHBOURCONNECT"
 	NEIGHBOURDISCONNECT = "NEIGHBOURDISCONNECT"
+	PING                = "PING"      // heartbeat sent by dispatcher to worker
+	PONG                = "PONG"      // heartbeat reply sent by worker to dispatcher
+	PEERREQ             = "PEERREQ"   // request for known dispatcher addresses
+	PEERS               = "PEERS"     // known dispatcher addresses
+	AUTH                = "AUTH"      // response to the challenge in a hello
+	WORKFLOWS           = "WORKFLOWS" // request uri of a client listing or watching its workflows
 )
 
 func HelloMessage(payload []byte) []byte {
 	return NewPeerMessage(HELLO, payload, nil).Serialize()
 }
 
+func AuthMessage(payload []byte) []byte {
+	return NewPeerMessage(AUTH, payload, nil).Serialize()
+}
+
+func ErrorMessage(code int, reason string) []byte {
+	return NewPeerMessage(ERROR, NewPeerError(code, reason).Serialize(), nil).Serialize()
+}
+
 func InvalidMessage() []byte {
-	return NewPeerMessage(INVALIDMESSAGE, nil, nil).Serialize()
+	return ErrorMessage(ErrCodeInvalidMessage, "invalid message")
+}
+
+func UnknownMessage(message string) []byte {
+	return ErrorMessage(ErrCodeUnknownMessage, "unknown message "+message)
+}
+
+func RateLimitMessage(wait time.Duration, nonce []byte) []byte {
+	peerErr := NewPeerError(ErrCodeRateLimit, "rate limited, retry after "+wait.String())
+	peerErr.RetryAfter = wait
+	peerErr.Nonce = nonce
+	return NewPeerMessage(ERROR, peerErr.Serialize(), nil).Serialize()
 }
 
 func ConnFullMessage() []byte {
-	return NewPeerMessage(CONNFULL, nil, nil).Serialize()
+	return ErrorMessage(ErrCodeConnFull, "max workers reached")
 }
 
 func JobMessage(payload, priv []byte) []byte {
@@ -33,7 +68,7 @@ func JobMessage(payload, priv []byte) []byte {
 }
 
 func InvalidSignature() []byte {
-	return NewPeerMessage(INVALIDMESSAGE, nil, nil).Serialize()
+	return ErrorMessage(ErrCodeInvalidSignature, "invalid signature")
 }
 
 func ResultMessage(payload, priv []byte) []byte {
@@ -60,6 +95,38 @@ func BlockResMessage(payload, priv []byte) []byte {
 	return NewPeerMessage(BLOCKRES, payload, priv).Serialize()
 }
 
+func HeadersReqMessage(payload, priv []byte) []byte {
+	return NewPeerMessage(HEADERSREQ, payload, priv).Serialize()
+}
+
+func HeadersMessage(payload, priv []byte) []byte {
+	return NewPeerMessage(HEADERS, payload, priv).Serialize()
+}
+
+func CapacityMessage(payload, priv []byte) []byte {
+	return NewPeerMessage(CAPACITY, payload, priv).Serialize()
+}
+
+func ForwardMessage(payload, priv []byte) []byte {
+	return NewPeerMessage(FORWARD, payload, priv).Serialize()
+}
+
+func ForwardResMessage(payload, priv []byte) []byte {
+	return NewPeerMessage(FORWARDRES, payload, priv).Serialize()
+}
+
+func HandoffMessage(payload, priv []byte) []byte {
+	return NewPeerMessage(HANDOFF, payload, priv).Serialize()
+}
+
+func HandoffAckMessage(payload, priv []byte) []byte {
+	return NewPeerMessage(HANDOFFACK, payload, priv).Serialize()
+}
+
+func WorkflowsMessage(payload, priv []byte) []byte {
+	return NewPeerMessage(WORKFLOWS, payload, priv).Serialize()
+}
+
 func NeighbourConnectMessage(payload, priv []byte) []byte {
 	return NewPeerMessage(NEIGHBOURCONNECT, payload, priv).Serialize()
 }
@@ -67,3 +134,19 @@ func NeighbourConnectMessage(payload, priv []byte) []byte {
 func NeighbourDisconnectMessage(payload, priv []byte) []byte {
 	return NewPeerMessage(NEIGHBOURDISCONNECT, payload, priv).Serialize()
 }
+
+func PingMessage(priv []byte) []byte {
+	return NewPeerMessage(PING, nil, priv).Serialize()
+}
+
+func PongMessage(priv []byte) []byte {
+	return NewPeerMessage(PONG, nil, priv).Serialize()
+}
+
+func PeerReqMessage(priv []byte) []byte {
+	return NewPeerMessage(PEERREQ, nil, priv).Serialize()
+}
+
+func PeersMessage(payload, priv []byte) []byte {
+	return NewPeerMessage(PEERS, payload, priv).Serialize()
+}
@@ -0,0 +1,132 @@
+package p2p
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+//unlimitedMessages answer the dispatcher, dropping them would leave execs, heartbeats or handshakes waiting
+var unlimitedMessages = map[string]bool{RESULT: true, PONG: true, AUTH: true, FORWARDRES: true}
+
+//token bucket of a remote ip or pub key
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+//RateLimiter holds token buckets keyed by remote ip and pub key
+type RateLimiter struct {
+	mu      *sync.Mutex
+	rate    float64 // tokens added per second, 0 disables the limiter
+	burst   float64 // size of a bucket
+	buckets map[string]*bucket
+}
+
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		mu:      new(sync.Mutex),
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+	}
+}
+
+//Allow takes a token from the bucket of every key, if one is empty it returns false with the time until it refills
+func (l *RateLimiter) Allow(keys ...string) (bool, time.Duration) {
+	if l.rate <= 0 {
+		return true, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if len(l.buckets) > MaxBuckets {
+		l.prune(now)
+	}
+	var wait time.Duration
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		b := l.refill(key, now)
+		if b.tokens < 1 {
+			if retry := time.Duration((1 - b.tokens) / l.rate * float64(time.Second)); retry > wait {
+				wait = retry
+			}
+		}
+	}
+	if wait != 0 {
+		return false, wait
+	}
+	for _, key := range keys {
+		if key != "" {
+			l.buckets[key].tokens--
+		}
+	}
+	return true, 0
+}
+
+//returns the bucket of key topped up with the tokens added since it was last used
+func (l *RateLimiter) refill(key string, now time.Time) *bucket {
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	return b
+}
+
+//drops buckets that have refilled, they're recreated full
+func (l *RateLimiter) prune(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+//RemoteIP returns the ip a request was sent from
+func RemoteIP(r *http.Request) string {
+	if r == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+//rate limit keys of a request, its remote ip and the pub key of its certificate
+func limitKeys(r *http.Request) []string {
+	keys := []string{"ip:" + RemoteIP(r)}
+	if pub := PeerPub(r); pub != "" {
+		keys = append(keys, "pub:"+pub)
+	}
+	return keys
+}
+
+//RetryAfter returns the value of a Retry-After header in whole seconds
+func RetryAfter(wait time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(wait.Seconds())))
+}
+
+//wraps a handler so requests over the limiter's rate are answered with 429 and a Retry-After header
+func rateLimit(l *RateLimiter, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ok, wait := l.Allow(limitKeys(r)...); !ok {
+			w.Header().Set("Retry-After", RetryAfter(wait))
+			http.Error(w, ErrRateLimited.Error(), http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,55 @@
+package p2p_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gizo-network/gizo/crypt"
+	"github.com/gizo-network/gizo/p2p"
+)
+
+func TestRateLimiterAllow(t *testing.T) {
+	l := p2p.NewRateLimiter(1, 2)
+	ok, _ := l.Allow("ip:10.0.0.1", "pub:a")
+	assert.True(t, ok)
+	ok, _ = l.Allow("ip:10.0.0.1", "pub:a")
+	assert.True(t, ok)
+
+	//! a bucket is empty after its burst, nothing is taken from the others
+	ok, wait := l.Allow("ip:10.0.0.1", "pub:b")
+	assert.False(t, ok)
+	assert.True(t, wait > 0 && wait <= time.Second)
+	ok, _ = l.Allow("pub:b")
+	assert.True(t, ok)
+
+	//! keys are limited separately and empty keys are skipped
+	ok, _ = l.Allow("pub:c", "")
+	assert.True(t, ok)
+
+	time.Sleep(time.Second)
+	ok, _ = l.Allow("ip:10.0.0.1")
+	assert.True(t, ok)
+
+	ok, _ = p2p.NewRateLimiter(0, 0).Allow("ip:10.0.0.1")
+	assert.True(t, ok)
+}
+
+func TestResendBuffer(t *testing.T) {
+	b := p2p.NewResendBuffer()
+	priv, _ := crypt.GenKeys()
+	m := p2p.PongMessage(priv)
+	b.Add(m)
+	pm, err := p2p.DeserializePeerMessage(m)
+	assert.NoError(t, err)
+	sent, ok := b.Get(pm.GetNonce())
+	assert.True(t, ok)
+	assert.Equal(t, m, sent)
+
+	for i := 0; i < p2p.MaxResend; i++ {
+		b.Add(p2p.PongMessage(priv))
+	}
+	_, ok = b.Get(pm.GetNonce())
+	assert.False(t, ok)
+}
@@ -0,0 +1,52 @@
+package p2p
+
+import (
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+//ReplayGuard rejects signed messages that are stale or have been received before
+type ReplayGuard struct {
+	mu        *sync.Mutex
+	seen      map[string]int64 // nonces received within MaxMessageAge and their timestamps
+	lastPrune int64
+}
+
+func NewReplayGuard() *ReplayGuard {
+	return &ReplayGuard{
+		mu:        new(sync.Mutex),
+		seen:      make(map[string]int64),
+		lastPrune: time.Now().Unix(),
+	}
+}
+
+//Check returns true if the message is fresh and its nonce hasn't been seen
+func (r *ReplayGuard) Check(m PeerMessage) bool {
+	now := time.Now().Unix()
+	age := now - m.GetTimestamp()
+	if age > int64(MaxMessageAge.Seconds()) || -age > int64(MaxMessageAge.Seconds()) || len(m.GetNonce()) == 0 {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if now-r.lastPrune > int64(MaxMessageAge.Seconds()) {
+		r.prune(now)
+	}
+	nonce := hex.EncodeToString(m.GetNonce())
+	if _, ok := r.seen[nonce]; ok {
+		return false
+	}
+	r.seen[nonce] = m.GetTimestamp()
+	return true
+}
+
+//forgets nonces of messages that would be rejected as stale
+func (r *ReplayGuard) prune(now int64) {
+	for nonce, timestamp := range r.seen {
+		if now-timestamp > int64(MaxMessageAge.Seconds()) {
+			delete(r.seen, nonce)
+		}
+	}
+	r.lastPrune = now
+}
@@ -0,0 +1,34 @@
+package p2p_test
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gizo-network/gizo/crypt"
+	"github.com/gizo-network/gizo/p2p"
+)
+
+func TestReplayGuard(t *testing.T) {
+	priv, pub := crypt.GenKeys()
+	guard := p2p.NewReplayGuard()
+
+	m := p2p.NewPeerMessage(p2p.PONG, nil, priv)
+	assert.True(t, m.VerifySignature(hex.EncodeToString(pub)))
+	assert.True(t, guard.Check(m))
+	assert.False(t, guard.Check(m)) //! replayed
+	assert.True(t, guard.Check(p2p.NewPeerMessage(p2p.PONG, nil, priv)))
+
+	unsigned := p2p.NewPeerMessage(p2p.PONG, nil, nil)
+	assert.False(t, guard.Check(unsigned))
+
+	stale := p2p.NewPeerMessage(p2p.PONG, nil, priv)
+	stale.Timestamp = time.Now().Add(-p2p.MaxMessageAge * 2).Unix()
+	assert.False(t, guard.Check(stale))
+
+	future := p2p.NewPeerMessage(p2p.PONG, nil, priv)
+	future.Timestamp = time.Now().Add(p2p.MaxMessageAge * 2).Unix()
+	assert.False(t, guard.Check(future))
+}
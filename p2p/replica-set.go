@@ -0,0 +1,92 @@
+package p2p
+
+import (
+	"encoding/hex"
+
+	"github.com/gizo-network/gizo/job"
+	"github.com/gizo-network/gizo/job/queue/qItem"
+	funk "github.com/thoas/go-funk"
+)
+
+//ReplicaSet tracks the workers an exec has been replicated to and the results they returned
+type ReplicaSet struct {
+	item    qItem.Item
+	pending int                 // number of replicas yet to be dispatched
+	workers []string            // workers the exec has been dispatched to
+	results map[string]job.Exec // results received keyed by worker pub
+}
+
+func NewReplicaSet(item qItem.Item) *ReplicaSet {
+	return &ReplicaSet{
+		item:    item,
+		pending: item.GetExec().GetReplicas(),
+		results: make(map[string]job.Exec),
+	}
+}
+
+func (r ReplicaSet) GetItem() qItem.Item {
+	return r.item
+}
+
+func (r ReplicaSet) GetPending() int {
+	return r.pending
+}
+
+func (r *ReplicaSet) IncrPending() {
+	r.pending++
+}
+
+func (r ReplicaSet) GetWorkers() []string {
+	return r.workers
+}
+
+//Assigned returns true if the exec has been dispatched to the worker
+func (r ReplicaSet) Assigned(pub string) bool {
+	return funk.ContainsString(r.GetWorkers(), pub)
+}
+
+//Assign records a dispatch of the exec to a worker
+func (r *ReplicaSet) Assign(pub string) {
+	r.workers = append(r.workers, pub)
+	r.pending--
+}
+
+func (r ReplicaSet) GetResults() map[string]job.Exec {
+	return r.results
+}
+
+func (r *ReplicaSet) AddResult(pub string, exec job.Exec) {
+	r.results[pub] = exec
+}
+
+//Done returns true if every replica has returned a result
+func (r ReplicaSet) Done() bool {
+	return len(r.GetResults()) >= r.GetItem().GetExec().GetReplicas()
+}
+
+//Majority returns the exec agreed on by more than half of the replicas, the workers that agreed and the workers that disagreed
+func (r ReplicaSet) Majority() (*job.Exec, []string, []string) {
+	tally := make(map[string][]string)
+	for pub, exec := range r.GetResults() {
+		hash := hex.EncodeToString(exec.GetResultHash())
+		tally[hash] = append(tally[hash], pub)
+	}
+	var agreed []string
+	for _, pubs := range tally {
+		if len(pubs) > len(agreed) {
+			agreed = pubs
+		}
+	}
+	if len(agreed)*2 <= r.GetItem().GetExec().GetReplicas() {
+		return nil, nil, r.GetWorkers()
+	}
+	var disagreed []string
+	for pub := range r.GetResults() {
+		if !funk.ContainsString(agreed, pub) {
+			disagreed = append(disagreed, pub)
+		}
+	}
+	chosen := r.GetResults()[agreed[0]]
+	chosen.SetVerifiers(agreed)
+	return &chosen, agreed, disagreed
+}
@@ -0,0 +1,63 @@
+package p2p_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gizo-network/gizo/job"
+	"github.com/gizo-network/gizo/job/queue/qItem"
+	"github.com/gizo-network/gizo/p2p"
+)
+
+func replicaSet(t *testing.T, replicas int) *p2p.ReplicaSet {
+	exec, err := job.NewExec([]interface{}{}, 0, job.NORMAL, 0, 0, 0, 0, "", job.NewEnvVariables(), "")
+	assert.NoError(t, err)
+	assert.NoError(t, exec.SetReplicas(replicas))
+	return p2p.NewReplicaSet(qItem.NewItem(job.Job{}, exec, nil, nil))
+}
+
+func result(by string, result interface{}) job.Exec {
+	exec := job.Exec{By: by}
+	exec.SetResult(result)
+	return exec
+}
+
+func TestReplicaSetMajority(t *testing.T) {
+	rs := replicaSet(t, 3)
+	for _, pub := range []string{"a", "b", "c"} {
+		assert.False(t, rs.Assigned(pub))
+		rs.Assign(pub)
+	}
+	assert.Equal(t, 0, rs.GetPending())
+	rs.AddResult("a", result("a", 1))
+	rs.AddResult("b", result("b", 2))
+	assert.False(t, rs.Done())
+	rs.AddResult("c", result("c", 1))
+	assert.True(t, rs.Done())
+
+	chosen, agreed, disagreed := rs.Majority()
+	assert.NotNil(t, chosen)
+	assert.Equal(t, 1, chosen.GetResult())
+	assert.ElementsMatch(t, []string{"a", "c"}, agreed)
+	assert.Equal(t, []string{"b"}, disagreed)
+	assert.ElementsMatch(t, agreed, chosen.GetVerifiers())
+
+	//! verifiers are covered by the exec hash
+	unverified := *chosen
+	unverified.SetVerifiers(nil)
+	assert.NotEqual(t, chosen.GetHash(), unverified.GetHash())
+}
+
+func TestReplicaSetNoMajority(t *testing.T) {
+	rs := replicaSet(t, 2)
+	rs.Assign("a")
+	rs.Assign("b")
+	rs.AddResult("a", result("a", 1))
+	rs.AddResult("b", result("b", 2))
+
+	chosen, agreed, disagreed := rs.Majority()
+	assert.Nil(t, chosen)
+	assert.Nil(t, agreed)
+	assert.ElementsMatch(t, []string{"a", "b"}, disagreed)
+}
@@ -0,0 +1,48 @@
+package p2p
+
+import (
+	"encoding/hex"
+	"sync"
+)
+
+//ResendBuffer holds the latest messages sent to a peer keyed by nonce so rate limited ones can be sent again,
+//unsigned messages share the empty nonce and only the latest one is kept
+type ResendBuffer struct {
+	mu    *sync.Mutex
+	order []string // nonces, oldest first
+	sent  map[string][]byte
+}
+
+func NewResendBuffer() *ResendBuffer {
+	return &ResendBuffer{
+		mu:   new(sync.Mutex),
+		sent: make(map[string][]byte),
+	}
+}
+
+//Add keeps a sent message, dropping the oldest one past MaxResend
+func (b *ResendBuffer) Add(message []byte) {
+	m, err := DeserializePeerMessage(message)
+	if err != nil {
+		return
+	}
+	nonce := hex.EncodeToString(m.GetNonce())
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.sent[nonce]; !ok {
+		b.order = append(b.order, nonce)
+	}
+	b.sent[nonce] = message
+	if len(b.order) > MaxResend {
+		delete(b.sent, b.order[0])
+		b.order = b.order[1:]
+	}
+}
+
+//Get returns the message sent with the nonce
+func (b *ResendBuffer) Get(nonce []byte) ([]byte, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	m, ok := b.sent[hex.EncodeToString(nonce)]
+	return m, ok
+}
@@ -0,0 +1,46 @@
+package p2p
+
+//Static discovers dispatchers from a fixed bootstrap list, announcements are no-ops
+type Static struct {
+	dispatchers []string
+	token       string
+}
+
+func NewStatic(dispatchers []string) *Static {
+	return &Static{dispatchers: dispatchers}
+}
+
+func (s Static) GetToken() string {
+	return s.token
+}
+
+func (s *Static) SetToken(token string) {
+	s.token = token
+}
+
+func (s Static) GetDispatchers() ([]string, error) {
+	if len(s.dispatchers) == 0 {
+		return nil, ErrNoDispatchers
+	}
+	return s.dispatchers, nil
+}
+
+func (s *Static) Register(priv []byte, pub, ip string, port int) error {
+	return nil
+}
+
+func (s Static) ConnectWorker() error {
+	return nil
+}
+
+func (s Static) DisconnectWorker() error {
+	return nil
+}
+
+func (s Static) Wake() error {
+	return nil
+}
+
+func (s Static) Sleep() error {
+	return nil
+}
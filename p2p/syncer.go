@@ -0,0 +1,425 @@
+package p2p
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gizo-network/gizo/core"
+	"github.com/gizo-network/gizo/metrics"
+	"github.com/gorilla/websocket"
+	"github.com/kpango/glg"
+	melody "gopkg.in/olahol/melody.v1"
+)
+
+//SyncProgress is a snapshot of the chain sync
+type SyncProgress struct {
+	Syncing  bool   `json:"syncing"`
+	Height   uint64 `json:"height"`   // height of the latest applied block
+	Target   uint64 `json:"target"`   // height of the latest known header
+	Pending  int    `json:"pending"`  // headers whose blocks have yet to be applied
+	Inflight int    `json:"inflight"` // blocks requested from peers
+	Peers    int    `json:"peers"`
+}
+
+//a peer blocks can be downloaded from
+type syncPeer struct {
+	height   uint64         // height of the latest header announced by the peer
+	requests []*syncRequest // unanswered batches, oldest first
+}
+
+//a batch of blocks requested from a peer
+type syncRequest struct {
+	hashes []string
+	sent   int64
+}
+
+//Syncer downloads the blockchain from neighbours headers first, spreads block downloads across peers in batches and applies blocks in order
+type Syncer struct {
+	bc       *core.BlockChain
+	priv     []byte
+	headers  []core.BlockInfo       // headers waiting for their block to be applied, oldest first
+	index    map[string]int         // position of a header in headers
+	blocks   map[string]*core.Block // downloaded blocks waiting for their parent to be applied
+	inflight map[string]bool        // blocks requested from a peer
+	peers    map[interface{}]*syncPeer
+	applied  int // position of the next header to apply
+	mu       *sync.Mutex
+}
+
+func NewSyncer(bc *core.BlockChain, priv []byte) *Syncer {
+	return &Syncer{
+		bc:       bc,
+		priv:     priv,
+		index:    make(map[string]int),
+		blocks:   make(map[string]*core.Block),
+		inflight: make(map[string]bool),
+		peers:    make(map[interface{}]*syncPeer),
+		mu:       new(sync.Mutex),
+	}
+}
+
+//connLocks holds a write lock per dialed neighbour, *websocket.Conn doesn't support concurrent writers
+var connLocks sync.Map
+
+//writes a message to a neighbour
+func writePeer(peer interface{}, m []byte) {
+	switch n := peer.(type) {
+	case *melody.Session:
+		n.Write(m)
+		break
+	case *websocket.Conn:
+		lock, _ := connLocks.LoadOrStore(n, new(sync.Mutex))
+		lock.(*sync.Mutex).Lock()
+		n.WriteMessage(websocket.BinaryMessage, m)
+		lock.(*sync.Mutex).Unlock()
+		break
+	}
+}
+
+//drops the write lock of a disconnected neighbour
+func releasePeer(peer interface{}) {
+	connLocks.Delete(peer)
+}
+
+//AddPeer starts syncing with a neighbour by requesting the headers following the local chain
+func (s *Syncer) AddPeer(peer interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.peers[peer]; !ok {
+		s.peers[peer] = &syncPeer{}
+	}
+	s.requestHeaders(peer, s.locator())
+}
+
+//RequestHeaders asks a neighbour for headers, used when it announces a block whose parent is unknown
+func (s *Syncer) RequestHeaders(peer interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.peers[peer]; ok {
+		s.requestHeaders(peer, s.locator())
+	}
+}
+
+//RemovePeer releases the batches requested from a disconnected neighbour so they're requested from other peers
+func (s *Syncer) RemovePeer(peer interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.peers[peer]
+	if !ok {
+		return
+	}
+	for _, req := range p.requests {
+		s.release(req)
+	}
+	delete(s.peers, peer)
+	s.schedule()
+}
+
+//GetProgress returns a snapshot of the chain sync
+func (s *Syncer) GetProgress() SyncProgress {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	height := s.bc.GetLatestHeight()
+	target := height
+	if len(s.headers) != 0 && s.headers[len(s.headers)-1].GetHeight() > target {
+		target = s.headers[len(s.headers)-1].GetHeight()
+	}
+	return SyncProgress{
+		Syncing:  s.applied < len(s.headers),
+		Height:   height,
+		Target:   target,
+		Pending:  len(s.headers) - s.applied,
+		Inflight: len(s.inflight),
+		Peers:    len(s.peers),
+	}
+}
+
+//returns a locator of the latest queued header followed by the local chain
+func (s *Syncer) locator() [][]byte {
+	locator := s.bc.GetLocator()
+	if len(s.headers) != 0 {
+		locator = append([][]byte{s.headers[len(s.headers)-1].GetHeader().GetHash()}, locator...)
+	}
+	return locator
+}
+
+func (s *Syncer) requestHeaders(peer interface{}, locator [][]byte) {
+	payload, err := json.Marshal(locator)
+	if err != nil {
+		glg.Fatal(err)
+	}
+	writePeer(peer, HeadersReqMessage(payload, s.priv))
+}
+
+//Handle processes a chain sync message from a neighbour
+func (s *Syncer) Handle(peer interface{}, m PeerMessage) {
+	switch m.GetMessage() {
+	case HEADERSREQ:
+		s.handleHeadersReq(peer, m.GetPayload())
+		break
+	case HEADERS:
+		s.handleHeaders(peer, m.GetPayload())
+		break
+	case BLOCKREQ:
+		s.handleBlockReq(peer, m.GetPayload())
+		break
+	case BLOCKRES:
+		s.handleBlockRes(peer, m.GetPayload())
+		break
+	}
+}
+
+//HandleLegacy processes a chain sync message from a neighbour without header sync, which requests a single block per BLOCKREQ
+func (s *Syncer) HandleLegacy(peer interface{}, m PeerMessage) {
+	if m.GetMessage() != BLOCKREQ {
+		return
+	}
+	blockinfo, err := s.bc.GetBlockInfo(m.GetPayload())
+	if err != nil {
+		return
+	}
+	writePeer(peer, BlockResMessage(blockinfo.GetBlock().Serialize(), s.priv))
+}
+
+//handleHeadersReq answers a HEADERSREQ with the headers following the latest locator hash in the local chain
+func (s *Syncer) handleHeadersReq(peer interface{}, payload []byte) {
+	var locator [][]byte
+	if err := json.Unmarshal(payload, &locator); err != nil {
+		writePeer(peer, InvalidMessage())
+		return
+	}
+	headers, err := json.Marshal(s.bc.GetBlockInfosAfter(locator, MaxHeaders))
+	if err != nil {
+		glg.Fatal(err)
+	}
+	writePeer(peer, HeadersMessage(headers, s.priv))
+}
+
+//handleHeaders queues headers that extend the local chain or the queued headers and schedules their download
+func (s *Syncer) handleHeaders(peer interface{}, payload []byte) {
+	var headers []core.BlockInfo
+	if err := json.Unmarshal(payload, &headers); err != nil {
+		writePeer(peer, InvalidMessage())
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.peers[peer]
+	if !ok {
+		return
+	}
+	for _, header := range headers {
+		hash := header.GetHeader().GetHash()
+		if _, queued := s.index[hex.EncodeToString(hash)]; !queued && !s.bc.HasBlock(hash) {
+			if !s.connects(header) {
+				glg.Warn("Dispatcher: received headers that don't connect to the chain")
+				break
+			}
+			s.index[hex.EncodeToString(hash)] = len(s.headers)
+			s.headers = append(s.headers, header)
+		}
+		if header.GetHeight() > p.height {
+			p.height = header.GetHeight()
+		}
+	}
+	if len(headers) == MaxHeaders {
+		//! peer has more headers
+		s.requestHeaders(peer, [][]byte{headers[len(headers)-1].GetHeader().GetHash()})
+	}
+	if s.applied < len(s.headers) {
+		glg.Info(fmt.Sprintf("Dispatcher: syncing %v headers", len(s.headers)-s.applied))
+	}
+	s.schedule()
+}
+
+//returns true if the header follows the latest queued header or, with none queued, a block in the local chain
+func (s *Syncer) connects(header core.BlockInfo) bool {
+	prev := header.GetHeader().GetPrevBlockHash()
+	if len(s.headers) != 0 {
+		last := s.headers[len(s.headers)-1]
+		return bytes.Equal(prev, last.GetHeader().GetHash()) && header.GetHeight() == last.GetHeight()+1
+	}
+	return header.GetHeight() == 0 || s.bc.HasBlock(prev)
+}
+
+//handleBlockReq answers a BLOCKREQ with the requested blocks found in the local chain
+func (s *Syncer) handleBlockReq(peer interface{}, payload []byte) {
+	var hashes [][]byte
+	if err := json.Unmarshal(payload, &hashes); err != nil || len(hashes) > SyncBatchSize {
+		writePeer(peer, InvalidMessage())
+		return
+	}
+	var blocks [][]byte
+	for _, hash := range hashes {
+		blockinfo, err := s.bc.GetBlockInfo(hash)
+		if err == nil {
+			blocks = append(blocks, blockinfo.GetBlock().Serialize())
+		}
+	}
+	res, err := json.Marshal(blocks)
+	if err != nil {
+		glg.Fatal(err)
+	}
+	writePeer(peer, BlockResMessage(res, s.priv))
+}
+
+//handleBlockRes stores the blocks of the peer's oldest batch, applies those whose parent is in the chain and schedules more downloads
+func (s *Syncer) handleBlockRes(peer interface{}, payload []byte) {
+	var blocks [][]byte
+	if err := json.Unmarshal(payload, &blocks); err != nil {
+		writePeer(peer, InvalidMessage())
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.peers[peer]
+	if !ok {
+		return
+	}
+	if len(p.requests) != 0 {
+		//! peers answer batches in order, blocks missing from the response are requested again
+		s.release(p.requests[0])
+		p.requests = p.requests[1:]
+	}
+	for _, blockBytes := range blocks {
+		b, err := core.DeserializeBlock(blockBytes)
+		if err != nil {
+			glg.Warn("Dispatcher: unable to deserialize synced block")
+			continue
+		}
+		hash := hex.EncodeToString(b.GetHeader().GetHash())
+		i, queued := s.index[hash]
+		if !queued || i < s.applied || !bytes.Equal(s.headers[i].GetHeader().GetHash(), b.GetHeader().GetHash()) {
+			continue
+		}
+		if !b.VerifyBlock() {
+			glg.Warn("Dispatcher: received unverified block - " + hash)
+			continue
+		}
+		s.blocks[hash] = b
+	}
+	s.apply()
+	s.schedule()
+}
+
+//frees the hashes of a batch so they can be requested again
+func (s *Syncer) release(req *syncRequest) {
+	for _, hash := range req.hashes {
+		delete(s.inflight, hash)
+	}
+}
+
+//adds downloaded blocks to the blockchain in order
+func (s *Syncer) apply() {
+	start := s.applied
+	for s.applied < len(s.headers) {
+		hash := hex.EncodeToString(s.headers[s.applied].GetHeader().GetHash())
+		b, ok := s.blocks[hash]
+		if !ok {
+			break
+		}
+		if err := b.Export(); err != nil {
+			glg.Fatal(err)
+		}
+		if err := s.bc.AddBlock(b); err != nil {
+			glg.Warn("Dispatcher: unable to add synced block - " + err.Error())
+		}
+		delete(s.blocks, hash)
+		s.applied++
+	}
+	if s.applied == start {
+		return
+	}
+	target := s.headers[len(s.headers)-1].GetHeight()
+	height := s.headers[s.applied-1].GetHeight()
+	metrics.SyncLag.Set(float64(target - height))
+	glg.Info(fmt.Sprintf("Dispatcher: synced %v of %v blocks", height, target))
+	if s.applied == len(s.headers) {
+		glg.Info("Dispatcher: sync complete")
+		s.headers = nil
+		s.index = make(map[string]int)
+		s.applied = 0
+	}
+}
+
+//requests batches of missing blocks within the sync window from the least busy peers that have them
+func (s *Syncer) schedule() {
+	var batch []string
+	var height uint64
+	end := s.applied + SyncWindow
+	if end > len(s.headers) {
+		end = len(s.headers)
+	}
+	for i := s.applied; i < end; i++ {
+		hash := hex.EncodeToString(s.headers[i].GetHeader().GetHash())
+		if _, ok := s.blocks[hash]; ok || s.inflight[hash] {
+			continue
+		}
+		batch = append(batch, hash)
+		height = s.headers[i].GetHeight()
+		if len(batch) == SyncBatchSize {
+			if !s.request(batch, height) {
+				return
+			}
+			batch = nil
+		}
+	}
+	if len(batch) != 0 {
+		s.request(batch, height)
+	}
+}
+
+//sends a batch to the least busy peer with the blocks, returns false if all peers are busy
+func (s *Syncer) request(batch []string, height uint64) bool {
+	var peer interface{}
+	var best *syncPeer
+	for n, p := range s.peers {
+		if p.height >= height && len(p.requests) < MaxSyncInflight && (best == nil || len(p.requests) < len(best.requests)) {
+			peer = n
+			best = p
+		}
+	}
+	if best == nil {
+		return false
+	}
+	var hashes [][]byte
+	for _, hash := range batch {
+		hashBytes, err := hex.DecodeString(hash)
+		if err != nil {
+			glg.Fatal(err)
+		}
+		hashes = append(hashes, hashBytes)
+		s.inflight[hash] = true
+	}
+	payload, err := json.Marshal(hashes)
+	if err != nil {
+		glg.Fatal(err)
+	}
+	best.requests = append(best.requests, &syncRequest{hashes: batch, sent: time.Now().Unix()})
+	writePeer(peer, BlockReqMessage(payload, s.priv))
+	return true
+}
+
+//periodically requests batches that weren't answered in time from other peers
+func (s *Syncer) watch() {
+	ticker := time.NewTicker(SyncTimeout / 2)
+	for range ticker.C {
+		s.mu.Lock()
+		for _, p := range s.peers {
+			if len(p.requests) != 0 && time.Now().Unix()-p.requests[0].sent > int64(SyncTimeout.Seconds()) {
+				glg.Warn("Dispatcher: sync peer timed out, requesting blocks from other peers")
+				for _, req := range p.requests {
+					s.release(req)
+				}
+				p.requests = nil
+				p.height = 0 //! not used for downloads until it announces headers again
+			}
+		}
+		s.schedule()
+		s.mu.Unlock()
+	}
+}
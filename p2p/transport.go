@@ -0,0 +1,160 @@
+package p2p
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gizo-network/gizo/config"
+	"github.com/gizo-network/gizo/crypt"
+)
+
+var (
+	ErrNoPeerCert      = errors.New("Transport: peer did not present a certificate")
+	ErrPeerKeyMismatch = errors.New("Transport: peer certificate is bound to a different node")
+	ErrInvalidCA       = errors.New("Transport: unable to read ca certificates")
+)
+
+//Transport holds the tls settings nodes are served and dialed with
+type Transport struct {
+	enabled bool
+	mutual  bool
+	cert    tls.Certificate
+	roots   *x509.CertPool // nil if peers are verified by their node key binding
+}
+
+func NewTransport(cfg config.TLSConfig, priv, pub []byte) (*Transport, error) {
+	t := &Transport{enabled: cfg.Enabled, mutual: cfg.Mutual}
+	if !cfg.Enabled {
+		return t, nil
+	}
+	var err error
+	if cfg.CertFile != "" {
+		t.cert, err = tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	} else {
+		t.cert, err = crypt.NewNodeCertificate(priv, pub)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cfg.CAFile != "" {
+		pem, err := ioutil.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		t.roots = x509.NewCertPool()
+		if !t.roots.AppendCertsFromPEM(pem) {
+			return nil, ErrInvalidCA
+		}
+	}
+	return t, nil
+}
+
+func (t Transport) GetEnabled() bool {
+	return t.enabled
+}
+
+func (t Transport) GetMutual() bool {
+	return t.mutual
+}
+
+//WSScheme returns the scheme nodes are dialed with
+func (t Transport) WSScheme() string {
+	if t.GetEnabled() {
+		return "wss"
+	}
+	return "ws"
+}
+
+//HTTPScheme returns the scheme http endpoints of nodes are requested with
+func (t Transport) HTTPScheme() string {
+	if t.GetEnabled() {
+		return "https"
+	}
+	return "http"
+}
+
+//returns a verifier accepting certificates bound to a node key, restricted to expected if it isn't empty
+func verifyNodeCert(expected string) func([][]byte, [][]*x509.Certificate) error {
+	return func(raw [][]byte, chains [][]*x509.Certificate) error {
+		if len(raw) == 0 {
+			return ErrNoPeerCert
+		}
+		cert, err := x509.ParseCertificate(raw[0])
+		if err != nil {
+			return err
+		}
+		pub, err := crypt.NodeKey(cert)
+		if err != nil {
+			return err
+		}
+		if expected != "" && hex.EncodeToString(pub) != expected {
+			return ErrPeerKeyMismatch
+		}
+		return nil
+	}
+}
+
+//ServerConfig returns the tls config the dispatcher serves with, client certificates are requested and verified if given
+func (t Transport) ServerConfig() *tls.Config {
+	c := &tls.Config{
+		Certificates: []tls.Certificate{t.cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if t.roots != nil {
+		c.ClientCAs = t.roots
+		c.ClientAuth = tls.VerifyClientCertIfGiven
+	} else {
+		c.ClientAuth = tls.RequestClientCert
+		c.VerifyPeerCertificate = func(raw [][]byte, chains [][]*x509.Certificate) error {
+			if len(raw) == 0 {
+				return nil
+			}
+			return verifyNodeCert("")(raw, chains)
+		}
+	}
+	return c
+}
+
+//ClientConfig returns the tls config used to dial the node with the given pub
+func (t Transport) ClientConfig(pub string, host string) *tls.Config {
+	if !t.GetEnabled() {
+		return nil
+	}
+	c := &tls.Config{
+		Certificates: []tls.Certificate{t.cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if t.roots != nil {
+		c.RootCAs = t.roots
+		c.ServerName = host
+	} else {
+		//! self-signed node certificates are verified against the pub in the node's address instead of a ca
+		c.InsecureSkipVerify = true
+		c.VerifyPeerCertificate = verifyNodeCert(pub)
+	}
+	return c
+}
+
+//Authorized returns false if mutual authentication is required and the request has no client certificate
+func (t Transport) Authorized(r *http.Request) bool {
+	if !t.GetEnabled() || !t.GetMutual() {
+		return true
+	}
+	return r.TLS != nil && len(r.TLS.PeerCertificates) != 0
+}
+
+//PeerPub returns the node key bound to the client certificate of a request, empty if there's none
+func PeerPub(r *http.Request) string {
+	if r == nil || r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return ""
+	}
+	pub, err := crypt.NodeKey(r.TLS.PeerCertificates[0])
+	if err != nil {
+		return ""
+	}
+	return hex.EncodeToString(pub)
+}
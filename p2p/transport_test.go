@@ -0,0 +1,142 @@
+package p2p_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gizo-network/gizo/config"
+	"github.com/gizo-network/gizo/crypt"
+	"github.com/gizo-network/gizo/p2p"
+)
+
+//returns a transport serving and dialing with a certificate bound to a new node key
+func nodeTransport(t *testing.T, mutual bool) (*p2p.Transport, string) {
+	priv, pub := crypt.GenKeys()
+	transport, err := p2p.NewTransport(config.TLSConfig{Enabled: true, Mutual: mutual}, priv, pub)
+	assert.NoError(t, err)
+	return transport, hex.EncodeToString(pub)
+}
+
+//returns a tls server answering with the node key bound to the client certificate
+func nodeServer(transport *p2p.Transport) *httptest.Server {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !transport.Authorized(r) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(p2p.PeerPub(r)))
+	}))
+	server.TLS = transport.ServerConfig()
+	server.StartTLS()
+	return server
+}
+
+//requests the server with the client config used to dial the node with pub
+func dial(server *httptest.Server, transport *p2p.Transport, pub string) (int, string, error) {
+	u, _ := url.Parse(server.URL)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: transport.ClientConfig(pub, u.Hostname())}}
+	res, err := client.Get(server.URL)
+	if err != nil {
+		return 0, "", err
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	return res.StatusCode, string(body), err
+}
+
+func TestTransport(t *testing.T) {
+	sTransport, sPub := nodeTransport(t, false)
+	server := nodeServer(sTransport)
+	defer server.Close()
+	cTransport, cPub := nodeTransport(t, false)
+
+	//! the server is verified against the pub it's dialed with and the client's node key is bound to the request
+	code, body, err := dial(server, cTransport, sPub)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, cPub, body)
+
+	_, otherPub := crypt.GenKeys()
+	_, _, err = dial(server, cTransport, hex.EncodeToString(otherPub))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), p2p.ErrPeerKeyMismatch.Error())
+
+	//! certificates without a node key binding aren't accepted
+	plain := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer plain.Close()
+	_, _, err = dial(plain, cTransport, "")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), crypt.ErrNoNodeKey.Error())
+}
+
+func TestTransportMutual(t *testing.T) {
+	sTransport, sPub := nodeTransport(t, true)
+	server := nodeServer(sTransport)
+	defer server.Close()
+
+	cTransport, cPub := nodeTransport(t, false)
+	code, body, err := dial(server, cTransport, sPub)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, cPub, body)
+
+	//! clients without a certificate are unauthorized
+	u, _ := url.Parse(server.URL)
+	tlsConfig := cTransport.ClientConfig(sPub, u.Hostname())
+	tlsConfig.Certificates = nil
+	res, err := (&http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}).Get(server.URL)
+	assert.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+}
+
+func TestNodeKey(t *testing.T) {
+	priv, pub := crypt.GenKeys()
+	cert, err := crypt.NewNodeCertificate(priv, pub)
+	assert.NoError(t, err)
+	parsed, err := x509.ParseCertificate(cert.Certificate[0])
+	assert.NoError(t, err)
+	bound, err := crypt.NodeKey(parsed)
+	assert.NoError(t, err)
+	assert.Equal(t, pub, bound)
+
+	//! a binding copied to a certificate of another key doesn't verify
+	var binding pkix.Extension
+	for _, ext := range parsed.Extensions {
+		if ext.Id.Equal(crypt.NodeKeyOID) {
+			binding = ext
+		}
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := x509.Certificate{
+		SerialNumber:    big.NewInt(1),
+		NotBefore:       time.Now().Add(-time.Hour),
+		NotAfter:        time.Now().Add(time.Hour),
+		ExtraExtensions: []pkix.Extension{binding},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	forged, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	_, err = crypt.NodeKey(forged)
+	assert.Equal(t, crypt.ErrInvalidNodeKey, err)
+
+	//! expired certificates aren't accepted
+	parsed.NotAfter = time.Now().Add(-time.Minute)
+	_, err = crypt.NodeKey(parsed)
+	assert.Equal(t, crypt.ErrCertExpired, err)
+}
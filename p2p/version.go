@@ -9,14 +9,14 @@ import (
 type Version struct {
 	Version int
 	Height  int
-	Blocks  []string
+	Tip     string // hash of the latest block
 }
 
-func NewVersion(version int, height int, blocks []string) Version {
+func NewVersion(version int, height int, tip string) Version {
 	return Version{
 		Version: version,
 		Height:  height,
-		Blocks:  blocks,
+		Tip:     tip,
 	}
 }
 
@@ -28,8 +28,8 @@ func (v Version) GetHeight() int {
 	return v.Height
 }
 
-func (v Version) GetBlocks() []string {
-	return v.Blocks
+func (v Version) GetTip() string {
+	return v.Tip
 }
 
 func (v Version) Serialize() []byte {
@@ -1,15 +1,27 @@
 package p2p
 
-import "github.com/gizo-network/gizo/job/queue/qItem"
+import (
+	"time"
+
+	"github.com/gizo-network/gizo/job"
+	"github.com/gizo-network/gizo/job/queue/qItem"
+	funk "github.com/thoas/go-funk"
+)
 
 type WorkerInfo struct {
-	pub  string
-	job  *qItem.Item
-	shut bool
+	pub       string
+	job       *qItem.Item
+	shut      bool
+	flags     int      // number of times the worker's result disagreed with other replicas
+	lastSeen  int64    // time the last message was received from the worker
+	deadline  int64    // time by which the assigned job should be done
+	unhealthy bool     // set when the worker misses a heartbeat or deadline
+	version   int      // negotiated protocol version
+	caps      []string // negotiated capabilities
 }
 
 func NewWorkerInfo(pub string) *WorkerInfo {
-	return &WorkerInfo{pub: pub}
+	return &WorkerInfo{pub: pub, lastSeen: time.Now().Unix()}
 }
 
 func (w WorkerInfo) GetPub() string {
@@ -20,6 +32,30 @@ func (w *WorkerInfo) SetPub(pub string) {
 	w.pub = pub
 }
 
+func (w WorkerInfo) GetVersion() int {
+	return w.version
+}
+
+func (w WorkerInfo) GetCapabilities() []string {
+	return w.caps
+}
+
+//SetProtocol sets the negotiated protocol version and capabilities
+func (w *WorkerInfo) SetProtocol(version int, caps []string) {
+	w.version = version
+	w.caps = caps
+}
+
+//Supports returns true if the capability was negotiated with the worker
+func (w WorkerInfo) Supports(c string) bool {
+	return funk.ContainsString(w.GetCapabilities(), c)
+}
+
+//Speaks returns true if the message is understood at the protocol version negotiated with the worker
+func (w WorkerInfo) Speaks(message string) bool {
+	return Speaks(w.GetVersion(), message)
+}
+
 func (w WorkerInfo) GetJob() *qItem.Item {
 	return w.job
 }
@@ -28,8 +64,45 @@ func (w *WorkerInfo) SetJob(j *qItem.Item) {
 	w.job = j
 }
 
+//Assign sets the job and a deadline derived from the exec's ttl
 func (w *WorkerInfo) Assign(j *qItem.Item) {
 	w.job = j
+	ttl := j.GetExec().GetTTL()
+	if ttl == 0 {
+		ttl = job.DefaultMaxTTL
+	}
+	w.deadline = time.Now().Add(ttl + DeadlineGrace).Unix()
+}
+
+func (w WorkerInfo) GetDeadline() int64 {
+	return w.deadline
+}
+
+//Overdue returns true if the worker has held its job past the deadline
+func (w WorkerInfo) Overdue() bool {
+	return w.GetJob() != nil && time.Now().Unix() > w.GetDeadline()
+}
+
+func (w WorkerInfo) GetLastSeen() int64 {
+	return w.lastSeen
+}
+
+//Seen updates the time the last message was received from the worker
+func (w *WorkerInfo) Seen() {
+	w.lastSeen = time.Now().Unix()
+}
+
+//Hung returns true if the worker hasn't sent a message within the heartbeat timeout
+func (w WorkerInfo) Hung() bool {
+	return time.Now().Sub(time.Unix(w.GetLastSeen(), 0)) > HeartbeatTimeout
+}
+
+func (w WorkerInfo) GetUnhealthy() bool {
+	return w.unhealthy
+}
+
+func (w *WorkerInfo) SetUnhealthy(u bool) {
+	w.unhealthy = u
 }
 
 func (w WorkerInfo) GetShut() bool {
@@ -40,6 +113,15 @@ func (w *WorkerInfo) SetShut(s bool) {
 	w.shut = s
 }
 
+func (w WorkerInfo) GetFlags() int {
+	return w.flags
+}
+
+//Flag marks the worker as having returned a result that disagreed with the majority
+func (w *WorkerInfo) Flag() {
+	w.flags++
+}
+
 func (w *WorkerInfo) Busy() bool {
 	return w.GetJob() == nil
 }
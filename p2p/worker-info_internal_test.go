@@ -0,0 +1,117 @@
+package p2p
+
+import (
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gizo-network/gizo/crypt"
+	"github.com/gizo-network/gizo/job"
+	"github.com/gizo-network/gizo/job/queue"
+	"github.com/gizo-network/gizo/job/queue/qItem"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/olahol/melody.v1"
+)
+
+//returns a melody session connected to a websocket client
+func connect(t *testing.T) (*melody.Session, *websocket.Conn, func()) {
+	m := melody.New()
+	sessions := make(chan *melody.Session, 1)
+	m.HandleConnect(func(s *melody.Session) {
+		sessions <- s
+	})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.HandleRequest(w, r)
+	}))
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	assert.NoError(t, err)
+	return <-sessions, conn, func() {
+		conn.Close()
+		m.Close()
+		server.Close()
+	}
+}
+
+//returns an item of a new job with a single exec
+func testItem(t *testing.T, priv []byte) qItem.Item {
+	j := job.NewJob("func Test(){return 1}", "Test", false, hex.EncodeToString(priv))
+	exec, err := job.NewExec([]interface{}{}, 0, job.NORMAL, 0, 0, 0, 0, "", job.NewEnvVariables(), "")
+	assert.NoError(t, err)
+	return qItem.NewItem(*j, exec, make(chan qItem.Item, 1), nil)
+}
+
+func TestWorkerInfoHealth(t *testing.T) {
+	priv, pub := crypt.GenKeys()
+	info := NewWorkerInfo(hex.EncodeToString(pub))
+	assert.False(t, info.Hung())
+	assert.False(t, info.Overdue()) //! no job
+	info.lastSeen = time.Now().Add(-HeartbeatTimeout - time.Second).Unix()
+	assert.True(t, info.Hung())
+	info.Seen()
+	assert.False(t, info.Hung())
+
+	item := testItem(t, priv)
+	info.Assign(&item)
+	assert.False(t, info.Overdue())
+	info.deadline = time.Now().Add(-time.Second).Unix()
+	assert.True(t, info.Overdue())
+}
+
+func TestCheckWorkers(t *testing.T) {
+	priv, pub := crypt.GenKeys()
+	d := &Dispatcher{
+		priv:     priv,
+		Pub:      pub,
+		mu:       new(sync.Mutex),
+		jobPQ:    queue.NewJobPriorityQueue(),
+		workers:  make(map[*melody.Session]*WorkerInfo),
+		replicas: make(map[*job.Exec]*ReplicaSet),
+		parked:   make(map[*job.Exec]qItem.Item),
+		workerPQ: NewWorkerPriorityQueue(),
+	}
+	hung, hungConn, closeHung := connect(t)
+	defer closeHung()
+	healthy, healthyConn, closeHealthy := connect(t)
+	defer closeHealthy()
+
+	//! a hung worker with a job and a healthy idle one, both answer pings
+	_, hungPub := crypt.GenKeys()
+	hungInfo := NewWorkerInfo(hex.EncodeToString(hungPub))
+	hungInfo.SetProtocol(ProtocolVersion, []string{CapHeartbeat})
+	assigned := testItem(t, priv)
+	hungInfo.Assign(&assigned)
+	hungInfo.lastSeen = time.Now().Add(-HeartbeatTimeout - time.Second).Unix()
+	d.workers[hung] = hungInfo
+	d.workerPQ.Push(hung, 0)
+	_, healthyPub := crypt.GenKeys()
+	healthyInfo := NewWorkerInfo(hex.EncodeToString(healthyPub))
+	healthyInfo.SetProtocol(ProtocolVersion, []string{CapHeartbeat})
+	d.workers[healthy] = healthyInfo
+	d.workerPQ.Push(healthy, 0)
+
+	queued := testItem(t, priv)
+	d.jobPQ.PushItem(queued, job.NORMAL)
+	d.checkWorkers()
+
+	assert.True(t, hungInfo.GetUnhealthy())
+	assert.Nil(t, hungInfo.GetJob())
+	assert.False(t, d.workerPQ.Exist(hung))
+	assert.False(t, healthyInfo.GetUnhealthy())
+	//! the hung worker's job is requeued with HIGH priority, ahead of the job that was already queued
+	assert.Equal(t, assigned.GetExec(), d.jobPQ.Pop().GetExec())
+	assert.Equal(t, queued.GetExec(), d.jobPQ.Pop().GetExec())
+
+	for _, conn := range []*websocket.Conn{hungConn, healthyConn} {
+		conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+		_, message, err := conn.ReadMessage()
+		assert.NoError(t, err)
+		m, err := DeserializePeerMessage(message)
+		assert.NoError(t, err)
+		assert.Equal(t, PING, m.GetMessage())
+	}
+}
@@ -1,14 +1,18 @@
 package p2p
 
 import (
+	"crypto/tls"
 	"encoding/hex"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/gizo-network/gizo/config"
 	"github.com/gizo-network/gizo/core"
 	"github.com/gizo-network/gizo/crypt"
 	"github.com/gizo-network/gizo/job/queue/qItem"
@@ -20,17 +24,25 @@ type Worker struct {
 	Port       uint   // port
 	Pub        []byte //public key of the node
 	Dispatcher string
-	shortlist  []string // array of dispatchers received from centrum
+	shortlist  []string // array of dispatchers received from discovery
 	priv       []byte   //private key of the node
 	uptime     int64    //time since node has been up
 	conn       *websocket.Conn
 	interrupt  chan os.Signal
 	shutdown   chan struct{}
-	busy       bool
+	busy       int32           // number of jobs running, accessed atomically
+	jobs       *sync.WaitGroup // jobs running, waited on before shutting down
 	state      string
+	mu         *sync.Mutex // guards writes to conn and state
+	transport  *Transport
+	challenge  []byte // nonce the dispatcher must sign during the handshake
+	replay     *ReplayGuard
+	sent       *ResendBuffer // messages sent to the dispatcher, resent if they're rate limited
+	caps       []string      // capabilities negotiated with the dispatcher
+	cfg        *config.Config
 }
 
-func (w Worker) GetShortlist() []string {
+func (w *Worker) GetShortlist() []string {
 	return w.shortlist
 }
 
@@ -38,51 +50,71 @@ func (w *Worker) SetShortlist(s []string) {
 	w.shortlist = s
 }
 
-func (w Worker) GetBusy() bool {
-	return w.busy
+//GetBusy returns true if a job is running
+func (w *Worker) GetBusy() bool {
+	return atomic.LoadInt32(&w.busy) > 0
 }
 
+//SetBusy marks a job as started or finished, jobs run in parallel with the read loop
 func (w *Worker) SetBusy(b bool) {
-	w.busy = b
+	if b {
+		w.jobs.Add(1)
+		atomic.AddInt32(&w.busy, 1)
+		return
+	}
+	atomic.AddInt32(&w.busy, -1)
+	w.jobs.Done()
 }
 
-func (w Worker) NodeTypeDispatcher() bool {
+func (w *Worker) NodeTypeDispatcher() bool {
 	return false
 }
 
-func (w Worker) GetState() string {
+func (w *Worker) GetState() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
 	return w.state
 }
 
 func (w *Worker) SetState(s string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
 	w.state = s
 }
 
-func (w Worker) GetPubByte() []byte {
+func (w *Worker) GetPubByte() []byte {
 	return w.Pub
 }
 
-func (w Worker) GetPubString() string {
+func (w *Worker) GetPubString() string {
 	return hex.EncodeToString(w.Pub)
 }
 
-func (w Worker) GetPrivByte() []byte {
+func (w *Worker) GetPrivByte() []byte {
 	return w.priv
 }
 
-func (w Worker) GetPrivString() string {
+func (w *Worker) GetPrivString() string {
 	return hex.EncodeToString(w.priv)
 }
 
-func (w Worker) GetPort() int {
+func (w *Worker) GetPort() int {
 	return int(w.Port)
 }
 
-func (w Worker) GetUptme() int64 {
+func (w *Worker) GetTransport() *Transport {
+	return w.transport
+}
+
+func (w *Worker) GetConfig() *config.Config {
+	return w.cfg
+}
+
+func (w *Worker) GetUptme() int64 {
 	return w.uptime
 }
 
-func (w Worker) GetDispatcher() string {
+func (w *Worker) GetDispatcher() string {
 	return w.Dispatcher
 }
 
@@ -90,7 +122,11 @@ func (w *Worker) SetDispatcher(d string) {
 	w.Dispatcher = d
 }
 
-func (w Worker) GetUptimeString() string {
+func (w *Worker) GetCapabilities() []string {
+	return w.caps
+}
+
+func (w *Worker) GetUptimeString() string {
 	return time.Unix(w.uptime, 0).Sub(time.Now()).String()
 }
 
@@ -98,20 +134,56 @@ func (w *Worker) Start() {
 	w.GetDispatchers()
 	w.Connect()
 	go w.WatchInterrupt()
-	w.conn.WriteMessage(websocket.BinaryMessage, HelloMessage(w.GetPubByte()))
+	go w.serveMetrics()
 	for {
 		_, message, err := w.conn.ReadMessage()
 		if err != nil {
+			if w.GetState() == DOWN {
+				return //! disconnected after a graceful shutdown
+			}
 			//TODO: handle dispatcher unexpected disconnect
 			glg.Fatal(err)
 		}
-		m := DeserializePeerMessage(message)
+		m, err := DeserializePeerMessage(message)
+		if err != nil {
+			w.Write(InvalidMessage())
+			continue
+		}
+		if !m.Compatible() {
+			w.Write(ErrorMessage(ErrCodeVersion, VersionReason(m.GetVersion())))
+			w.dropDispatcher()
+			w.Disconnect()
+			w.Connect()
+			continue
+		}
 		switch m.GetMessage() {
+		case ERROR:
+			logPeerError("Worker: dispatcher error", m)
+			if peerErr, err := DeserializePeerError(m.GetPayload()); err == nil {
+				switch peerErr.GetCode() {
+				case ErrCodeConnFull, ErrCodeVersion, ErrCodeHandshake:
+					w.dropDispatcher()
+					w.Disconnect()
+					w.Connect()
+				case ErrCodeRateLimit:
+					go w.resend(peerErr)
+				}
+			}
+			break
 		case HELLO:
-			if w.GetDispatcher() != hex.EncodeToString(m.GetPayload()) {
+			hello, err := DeserializeHandshake(m.GetPayload())
+			if err == nil {
+				_, err = NegotiateVersion(hello.GetVersion())
+			}
+			if err != nil || w.GetDispatcher() != hex.EncodeToString(hello.GetPub()) || !VerifyChallenge(hello.GetPub(), HelloDomain, w.challenge, w.GetPubByte(), hello.GetResponse()) {
+				glg.Warn("Worker: dispatcher failed handshake")
+				w.dropDispatcher()
 				w.Disconnect()
 				w.Connect()
+				break
 			}
+			w.caps = NegotiateCapabilities(hello.GetCapabilities())
+			w.Write(AuthMessage(NewHandshake(w.GetPubByte(), nil, SignChallenge(w.GetPrivByte(), AuthDomain, hello.GetChallenge(), w.GetPubByte(), hello.GetPub())).Serialize()))
 			w.SetState(INIT)
 			glg.Info("P2P: connected to dispatcher")
 			break
@@ -120,33 +192,37 @@ func (w *Worker) Start() {
 			if w.GetState() != LIVE {
 				w.SetState(LIVE)
 			}
-			w.SetBusy(true)
-			if m.VerifySignature(w.GetDispatcher()) {
-				j := qItem.DeserializeItem(m.GetPayload())
-				exec := j.Job.Execute(j.GetExec(), w.GetDispatcher())
-				j.SetExec(exec)
-				w.conn.WriteMessage(websocket.BinaryMessage, ResultMessage(j.GetExec().Serialize(), w.GetPrivByte()))
+			if m.VerifySignature(w.GetDispatcher()) && w.replay.Check(m) {
+				w.SetBusy(true)
+				//! executed in a goroutine so heartbeats are answered while the job runs
+				go func(payload []byte) {
+					j := qItem.DeserializeItem(payload)
+					exec := j.Job.Execute(j.GetExec(), w.GetDispatcher())
+					j.SetExec(exec)
+					w.Write(ResultMessage(j.GetExec().Serialize(), w.GetPrivByte()))
+					w.SetBusy(false)
+				}(m.GetPayload())
 			} else {
-				w.conn.WriteMessage(websocket.BinaryMessage, InvalidSignature())
+				w.Write(InvalidSignature())
 				w.Disconnect()
 			}
-			w.SetBusy(false)
+			break
+		case PING:
+			w.Write(PongMessage(w.GetPrivByte()))
 			break
 		case SHUT:
 			//TODO: handle dispatcher shut
 			break
 		case SHUTACK:
-			for {
-				if w.GetBusy() {
-					continue
-				} else {
-					break
-				}
-			} // wait until worker not busy
-			w.Disconnect()
-			w.SetState(DOWN)
-			glg.Info("Worker: graceful shutdown")
-			os.Exit(0)
+			//! waits for running jobs in a goroutine so their results are sent and pings answered meanwhile
+			go func() {
+				w.jobs.Wait()
+				w.SetState(DOWN)
+				w.Disconnect()
+				glg.Info("Worker: graceful shutdown")
+				os.Exit(0)
+			}()
+			break
 		default:
 			w.Disconnect() //look for new dispatcher
 			break
@@ -154,7 +230,28 @@ func (w *Worker) Start() {
 	}
 }
 
-func (w Worker) Disconnect() {
+//Write sends a message to the dispatcher
+func (w *Worker) Write(m []byte) error {
+	w.sent.Add(m)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.conn.WriteMessage(websocket.BinaryMessage, m)
+}
+
+//sends a rate limited message again once the dispatcher's retry-after has passed
+func (w *Worker) resend(peerErr PeerError) {
+	m, ok := w.sent.Get(peerErr.GetNonce())
+	if !ok {
+		glg.Warn("Worker: rate limited message is no longer held, dropping it")
+		return
+	}
+	time.Sleep(peerErr.GetRetryAfter())
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.conn.WriteMessage(websocket.BinaryMessage, m)
+}
+
+func (w *Worker) Disconnect() {
 	w.conn.Close()
 }
 
@@ -162,9 +259,10 @@ func (w *Worker) Connect() {
 	for i, dispatcher := range w.GetShortlist() {
 		addr, err := ParseAddr(dispatcher)
 		if err == nil {
-			url := fmt.Sprintf("ws://%v:%v/w", addr["ip"], addr["port"])
-			if err = w.Dial(url); err == nil {
+			url := fmt.Sprintf("%v://%v:%v/w", w.GetTransport().WSScheme(), addr["ip"], addr["port"])
+			if err = w.Dial(url, w.GetTransport().ClientConfig(addr["pub"].(string), addr["ip"].(string))); err == nil {
 				w.SetDispatcher(addr["pub"].(string))
+				w.hello()
 				return
 			}
 		}
@@ -173,28 +271,47 @@ func (w *Worker) Connect() {
 	w.GetDispatchers()
 }
 
-func (w *Worker) Dial(url string) error {
+//starts the handshake with the connected dispatcher
+func (w *Worker) hello() {
+	w.challenge = NewChallenge()
+	w.Write(HelloMessage(NewHandshake(w.GetPubByte(), w.challenge, nil).Serialize()))
+}
+
+//removes the connected dispatcher from the shortlist
+func (w *Worker) dropDispatcher() {
+	var shortlist []string
+	for _, dispatcher := range w.GetShortlist() {
+		if addr, err := ParseAddr(dispatcher); err == nil && addr["pub"].(string) != w.GetDispatcher() {
+			shortlist = append(shortlist, dispatcher)
+		}
+	}
+	w.SetShortlist(shortlist)
+}
+
+func (w *Worker) Dial(url string, tlsConfig *tls.Config) error {
 	dailer := websocket.Dialer{
 		Proxy:           http.ProxyFromEnvironment,
-		ReadBufferSize:  10000,
-		WriteBufferSize: 10000,
+		ReadBufferSize:  w.GetConfig().Worker.ReadBufferSize,
+		WriteBufferSize: w.GetConfig().Worker.WriteBufferSize,
+		TLSClientConfig: tlsConfig,
 	}
 	conn, _, err := dailer.Dial(url, nil)
 	if err != nil {
-		glg.Fatal(err)
+		glg.Warn("Worker: unable to dial dispatcher - " + err.Error())
+		return err
 	}
 	conn.EnableWriteCompression(true)
 	w.conn = conn
 	return nil
 }
 
-func (w Worker) WatchInterrupt() {
+func (w *Worker) WatchInterrupt() {
 	select {
 	case i := <-w.interrupt:
 		glg.Warn("Worker: interrupt detected")
 		switch i {
 		case syscall.SIGINT, syscall.SIGTERM:
-			w.conn.WriteMessage(websocket.BinaryMessage, ShutMessage(w.GetPrivByte()))
+			w.Write(ShutMessage(w.GetPrivByte()))
 			break
 		case syscall.SIGQUIT:
 			os.Exit(1)
@@ -203,17 +320,19 @@ func (w Worker) WatchInterrupt() {
 }
 
 func (w *Worker) GetDispatchers() {
-	c := NewCentrum()
-	res := c.GetDispatchers()
-	shortlist, ok := res["dispatchers"]
-	if !ok {
-		glg.Warn(ErrNoDispatchers)
+	discovery, err := NewDiscovery(w.GetConfig())
+	if err != nil {
+		glg.Fatal(err)
+	}
+	shortlist, err := discovery.GetDispatchers()
+	if err != nil {
+		glg.Warn(err)
 		os.Exit(0)
 	}
-	w.SetShortlist(shortlist.([]string))
+	w.SetShortlist(shortlist)
 }
 
-func NewWorker(port int) *Worker {
+func NewWorker(cfg *config.Config) *Worker {
 	core.InitializeDataPath()
 	var priv, pub []byte
 	interrupt := make(chan os.Signal, 1)
@@ -252,6 +371,10 @@ func NewWorker(port int) *Worker {
 	// }
 	// }
 	priv, pub = crypt.GenKeys()
+	transport, err := NewTransport(cfg.TLS, priv, pub)
+	if err != nil {
+		glg.Fatal(err)
+	}
 	// db, err := bolt.Open(dbFile, 0600, &bolt.Options{Timeout: time.Second * 2})
 	// if err != nil {
 	// 	glg.Fatal(err)
@@ -278,9 +401,15 @@ func NewWorker(port int) *Worker {
 	return &Worker{
 		Pub:       pub,
 		priv:      priv,
-		Port:      uint(port),
+		Port:      uint(cfg.Worker.Port),
 		uptime:    time.Now().Unix(),
 		interrupt: interrupt,
 		state:     DOWN,
+		mu:        new(sync.Mutex),
+		jobs:      new(sync.WaitGroup),
+		cfg:       cfg,
+		transport: transport,
+		replay:    NewReplayGuard(),
+		sent:      NewResendBuffer(),
 	}
 }
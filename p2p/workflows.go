@@ -0,0 +1,191 @@
+package p2p
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"net/http"
+
+	"github.com/gizo-network/gizo/job"
+	"github.com/gizo-network/gizo/job/batch"
+	"github.com/gizo-network/gizo/job/chain"
+	"github.com/gizo-network/gizo/job/chord"
+	"github.com/gizo-network/gizo/job/dag"
+	"github.com/gizo-network/gizo/job/mapreduce"
+	"github.com/gizo-network/gizo/job/queue"
+	"github.com/gizo-network/gizo/job/solo"
+	"github.com/gizo-network/gizo/job/workflow"
+	"github.com/gorilla/mux"
+)
+
+var (
+	ErrWorkflowAuth = errors.New("Workflows: request must be signed by the client")
+)
+
+const (
+	WorkflowPubHeader  = "x-gizo-pub"
+	WorkflowAuthHeader = "x-gizo-workflow-auth" // hex of a WORKFLOWS message over the request uri signed by the client, also used for results
+)
+
+type ownerKey struct{}
+
+//tracked is implemented by workflows that keep a record
+type tracked interface {
+	SetOwner(pub string)
+	SetStore(s *workflow.Store)
+}
+
+//SignWorkflowsRequest sets the headers that authenticate a client's request to the workflow endpoints
+func SignWorkflowsRequest(r *http.Request, priv, pub []byte) {
+	r.Header.Set(WorkflowPubHeader, hex.EncodeToString(pub))
+	r.Header.Set(WorkflowAuthHeader, hex.EncodeToString(WorkflowsMessage([]byte(r.URL.RequestURI()), priv)))
+}
+
+//registers the endpoints clients use to list their workflows and watch one after reconnecting, and to fetch results
+//of their execs recovered from the queue journal after a restart
+func (d *Dispatcher) registerWorkflows() {
+	limit := func(next http.Handler) http.Handler {
+		return rateLimit(d.rpcLimit, next)
+	}
+	workflows := d.router.PathPrefix("/workflows").Subrouter()
+	workflows.Use(limit)
+	workflows.Use(d.workflowsAuth)
+	workflows.HandleFunc("", d.listWorkflows).Methods("GET")
+	workflows.HandleFunc("/{id}", d.getWorkflow).Methods("GET")
+	results := d.router.PathPrefix("/results").Subrouter()
+	results.Use(limit)
+	results.Use(d.workflowsAuth)
+	results.HandleFunc("/{id}", d.getResults).Methods("GET")
+}
+
+//rejects requests that aren't signed by the pub they claim, or that were signed for another uri
+func (d *Dispatcher) workflowsAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pub := r.Header.Get(WorkflowPubHeader)
+		b, err := hex.DecodeString(r.Header.Get(WorkflowAuthHeader))
+		if err != nil {
+			http.Error(w, ErrWorkflowAuth.Error(), http.StatusUnauthorized)
+			return
+		}
+		m, err := DeserializePeerMessage(b)
+		if err != nil || m.GetMessage() != WORKFLOWS || string(m.GetPayload()) != r.URL.RequestURI() || !d.verify(m, pub) {
+			http.Error(w, ErrWorkflowAuth.Error(), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ownerKey{}, pub)))
+	})
+}
+
+//lists the requester's workflow records, filtered by the state query param if set
+func (d *Dispatcher) listWorkflows(w http.ResponseWriter, r *http.Request) {
+	records := d.GetWorkflows().List(r.Context().Value(ownerKey{}).(string), r.URL.Query().Get("state"))
+	if records == nil {
+		records = []workflow.Record{}
+	}
+	writeJSON(w, records)
+}
+
+//returns a workflow record of the requester, records of other clients are reported as not found
+func (d *Dispatcher) getWorkflow(w http.ResponseWriter, r *http.Request) {
+	record, err := d.GetWorkflows().Get(mux.Vars(r)["id"])
+	if err == nil && record.GetOwner() != r.Context().Value(ownerKey{}).(string) {
+		err = workflow.ErrWorkflowNotFound
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, record)
+}
+
+//returns the journal records of the requester's execs of a job, execs of other clients are left out
+func (d *Dispatcher) getResults(w http.ResponseWriter, r *http.Request) {
+	results := []queue.Record{}
+	for _, record := range d.GetJobPQ().Results(mux.Vars(r)["id"]) {
+		if record.GetItem().GetExec().GetSubmitter() == r.Context().Value(ownerKey{}).(string) {
+			results = append(results, record)
+		}
+	}
+	writeJSON(w, results)
+}
+
+//saves the record of a workflow submitted by pub to the dispatcher's store
+func (d *Dispatcher) track(t tracked, pub string) {
+	t.SetOwner(pub)
+	t.SetStore(d.GetWorkflows())
+}
+
+//sets the submitter of the execs of a workflow to the pub of the client that submitted it
+func submittedBy(pub string, jobs ...job.JobRequestMultiple) {
+	for _, jr := range jobs {
+		for _, exec := range jr.GetExec() {
+			exec.SetSubmitter(pub)
+		}
+	}
+}
+
+//NewSolo returns a solo submitted by pub that's queued on the dispatcher and recorded in its workflow store
+func (d *Dispatcher) NewSolo(pub string, jr job.JobRequestSingle) *solo.Solo {
+	jr.GetExec().SetSubmitter(pub)
+	s := solo.NewSolo(jr, d.GetBC(), d.GetJobPQ(), d.GetJC())
+	d.track(s, pub)
+	return s
+}
+
+//NewChain returns a chain submitted by pub that's queued on the dispatcher and recorded in its workflow store
+func (d *Dispatcher) NewChain(pub string, j []job.JobRequestMultiple) (*chain.Chain, error) {
+	submittedBy(pub, j...)
+	c, err := chain.NewChain(j, d.GetBC(), d.GetJobPQ(), d.GetJC())
+	if err != nil {
+		return nil, err
+	}
+	d.track(c, pub)
+	return c, nil
+}
+
+//NewBatch returns a batch submitted by pub that's queued on the dispatcher and recorded in its workflow store
+func (d *Dispatcher) NewBatch(pub string, j []job.JobRequestMultiple) (*batch.Batch, error) {
+	submittedBy(pub, j...)
+	b, err := batch.NewBatch(j, d.GetBC(), d.GetJobPQ(), d.GetJC())
+	if err != nil {
+		return nil, err
+	}
+	d.track(b, pub)
+	return b, nil
+}
+
+//NewChord returns a chord submitted by pub that's queued on the dispatcher and recorded in its workflow store
+func (d *Dispatcher) NewChord(pub string, j []job.JobRequestMultiple, callback job.JobRequestMultiple) (*chord.Chord, error) {
+	submittedBy(pub, j...)
+	submittedBy(pub, callback)
+	c, err := chord.NewChord(j, callback, d.GetBC(), d.GetJobPQ(), d.GetJC())
+	if err != nil {
+		return nil, err
+	}
+	d.track(c, pub)
+	return c, nil
+}
+
+//NewDAG returns a dag submitted by pub that's queued on the dispatcher and recorded in its workflow store
+func (d *Dispatcher) NewDAG(pub string, nodes map[string]job.JobRequestMultiple, edges []dag.Edge) (*dag.DAG, error) {
+	for _, jr := range nodes {
+		submittedBy(pub, jr)
+	}
+	g, err := dag.NewDAG(nodes, edges, d.GetBC(), d.GetJobPQ(), d.GetJC())
+	if err != nil {
+		return nil, err
+	}
+	d.track(g, pub)
+	return g, nil
+}
+
+//NewMapReduce returns a map/reduce submitted by pub that's queued on the dispatcher and recorded in its workflow store
+func (d *Dispatcher) NewMapReduce(pub, mapper, combiner, reducer string, inputs []interface{}, chunk int, template *job.Exec) (*mapreduce.MapReduce, error) {
+	template.SetSubmitter(pub)
+	mr, err := mapreduce.NewMapReduce(mapper, combiner, reducer, inputs, chunk, template, d.GetBC(), d.GetJobPQ(), d.GetJC())
+	if err != nil {
+		return nil, err
+	}
+	d.track(mr, pub)
+	return mr, nil
+}
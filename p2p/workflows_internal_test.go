@@ -0,0 +1,165 @@
+package p2p
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/gizo-network/gizo/crypt"
+	"github.com/gizo-network/gizo/job"
+	"github.com/gizo-network/gizo/job/queue"
+	"github.com/gizo-network/gizo/job/queue/qItem"
+	"github.com/gizo-network/gizo/job/workflow"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkflowsAuth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gizo-workflows")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	db, err := bolt.Open(path.Join(dir, NodeDB), 0600, nil)
+	assert.NoError(t, err)
+	defer db.Close()
+	d := &Dispatcher{
+		router:    mux.NewRouter(),
+		replay:    NewReplayGuard(),
+		workflows: workflow.NewStore(db),
+		rpcLimit:  NewRateLimiter(100, 100),
+	}
+	d.registerWorkflows()
+	server := httptest.NewServer(d.router)
+	defer server.Close()
+
+	priv, pub := crypt.GenKeys()
+	otherPriv, otherPub := crypt.GenKeys()
+	mine := workflow.NewTracker("chain")
+	d.track(mine, hex.EncodeToString(pub))
+	other := workflow.NewTracker("batch")
+	d.track(other, hex.EncodeToString(otherPub))
+
+	get := func(uri string, priv, pub []byte) *http.Response {
+		req, err := http.NewRequest("GET", server.URL+uri, nil)
+		assert.NoError(t, err)
+		if priv != nil {
+			SignWorkflowsRequest(req, priv, pub)
+		}
+		res, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		return res
+	}
+
+	res := get("/workflows", nil, nil)
+	res.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	res = get("/workflows", otherPriv, pub) //! signed by another client's key
+	res.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+	//! clients only see their own workflows
+	res = get("/workflows", priv, pub)
+	var records []workflow.Record
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&records))
+	res.Body.Close()
+	assert.Len(t, records, 1)
+	assert.Equal(t, mine.GetID(), records[0].GetID())
+	res = get("/workflows/"+mine.GetID(), priv, pub)
+	res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	res = get("/workflows/"+other.GetID(), priv, pub)
+	res.Body.Close()
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+	//! signed requests can't be replayed or used for another uri
+	req, err := http.NewRequest("GET", server.URL+"/workflows", nil)
+	assert.NoError(t, err)
+	SignWorkflowsRequest(req, priv, pub)
+	res, err = http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	res, err = http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	moved, err := http.NewRequest("GET", server.URL+"/workflows?state=RUNNING", nil)
+	assert.NoError(t, err)
+	SignWorkflowsRequest(moved, priv, pub)
+	moved.Header.Set(WorkflowAuthHeader, req.Header.Get(WorkflowAuthHeader))
+	res, err = http.DefaultClient.Do(moved)
+	assert.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+}
+
+func TestResultsAfterRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gizo-results")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	db, err := bolt.Open(path.Join(dir, NodeDB), 0600, nil)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	priv, pub := crypt.GenKeys()
+	otherPriv, otherPub := crypt.GenKeys()
+	j := job.NewJob("func Test(){return 1}", "Test", false, hex.EncodeToString(priv))
+	pq := queue.NewPersistentJobPriorityQueue(db, nil, nil)
+	for _, submitter := range [][]byte{pub, otherPub} {
+		exec, err := job.NewExec([]interface{}{}, 0, job.NORMAL, 0, 0, 0, 0, "", job.NewEnvVariables(), "")
+		assert.NoError(t, err)
+		exec.SetSubmitter(hex.EncodeToString(submitter))
+		assert.NoError(t, pq.Push(*j, exec, make(chan qItem.Item, 1), nil))
+	}
+	for !pq.Empty() {
+		item := pq.Pop()
+		result := *item.GetExec()
+		result.SetStatus(job.FINISHED)
+		result.SetResult(item.GetExec().GetSubmitter())
+		pq.Complete(item.GetExec(), result)
+	}
+
+	//! the dispatcher restarts with the journal of the queue
+	d := &Dispatcher{
+		router:   mux.NewRouter(),
+		replay:   NewReplayGuard(),
+		jobPQ:    queue.NewPersistentJobPriorityQueue(db, nil, nil),
+		rpcLimit: NewRateLimiter(100, 100),
+	}
+	d.registerWorkflows()
+	server := httptest.NewServer(d.router)
+	defer server.Close()
+	get := func(uri string, priv, pub []byte) (*http.Response, []queue.Record) {
+		req, err := http.NewRequest("GET", server.URL+uri, nil)
+		assert.NoError(t, err)
+		if priv != nil {
+			SignWorkflowsRequest(req, priv, pub)
+		}
+		res, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		defer res.Body.Close()
+		var records []queue.Record
+		if res.StatusCode == http.StatusOK {
+			assert.NoError(t, json.NewDecoder(res.Body).Decode(&records))
+		}
+		return res, records
+	}
+
+	res, _ := get("/results/"+j.GetID(), nil, nil)
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	//! each client only gets the results of its own execs
+	for _, client := range [][][]byte{{priv, pub}, {otherPriv, otherPub}} {
+		res, records := get("/results/"+j.GetID(), client[0], client[1])
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Len(t, records, 1)
+		assert.True(t, records[0].Done())
+		assert.Equal(t, hex.EncodeToString(client[1]), records[0].GetResult().GetResult())
+	}
+	_, records := get("/results/unknown", priv, pub)
+	assert.Empty(t, records)
+}
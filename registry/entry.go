@@ -0,0 +1,90 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/kpango/glg"
+)
+
+//Entry is a dispatcher known to the registry
+type Entry struct {
+	Pub     string `json:"pub"`
+	IP      string `json:"ip"`
+	Port    int    `json:"port"`
+	Workers int    `json:"workers"` // number of workers connected to the dispatcher
+	Awake   bool   `json:"awake"`
+	Nonce   int64  `json:"nonce"` // nonce of the latest registration, older ones are rejected as replays
+}
+
+func NewEntry(pub, ip string, port int) *Entry {
+	return &Entry{Pub: pub, IP: ip, Port: port, Awake: true}
+}
+
+func (e Entry) GetPub() string {
+	return e.Pub
+}
+
+func (e Entry) GetIP() string {
+	return e.IP
+}
+
+func (e Entry) GetPort() int {
+	return e.Port
+}
+
+func (e Entry) GetWorkers() int {
+	return e.Workers
+}
+
+func (e *Entry) SetWorkers(w int) {
+	e.Workers = w
+}
+
+func (e *Entry) IncrWorkers() {
+	e.Workers++
+}
+
+func (e *Entry) DecrWorkers() {
+	if e.Workers > 0 {
+		e.Workers--
+	}
+}
+
+func (e Entry) GetAwake() bool {
+	return e.Awake
+}
+
+func (e *Entry) SetAwake(a bool) {
+	e.Awake = a
+}
+
+func (e Entry) GetNonce() int64 {
+	return e.Nonce
+}
+
+func (e *Entry) SetNonce(n int64) {
+	e.Nonce = n
+}
+
+//GetAddr returns the address of the dispatcher in the format parsed by nodes
+func (e Entry) GetAddr() string {
+	return fmt.Sprintf("%v://%v@%v:%v", Scheme, e.GetPub(), e.GetIP(), e.GetPort())
+}
+
+func (e Entry) Serialize() []byte {
+	bytes, err := json.Marshal(e)
+	if err != nil {
+		glg.Fatal(err)
+	}
+	return bytes
+}
+
+func DeserializeEntry(b []byte) *Entry {
+	var e Entry
+	err := json.Unmarshal(b, &e)
+	if err != nil {
+		glg.Fatal(err)
+	}
+	return &e
+}
@@ -0,0 +1,211 @@
+package registry
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/gizo-network/gizo/crypt"
+	"github.com/gorilla/mux"
+	"github.com/kpango/glg"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	Scheme         = "gizo"
+	RegistryDB     = "centrum.db"
+	EntryBucket    = "dispatchers"
+	TokenHeader    = "x-gizo-token"
+	RegisterDomain = "gizo-register" // prefixed to registrations so their signatures can't be reused elsewhere
+	MaxRegisterAge = time.Minute * 5 // registrations signed longer ago or further in the future are rejected
+)
+
+var (
+	ErrInvalidToken      = errors.New("Registry: invalid token")
+	ErrInvalidDispatcher = errors.New("Registry: invalid dispatcher")
+	ErrInvalidSignature  = errors.New("Registry: invalid signature")
+	ErrStaleNonce        = errors.New("Registry: stale or reused nonce")
+)
+
+//RegisterMessage returns the data a dispatcher signs with its node key to register pub at ip and port,
+//nonce is the unix time in nanoseconds the registration was signed at
+func RegisterMessage(pub, ip string, port int, nonce int64) []byte {
+	return []byte(strings.Join([]string{RegisterDomain, pub, ip, strconv.Itoa(port), strconv.FormatInt(nonce, 10)}, "|"))
+}
+
+//Registry is a self-hosted centrum compatible registry of dispatchers
+type Registry struct {
+	db     *bolt.DB
+	router *mux.Router
+}
+
+func NewRegistry(db *bolt.DB) *Registry {
+	err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(EntryBucket))
+		return err
+	})
+	if err != nil {
+		glg.Fatal(err)
+	}
+	r := &Registry{db: db, router: mux.NewRouter()}
+	r.router.HandleFunc("/v1/dispatchers", r.getDispatchers).Methods("GET")
+	r.router.HandleFunc("/v1/dispatcher", r.register).Methods("POST")
+	r.router.HandleFunc("/v1/dispatcher/{action:connect|disconnect|wake|sleep}", r.update).Methods("PATCH")
+	return r
+}
+
+func (r Registry) GetDB() *bolt.DB {
+	return r.db
+}
+
+func (r Registry) GetRouter() *mux.Router {
+	return r.router
+}
+
+//GetEntries returns the dispatchers known to the registry
+func (r Registry) GetEntries() []Entry {
+	var entries []Entry
+	err := r.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(EntryBucket)).ForEach(func(k, v []byte) error {
+			entries = append(entries, *DeserializeEntry(v))
+			return nil
+		})
+	})
+	if err != nil {
+		glg.Fatal(err)
+	}
+	return entries
+}
+
+func (r Registry) Start(port int) error {
+	glg.Info("Registry: listening on port " + strconv.Itoa(port))
+	return http.ListenAndServe(":"+strconv.Itoa(port), r.GetRouter())
+}
+
+func writeStatus(w http.ResponseWriter, code int, res map[string]string) {
+	bytes, err := json.Marshal(res)
+	if err != nil {
+		glg.Fatal(err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(bytes)
+}
+
+//returns awake dispatchers, least loaded first
+func (r Registry) getDispatchers(w http.ResponseWriter, req *http.Request) {
+	var awake []Entry
+	for _, entry := range r.GetEntries() {
+		if entry.GetAwake() {
+			awake = append(awake, entry)
+		}
+	}
+	sort.Slice(awake, func(i, j int) bool {
+		return awake[i].GetWorkers() < awake[j].GetWorkers()
+	})
+	addrs := []string{}
+	for _, entry := range awake {
+		addrs = append(addrs, entry.GetAddr())
+	}
+	bytes, err := json.Marshal(addrs)
+	if err != nil {
+		glg.Fatal(err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(bytes)
+}
+
+//registers a dispatcher, the registration must be signed by the node key of pub over its address and a nonce
+//newer than the dispatcher's last registration
+func (r Registry) register(w http.ResponseWriter, req *http.Request) {
+	pub := req.FormValue("pub")
+	ip := req.FormValue("ip")
+	port, err := strconv.Atoi(req.FormValue("port"))
+	pubBytes, decodeErr := hex.DecodeString(pub)
+	if pub == "" || decodeErr != nil || ip == "" || err != nil || port <= 0 {
+		writeStatus(w, http.StatusBadRequest, map[string]string{"status": ErrInvalidDispatcher.Error()})
+		return
+	}
+	nonce, err := strconv.ParseInt(req.FormValue("nonce"), 10, 64)
+	if age := time.Since(time.Unix(0, nonce)); err != nil || age > MaxRegisterAge || -age > MaxRegisterAge {
+		writeStatus(w, http.StatusUnauthorized, map[string]string{"status": ErrStaleNonce.Error()})
+		return
+	}
+	sigR, errR := hex.DecodeString(req.FormValue("r"))
+	sigS, errS := hex.DecodeString(req.FormValue("s"))
+	if errR != nil || errS != nil || !crypt.Verify(pubBytes, RegisterMessage(pub, ip, port, nonce), [][]byte{sigR, sigS}) {
+		writeStatus(w, http.StatusUnauthorized, map[string]string{"status": ErrInvalidSignature.Error()})
+		return
+	}
+	token := uuid.NewV4().String()
+	err = r.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(EntryBucket))
+		var stale [][]byte
+		var replayed bool
+		b.ForEach(func(k, v []byte) error {
+			if entry := DeserializeEntry(v); entry.GetPub() == pub {
+				stale = append(stale, k)
+				replayed = replayed || entry.GetNonce() >= nonce
+			}
+			return nil
+		})
+		if replayed {
+			return ErrStaleNonce
+		}
+		for _, k := range stale {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		entry := NewEntry(pub, ip, port)
+		entry.SetNonce(nonce)
+		return b.Put([]byte(token), entry.Serialize())
+	})
+	if err == ErrStaleNonce {
+		writeStatus(w, http.StatusUnauthorized, map[string]string{"status": err.Error()})
+		return
+	} else if err != nil {
+		writeStatus(w, http.StatusInternalServerError, map[string]string{"status": err.Error()})
+		return
+	}
+	glg.Info("Registry: dispatcher registered - " + pub)
+	writeStatus(w, http.StatusCreated, map[string]string{"status": "success", "token": token})
+}
+
+func (r Registry) update(w http.ResponseWriter, req *http.Request) {
+	token := []byte(req.Header.Get(TokenHeader))
+	err := r.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(EntryBucket))
+		v := b.Get(token)
+		if v == nil {
+			return ErrInvalidToken
+		}
+		entry := DeserializeEntry(v)
+		switch mux.Vars(req)["action"] {
+		case "connect":
+			entry.IncrWorkers()
+		case "disconnect":
+			entry.DecrWorkers()
+		case "wake":
+			entry.SetAwake(true)
+		case "sleep":
+			entry.SetAwake(false)
+			entry.SetWorkers(0)
+		}
+		return b.Put(token, entry.Serialize())
+	})
+	if err == ErrInvalidToken {
+		writeStatus(w, http.StatusUnauthorized, map[string]string{"status": err.Error()})
+		return
+	} else if err != nil {
+		writeStatus(w, http.StatusInternalServerError, map[string]string{"status": err.Error()})
+		return
+	}
+	writeStatus(w, http.StatusOK, map[string]string{"status": "success"})
+}
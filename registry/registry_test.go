@@ -0,0 +1,101 @@
+package registry_test
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/gizo-network/gizo/crypt"
+	"github.com/gizo-network/gizo/p2p"
+	"github.com/gizo-network/gizo/registry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gizo-registry")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	db, err := bolt.Open(path.Join(dir, registry.RegistryDB), 0600, &bolt.Options{Timeout: time.Second * 2})
+	assert.NoError(t, err)
+	defer db.Close()
+	server := httptest.NewServer(registry.NewRegistry(db).GetRouter())
+	defer server.Close()
+
+	priv, pubBytes := crypt.GenKeys()
+	pub := hex.EncodeToString(pubBytes)
+	otherPriv, otherPubBytes := crypt.GenKeys()
+	otherPub := hex.EncodeToString(otherPubBytes)
+
+	c := p2p.NewCentrum(server.URL)
+	_, err = c.GetDispatchers()
+	assert.Equal(t, p2p.ErrNoDispatchers, err)
+	assert.Equal(t, p2p.ErrNoToken, c.Wake())
+
+	assert.Error(t, c.Register(priv, "not hex", "127.0.0.1", 9999))
+	assert.Error(t, c.Register(otherPriv, pub, "127.0.0.1", 9999)) //! signed by another node's key
+	assert.NoError(t, c.Register(priv, pub, "127.0.0.1", 9999))
+	assert.NotEmpty(t, c.GetToken())
+
+	other := p2p.NewCentrum(server.URL)
+	assert.NoError(t, other.Register(otherPriv, otherPub, "127.0.0.2", 9999))
+	assert.NoError(t, c.ConnectWorker())
+
+	dispatchers, err := c.GetDispatchers()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"gizo://" + otherPub + "@127.0.0.2:9999", "gizo://" + pub + "@127.0.0.1:9999"}, dispatchers)
+	addr, err := p2p.ParseAddr(dispatchers[1])
+	assert.NoError(t, err)
+	assert.Equal(t, pub, addr["pub"])
+
+	assert.NoError(t, other.Sleep())
+	dispatchers, err = c.GetDispatchers()
+	assert.NoError(t, err)
+	assert.Len(t, dispatchers, 1)
+	assert.NoError(t, other.Wake())
+
+	c.SetToken("invalid")
+	assert.Error(t, c.DisconnectWorker())
+}
+
+func TestRegistryReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gizo-registry")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	db, err := bolt.Open(path.Join(dir, registry.RegistryDB), 0600, &bolt.Options{Timeout: time.Second * 2})
+	assert.NoError(t, err)
+	defer db.Close()
+	server := httptest.NewServer(registry.NewRegistry(db).GetRouter())
+	defer server.Close()
+
+	priv, pubBytes := crypt.GenKeys()
+	pub := hex.EncodeToString(pubBytes)
+	register := func(nonce int64) int {
+		sig, err := crypt.Sign(priv, registry.RegisterMessage(pub, "127.0.0.1", 9999, nonce))
+		assert.NoError(t, err)
+		res, err := http.PostForm(server.URL+"/v1/dispatcher", url.Values{
+			"pub":   {pub},
+			"ip":    {"127.0.0.1"},
+			"port":  {"9999"},
+			"nonce": {strconv.FormatInt(nonce, 10)},
+			"r":     {hex.EncodeToString(sig[0])},
+			"s":     {hex.EncodeToString(sig[1])},
+		})
+		assert.NoError(t, err)
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	nonce := time.Now().UnixNano()
+	assert.Equal(t, http.StatusCreated, register(nonce))
+	assert.Equal(t, http.StatusUnauthorized, register(nonce))
+	assert.Equal(t, http.StatusUnauthorized, register(time.Now().Add(-registry.MaxRegisterAge*2).UnixNano()))
+	assert.Equal(t, http.StatusCreated, register(time.Now().UnixNano()))
+}